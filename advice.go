@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// recommend returns a short clothing/activity suggestion for the current
+// conditions, or "" when nothing stands out.
+func recommend(w WeatherData) string {
+	var tips []string
+	condition := strings.ToLower(w.condition().Main)
+	temp := w.celsius(w.Main.Temp)
+
+	// Precipitation matters most, so it goes first.
+	switch condition {
+	case "rain", "drizzle":
+		tips = append(tips, "Bring an umbrella.")
+	case "thunderstorm":
+		tips = append(tips, "Bring an umbrella and avoid open spaces.")
+	case "snow":
+		tips = append(tips, "Wear waterproof boots.")
+	}
+
+	switch {
+	case temp < 5:
+		tips = append(tips, "Dress warmly.")
+	case temp > 30:
+		tips = append(tips, "Stay hydrated and seek shade.")
+	}
+
+	// 10 m/s is roughly a "fresh breeze" on the Beaufort scale.
+	if w.Wind.Speed >= 10 {
+		tips = append(tips, "Expect strong winds.")
+	}
+
+	// Only suggest a walk when none of the above applied.
+	if len(tips) == 0 && (condition == "clear" || condition == "clouds") && temp >= 12 && temp <= 26 {
+		tips = append(tips, "Great day for a walk.")
+	}
+
+	return strings.Join(tips, " ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// staleObservationAge is how old an observation can get before the report
+// flags it, set with STALE_OBSERVATION_AGE.
+var staleObservationAge = envDuration("STALE_OBSERVATION_AGE", 2*time.Hour)
+
+// dataAgeNote describes how old a report is from when OpenWeather observed
+// it and when this server fetched it; either time may be zero if unknown.
+// stale is set once the observation is older than staleObservationAge.
+func dataAgeNote(observed, fetched, now time.Time) (note string, stale bool) {
+	switch {
+	case !observed.IsZero() && !fetched.IsZero():
+		note = fmt.Sprintf("data is %s old, fetched %s ago", formatAge(now.Sub(observed)), formatAge(now.Sub(fetched)))
+	case !observed.IsZero():
+		note = fmt.Sprintf("data is %s old", formatAge(now.Sub(observed)))
+	case !fetched.IsZero():
+		note = fmt.Sprintf("fetched %s ago", formatAge(now.Sub(fetched)))
+	default:
+		return "", false
+	}
+	if !observed.IsZero() && now.Sub(observed) > staleObservationAge {
+		return note + ", potentially stale", true
+	}
+	return note, false
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < 2*time.Minute:
+		return "1 minute"
+	case d < 2*time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d hours", int(d.Hours()))
+}
+
+// observedAt is the observation time, or zero when the response had none.
+func (w WeatherData) observedAt() time.Time {
+	if w.Dt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(w.Dt, 0)
+}
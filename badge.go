@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// badgeTemplate is a two-part shields.io style badge: the city on grey,
+// the reading on a color from tempColor. %[1]d is the total width, %[2]d
+// the label width, %[3]d the value width, %[4]s the label, %[5]s the
+// value, %[6]s the value color and %[7]d/%[8]d the text centers.
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<rect width="%[2]d" height="20" fill="#555"/>
+<rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="14">%[4]s</text>
+<text x="%[8]d" y="14">%[5]s</text>
+</g>
+</svg>
+`
+
+// badgeTextWidth approximates the rendered width of s at 11px Verdana,
+// plus padding; close enough for short labels.
+func badgeTextWidth(s string) int {
+	return utf8.RuneCountInString(s)*7 + 10
+}
+
+func renderBadge(label, value, color string) string {
+	lw, vw := badgeTextWidth(label), badgeTextWidth(value)
+	return fmt.Sprintf(badgeTemplate, lw+vw, lw, vw, html.EscapeString(label), html.EscapeString(value), color, lw/2, lw+vw/2)
+}
+
+// handleBadge serves an SVG badge with the city and its current
+// temperature, from the same cache as /weather/{city}.
+func (s *server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	upstreamUnits := fetchUnits(units)
+	data, status, err := s.cache.Get(r.Context(), cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, upstreamUnits)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	value := notAvailable
+	if data.tempKnown(data.Main.Temp) {
+		value = formatTemp(convertTemp(data.Main.Temp, data.Units, units), 0) + unitSetFor(units).Temp
+	}
+	c := data.condition()
+	value = conditionEmoji(c.Main, c.ID) + " " + value
+	s.cache.setCacheHeaders(w, status, data)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cache.ttl.Seconds())))
+	w.Write([]byte(renderBadge(data.Location().Name, value, tempColor(data.celsius(data.Main.Temp)))))
+}
+
+// handleEmoji answers with nothing but the condition emoji, or the
+// ?theme= symbol, for status bars and other widgets with room for a single
+// glyph.
+func (s *server) handleEmoji(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	theme, err := parseTheme(r.URL.Query().Get("theme"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	units := fetchUnits("")
+	data, status, err := s.cache.Get(r.Context(), cacheKey(city, units), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, units)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	c := data.condition()
+	emoji := conditionSymbol(theme, c.Main, c.ID)
+	s.cache.setCacheHeaders(w, status, data)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cache.ttl.Seconds())))
+	w.Write([]byte(emoji))
+}
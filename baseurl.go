@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// publicBaseFallback is PUBLIC_BASE_URL, used when a request carries no
+// usable host, e.g. "https://weather.example.com".
+var publicBaseFallback = loadPublicBaseFallback()
+
+func loadPublicBaseFallback() string {
+	raw := os.Getenv("PUBLIC_BASE_URL")
+	if raw == "" {
+		return "http://localhost" + listenAddr
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Printf("ignoring PUBLIC_BASE_URL %q: expected an absolute http or https URL", raw)
+		return "http://localhost" + listenAddr
+	}
+	return strings.TrimSuffix(raw, "/")
+}
+
+// publicBaseURL is the scheme and host clients reach this server on, for
+// building absolute links. Behind a reverse proxy that comes from the
+// first X-Forwarded-Proto and X-Forwarded-Host values; otherwise from the
+// request itself, then publicBaseFallback.
+func publicBaseURL(r *http.Request) string {
+	host := firstForwarded(r.Header.Get("X-Forwarded-Host"))
+	if host == "" {
+		host = r.Host
+	}
+	if !validHost(host) {
+		return publicBaseFallback
+	}
+	scheme := strings.ToLower(firstForwarded(r.Header.Get("X-Forwarded-Proto")))
+	if scheme != "http" && scheme != "https" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + host
+}
+
+// firstForwarded takes the first entry of a comma-separated forwarded
+// header, which is the one the outermost proxy set.
+func firstForwarded(v string) string {
+	first, _, _ := strings.Cut(v, ",")
+	return strings.TrimSpace(first)
+}
+
+// validHost rules out anything but a bare host or host:port, so a forged
+// header can't smuggle a path or another URL into a link.
+func validHost(host string) bool {
+	if host == "" || strings.ContainsAny(host, "/\\@?# ") {
+		return false
+	}
+	u, err := url.Parse("http://" + host)
+	return err == nil && u.Host == host
+}
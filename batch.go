@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxBatchCities caps how many cities one batch request may ask for, and
+// batchWorkers how many of them are fetched at once.
+var (
+	maxBatchCities = envInt("BATCH_MAX_CITIES", 20)
+	batchWorkers   = max(1, envInt("BATCH_WORKERS", 5))
+)
+
+type batchForecastRequest struct {
+	Cities []string `json:"cities"`
+	Days   int      `json:"days"`
+}
+
+type dailyJSON struct {
+	Date      string  `json:"date"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Avg       float64 `json:"avg"`
+	Condition string  `json:"condition,omitempty"`
+}
+
+// batchForecastResult is one city's outcome; a failed city carries Error
+// and Status instead of Days so the others are still returned.
+type batchForecastResult struct {
+	City     string      `json:"city"`
+	Location *Location   `json:"location,omitempty"`
+	Days     []dailyJSON `json:"days,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Status   int         `json:"status,omitempty"`
+}
+
+// handleBatchForecast returns the daily rollup for every city in the body,
+// fetched by batchWorkers workers. Upstream calls still go through the
+// provider, so the client's concurrency limit applies on top.
+//
+// With ?mode=partial (the default) failed cities are reported inline and
+// the response is 200. With ?mode=strict any failure fails the request
+// with the status and error of the first failed city in request order.
+func (s *server) handleBatchForecast(w http.ResponseWriter, r *http.Request) {
+	strict, err := parseBatchMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req batchForecastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case len(req.Cities) == 0:
+		http.Error(w, "cities is required", http.StatusBadRequest)
+		return
+	case len(req.Cities) > maxBatchCities:
+		http.Error(w, fmt.Sprintf("at most %d cities per batch", maxBatchCities), http.StatusBadRequest)
+		return
+	case req.Days < 0:
+		http.Error(w, fmt.Sprintf("invalid days %d: expected an integer between 1 and %d", req.Days, maxForecastDays), http.StatusBadRequest)
+		return
+	}
+	limit := min(req.Days, maxForecastDays)
+	units := requestUnits(r)
+	if _, ok := tempLabels[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := batchContext(r)
+	defer cancel()
+	results, done := collectUntil(ctx, len(req.Cities), batchWorkers, func(ctx context.Context, i int) (res batchForecastResult) {
+		res.City = req.Cities[i]
+		city, err := parseCityQuery(req.Cities[i])
+		if err != nil {
+			res.Error, res.Status = err.Error(), cityErrorStatus(err)
+			return res
+		}
+		data, err := s.provider.Forecast(ctx, city, 0, fetchUnits(units))
+		if err != nil {
+			res.Error, res.Status = err.Error(), queryErrorStatus(err)
+			return res
+		}
+		days := dailyRollup(data)
+		if limit > 0 && len(days) > limit {
+			days = days[:limit]
+		}
+		loc := data.Location()
+		res.Location = &loc
+		res.Days = formatDailyJSON(days, units)
+		return res
+	})
+	timedOut := 0
+	for i := range results {
+		if !done[i] {
+			results[i] = batchForecastResult{City: req.Cities[i], Error: errBatchTimedOut.Error(), Status: http.StatusGatewayTimeout}
+			timedOut++
+		}
+	}
+
+	if strict {
+		for _, res := range results {
+			if res.Error != "" {
+				http.Error(w, res.City+": "+res.Error, res.Status)
+				return
+			}
+		}
+	}
+	body := map[string]any{"units": units, "results": results}
+	if timedOut > 0 {
+		body["note"] = fmt.Sprintf("%d of %d cities timed out", timedOut, len(results))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// parseBatchMode validates ?mode= and reports whether it is strict.
+func parseBatchMode(raw string) (strict bool, err error) {
+	switch raw {
+	case "", "partial":
+		return false, nil
+	case "strict":
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported mode %q: expected strict or partial", raw)
+}
+
+// collectUntil calls fn for 0..n-1 from at most workers goroutines and
+// waits for all of them, or until ctx is done. Indexes not started by then
+// are skipped, and calls still running see their ctx cancelled and are
+// abandoned: out[i] is fn(ctx, i) only where done[i] is set.
+func collectUntil[T any](ctx context.Context, n, workers int, fn func(ctx context.Context, i int) T) (out []T, done []bool) {
+	out, done = make([]T, n), make([]bool, n)
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				v := fn(ctx, i)
+				mu.Lock()
+				// A call cut short by ctx fails with ctx's error, which is
+				// the timeout the caller reports for it, not a result.
+				if !closed && ctx.Err() == nil {
+					out[i], done[i] = v, true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer wg.Wait()
+		defer close(next)
+		for i := range n {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	closed = true
+	return out, done
+}
+
+// batchDeadlineMargin is how long before the request deadline batch
+// handlers stop waiting, so what has been fetched still beats the 504.
+var batchDeadlineMargin = envDuration("BATCH_DEADLINE_MARGIN", time.Second)
+
+// batchContext ends batchDeadlineMargin before r's deadline, if it has one.
+func batchContext(r *http.Request) (context.Context, context.CancelFunc) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithDeadline(r.Context(), deadline.Add(-batchDeadlineMargin))
+}
+
+// errBatchTimedOut marks the entries collectUntil gave up on.
+var errBatchTimedOut = errors.New("timed out before this entry was fetched")
+
+// formatDailyJSON converts a rollup to JSON rows in units.
+func formatDailyJSON(days []DailyForecast, units string) []dailyJSON {
+	out := make([]dailyJSON, 0, len(days))
+	for _, d := range days {
+		out = append(out, dailyJSON{
+			Date:      d.Date.Format("2006-01-02"),
+			Min:       convertTemp(d.Min, unitsStandard, units),
+			Max:       convertTemp(d.Max, unitsStandard, units),
+			Avg:       convertTemp(d.Avg, unitsStandard, units),
+			Condition: d.Condition,
+		})
+	}
+	return out
+}
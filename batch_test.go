@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchForecastUnits(t *testing.T) {
+	h := newTestServer().routes()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	// London's warmest step is 291.4 K.
+	for units, wantMax := range map[string]float64{
+		"":            convertTemp(291.4, unitsStandard, unitsMetric),
+		unitsImperial: convertTemp(291.4, unitsStandard, unitsImperial),
+		unitsStandard: 291.4,
+	} {
+		rec := post("/forecast/batch?units="+units, `{"cities": ["London", "Atlantis"]}`)
+		var body struct {
+			Units   string                `json:"units"`
+			Results []batchForecastResult `json:"results"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Results) != 2 || len(body.Results[0].Days) == 0 {
+			t.Fatalf("batch ?units=%s: %v: %s", units, err, rec.Body.String())
+		}
+		want := units
+		if want == "" {
+			want = unitsMetric
+		}
+		if d := body.Results[0].Days[0]; body.Units != want || math.Abs(d.Max-wantMax) > 1e-9 {
+			t.Errorf("batch ?units=%s: units %q, first day %+v, want %s with max %.2f", units, body.Units, d, want, wantMax)
+		}
+		if r := body.Results[1]; r.Status != http.StatusNotFound || r.Days != nil {
+			t.Errorf("batch ?units=%s: unknown city got %+v, want a 404 entry", units, r)
+		}
+	}
+
+	if rec := post("/forecast/batch?units=rankine", `{"cities": ["London"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("batch ?units=rankine: got %d, want 400", rec.Code)
+	}
+	if rec := post("/forecast/batch", `{"cities": []}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("batch with no cities: got %d, want 400", rec.Code)
+	}
+}
+
+// TestCollectUntil checks every index is visited once and no more than
+// workers calls ever run at the same time.
+func TestCollectUntil(t *testing.T) {
+	for _, workers := range []int{1, 5, 50} {
+		var running, peak atomic.Int64
+		seen := make([]atomic.Int64, 17)
+		_, done := collectUntil(context.Background(), len(seen), workers, func(_ context.Context, i int) struct{} {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			seen[i].Add(1)
+			running.Add(-1)
+			return struct{}{}
+		})
+		if p := peak.Load(); p > int64(workers) {
+			t.Errorf("workers %d: %d calls at once", workers, p)
+		}
+		for i := range seen {
+			if n := seen[i].Load(); n != 1 || !done[i] {
+				t.Errorf("workers %d: index %d visited %d times, done %v", workers, i, n, done[i])
+			}
+		}
+	}
+}
+
+func TestBatchMaxCities(t *testing.T) {
+	defer func(n int) { maxBatchCities = n }(maxBatchCities)
+	maxBatchCities = 2
+	rec := httptest.NewRecorder()
+	newTestServer().routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/forecast/batch", strings.NewReader(`{"cities": ["London", "Paris", "Rome"]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "at most 2 cities") {
+		t.Errorf("3 cities with BATCH_MAX_CITIES=2: got %d %q, want 400", rec.Code, rec.Body)
+	}
+}
+
+func TestBatchMode(t *testing.T) {
+	h := newTestServer().routes()
+	tests := []struct {
+		mode       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"", http.StatusOK, `"status":404`},
+		{"partial", http.StatusOK, `"status":404`},
+		{"strict", http.StatusNotFound, "Atlantis: "},
+		{"lenient", http.StatusBadRequest, "unsupported mode"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/forecast/batch?mode="+tt.mode, strings.NewReader(`{"cities": ["London", "Atlantis"]}`)))
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("mode %q: got %d %q, want %d containing %q", tt.mode, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+// TestCollectUntilCancelsSlowCalls gives collectUntil a mix of fast and
+// slow calls and a deadline only the fast ones meet: the slow ones must
+// see their ctx cancelled rather than run on unobserved.
+func TestCollectUntilCancelsSlowCalls(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var running, cancelled atomic.Int64
+	exited := make(chan struct{}, 4)
+	out, done := collectUntil(ctx, 4, 4, func(ctx context.Context, i int) int {
+		if i%2 == 0 {
+			return i
+		}
+		running.Add(1)
+		defer func() { exited <- struct{}{} }()
+		select {
+		case <-ctx.Done():
+			cancelled.Add(1)
+		case <-time.After(time.Minute):
+		}
+		return i
+	})
+	for i := range out {
+		if fast := i%2 == 0; done[i] != fast {
+			t.Errorf("done[%d] = %v, want %v", i, done[i], fast)
+		}
+		if done[i] && out[i] != i {
+			t.Errorf("out[%d] = %d, want %d", i, out[i], i)
+		}
+	}
+	for range running.Load() {
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("a slow call is still running after collectUntil returned")
+		}
+	}
+	if n := cancelled.Load(); n != running.Load() {
+		t.Errorf("%d of %d slow calls saw ctx cancelled", n, running.Load())
+	}
+}
+
+// TestBatchForecastSlowCity checks that a city slower than the batch
+// deadline is reported as timed out, the others are still returned, and
+// the slow provider call is cancelled.
+func TestBatchForecastSlowCity(t *testing.T) {
+	t.Setenv("TIMEOUT_BATCH", "300ms")
+	defer func(d time.Duration) { batchDeadlineMargin = d }(batchDeadlineMargin)
+	batchDeadlineMargin = 100 * time.Millisecond
+
+	paris := sampleForecast()
+	paris.City.Name, paris.City.Country = "Paris", "FR"
+	p := observedProvider{
+		FakeProvider: &FakeProvider{
+			Forecasts: map[string]ForecastData{cacheKey("London"): sampleForecast(), cacheKey("Paris"): paris},
+			Delays:    map[string]time.Duration{cacheKey("Paris"): time.Minute},
+		},
+		errs: make(chan error, 2),
+	}
+	h := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries)).handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/forecast/batch", strings.NewReader(`{"cities": ["London", "Paris"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Results []batchForecastResult `json:"results"`
+		Note    string                `json:"note"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	if len(body.Results) != 2 {
+		t.Fatalf("%d results, want 2: %s", len(body.Results), rec.Body.String())
+	}
+	if london := body.Results[0]; london.Error != "" || len(london.Days) == 0 {
+		t.Errorf("London = %+v, want its days", london)
+	}
+	if paris := body.Results[1]; paris.Status != http.StatusGatewayTimeout || paris.Error != errBatchTimedOut.Error() {
+		t.Errorf("Paris = %+v, want a 504 timeout entry", paris)
+	}
+	if body.Note != "1 of 2 cities timed out" {
+		t.Errorf("note = %q", body.Note)
+	}
+
+	var cancelled bool
+	for range 2 {
+		select {
+		case err := <-p.errs:
+			cancelled = cancelled || errors.Is(err, context.DeadlineExceeded)
+		case <-time.After(5 * time.Second):
+			t.Fatal("a provider call is still running after the response")
+		}
+	}
+	if !cancelled {
+		t.Error("the slow Forecast call didn't see the deadline")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultBoxZoom is the map zoom /weather/box uses without ?zoom=. Higher
+// zooms list smaller towns as well as the major cities.
+const defaultBoxZoom = 10
+
+// BoxProvider is implemented by providers that can list the weather in
+// every city inside a bounding box.
+type BoxProvider interface {
+	Box(ctx context.Context, b bbox, zoom int) (BoxData, error)
+}
+
+var errBoxUnavailable = errors.New("bounding box lookups are not available")
+
+// bbox is a region between two meridians and two parallels. Boxes across
+// the antimeridian aren't supported; split them in two.
+type bbox struct {
+	West, South, East, North float64
+}
+
+// parseBBox validates ?bbox=lon1,lat1,lon2,lat2, the south-west corner
+// followed by the north-east one as OpenWeather orders them.
+func parseBBox(raw string) (bbox, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return bbox{}, fmt.Errorf("invalid bbox %q: expected lon1,lat1,lon2,lat2", raw)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return bbox{}, fmt.Errorf("invalid bbox %q: %q is not a number", raw, part)
+		}
+		v[i] = f
+	}
+	b := bbox{West: v[0], South: v[1], East: v[2], North: v[3]}
+	switch {
+	case b.West < -180 || b.West > 180 || b.East < -180 || b.East > 180:
+		return bbox{}, fmt.Errorf("invalid bbox %q: longitudes must be -180 to 180", raw)
+	case b.South < -90 || b.South > 90 || b.North < -90 || b.North > 90:
+		return bbox{}, fmt.Errorf("invalid bbox %q: latitudes must be -90 to 90", raw)
+	case b.West >= b.East:
+		return bbox{}, fmt.Errorf("invalid bbox %q: lon1 must be west of lon2", raw)
+	case b.South >= b.North:
+		return bbox{}, fmt.Errorf("invalid bbox %q: lat1 must be south of lat2", raw)
+	}
+	return b, nil
+}
+
+func (b bbox) String() string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return f(b.West) + "," + f(b.South) + "," + f(b.East) + "," + f(b.North)
+}
+
+// parseBoxZoom validates the optional zoom parameter, on the same scale
+// as the map tiles.
+func parseBoxZoom(raw string) (int, error) {
+	if raw == "" {
+		return defaultBoxZoom, nil
+	}
+	zoom, err := strconv.Atoi(raw)
+	if err != nil || zoom < 1 || zoom > maxTileZoom {
+		return 0, fmt.Errorf("invalid zoom %q: expected an integer between 1 and %d", raw, maxTileZoom)
+	}
+	return zoom, nil
+}
+
+// BoxCity is one city in OpenWeather's box/city response. Its coordinates
+// are capitalised and it has no country, unlike /weather.
+type BoxCity struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Dt    int64  `json:"dt"`
+	Coord struct {
+		Lon float64 `json:"Lon"`
+		Lat float64 `json:"Lat"`
+	} `json:"coord"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int     `json:"pressure"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+	} `json:"wind"`
+	Clouds struct {
+		Today int `json:"today"`
+	} `json:"clouds"`
+	Weather []Condition `json:"weather"`
+}
+
+type BoxData struct {
+	Cnt  int       `json:"cnt"`
+	List []BoxCity `json:"list"`
+
+	// Units is the unit system the readings are in; the box is fetched in
+	// standard, as the forecast is.
+	Units string `json:"-"`
+}
+
+// Box fetches the current weather in the cities inside b.
+func (c *WeatherClient) Box(ctx context.Context, b bbox, zoom int) (BoxData, error) {
+	params := url.Values{"bbox": {b.String() + "," + strconv.Itoa(zoom)}}
+	var data BoxData
+	if err := c.fetch(ctx, endpointBox, params, &data); err != nil {
+		return BoxData{}, err
+	}
+	data.Units = unitsStandard
+	return data, nil
+}
+
+// boxCityResult is one city of /weather/box in the requested units. A
+// missing temperature or feels-like reading is null, as in JSONFormatter.
+type boxCityResult struct {
+	Name        string   `json:"name"`
+	Lat         float64  `json:"lat"`
+	Lon         float64  `json:"lon"`
+	Temperature *float64 `json:"temperature"`
+	FeelsLike   *float64 `json:"feels_like"`
+	Humidity    int      `json:"humidity"`
+	WindSpeed   float64  `json:"wind_speed"`
+	Condition   string   `json:"condition"`
+	Description string   `json:"description"`
+}
+
+// handleWeatherBox reports the current weather in every city OpenWeather
+// lists inside ?bbox=, as a JSON array or, with ?format=summary, as a
+// plain text overview of the region.
+func (s *server) handleWeatherBox(w http.ResponseWriter, r *http.Request) {
+	bp, ok := s.provider.(BoxProvider)
+	if !ok {
+		http.Error(w, errBoxUnavailable.Error(), http.StatusNotImplemented)
+		return
+	}
+	// The cities in a box can't be checked against the allowlist before
+	// they're fetched, so it rules boxes out as it does coordinates.
+	if allowedCities != nil {
+		http.Error(w, "bounding box lookups are disabled while ALLOWED_CITIES is set", http.StatusForbidden)
+		return
+	}
+	raw := r.URL.Query().Get("bbox")
+	if raw == "" {
+		http.Error(w, "bbox is required, as ?bbox=lon1,lat1,lon2,lat2", http.StatusBadRequest)
+		return
+	}
+	b, err := parseBBox(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	zoom, err := parseBoxZoom(r.URL.Query().Get("zoom"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "summary" {
+		http.Error(w, fmt.Sprintf("unsupported format %q: expected json or summary", format), http.StatusBadRequest)
+		return
+	}
+	data, err := bp.Box(r.Context(), b, zoom)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	// A box city has no tempKnown of its own, but the same ABSOLUTE_ZERO_GUARD
+	// rules apply.
+	known := WeatherData{Units: data.Units}.tempKnown
+	temp := func(v float64) *float64 {
+		if !known(v) {
+			return nil
+		}
+		t := roundTemp(convertTemp(v, data.Units, units), 2)
+		return &t
+	}
+	results := make([]boxCityResult, 0, len(data.List))
+	for _, c := range data.List {
+		condition := primaryCondition(c.Weather)
+		results = append(results, boxCityResult{
+			Name:        c.Name,
+			Lat:         c.Coord.Lat,
+			Lon:         c.Coord.Lon,
+			Temperature: temp(c.Main.Temp),
+			FeelsLike:   temp(c.Main.FeelsLike),
+			Humidity:    c.Main.Humidity,
+			WindSpeed:   convertSpeed(c.Wind.Speed, data.Units, units),
+			Condition:   condition.Main,
+			Description: condition.Description,
+		})
+	}
+	if format == "summary" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(boxSummary(b, results, units)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// boxSummary sums the region up: how many cities, their average
+// temperature, the warmest and coldest, and the most common condition.
+// Cities without a temperature count towards the condition only.
+func boxSummary(b bbox, results []boxCityResult, units string) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "Weather in %s 🌍\n", b)
+	fmt.Fprintf(&out, "==================================\n")
+	if len(results) == 0 {
+		out.WriteString("No cities reported in this area.\n")
+		return out.String()
+	}
+	label := unitSetFor(units).Temp
+	var sum float64
+	var measured int
+	var warmest, coldest boxCityResult
+	counts := make(map[string]int)
+	var common string
+	for _, res := range results {
+		if t := res.Temperature; t != nil {
+			if measured == 0 || *t > *warmest.Temperature {
+				warmest = res
+			}
+			if measured == 0 || *t < *coldest.Temperature {
+				coldest = res
+			}
+			sum += *t
+			measured++
+		}
+		counts[res.Condition]++
+		// Ties go to the condition seen first, as in dailyRollup.
+		if counts[res.Condition] > counts[common] {
+			common = res.Condition
+		}
+	}
+	tw := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cities:\t%d\n", len(results))
+	if measured > 0 {
+		fmt.Fprintf(tw, "Average:\t%s%s\n", formatTemp(sum/float64(measured), 1), label)
+		fmt.Fprintf(tw, "Warmest:\t%s, %s%s\n", stripControl(warmest.Name), formatTemp(*warmest.Temperature, 1), label)
+		fmt.Fprintf(tw, "Coldest:\t%s, %s%s\n", stripControl(coldest.Name), formatTemp(*coldest.Temperature, 1), label)
+	} else {
+		fmt.Fprintf(tw, "Average:\t%s\n", notAvailable)
+	}
+	fmt.Fprintf(tw, "Most common:\t%s %s (%d of %d)\n", conditionSymbol(defaultTheme, common, 0), common, counts[common], len(results))
+	tw.Flush()
+	return out.String()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// boxJSON is a box/city response for two cities near London, in standard
+// units. Coordinates are capitalised and clouds are "today", as
+// OpenWeather sends them; Slough has no feels_like.
+const boxJSON = `{
+	"cod": 200, "calctime": 0.3, "cnt": 2,
+	"list": [
+		{"id": 2643743, "dt": 1718791200, "name": "London",
+			"coord": {"Lon": -0.1257, "Lat": 51.5085},
+			"main": {"temp": 288.15, "feels_like": 287.6, "temp_min": 286.9, "temp_max": 289.3, "pressure": 1012, "humidity": 72},
+			"wind": {"speed": 4.6, "deg": 240}, "clouds": {"today": 75},
+			"weather": [{"id": 803, "main": "Clouds", "description": "broken clouds", "icon": "04d"}]},
+		{"id": 2637827, "dt": 1718791200, "name": "Slough",
+			"coord": {"Lon": -0.5959, "Lat": 51.5095},
+			"main": {"temp": 290.15, "temp_min": 289.1, "temp_max": 291.2, "pressure": 1012, "humidity": 65},
+			"wind": {"speed": 3.1, "deg": 250}, "clouds": {"today": 40},
+			"weather": [{"id": 802, "main": "Clouds", "description": "scattered clouds", "icon": "03d"}]}
+	]
+}`
+
+func TestParseBBox(t *testing.T) {
+	valid := map[string]bbox{
+		"-0.6,51.3,0.3,51.7":     {West: -0.6, South: 51.3, East: 0.3, North: 51.7},
+		" -0.6, 51.3 ,0.3,51.7 ": {West: -0.6, South: 51.3, East: 0.3, North: 51.7},
+		// The limits themselves are allowed.
+		"-180,-90,180,90": {West: -180, South: -90, East: 180, North: 90},
+	}
+	for raw, want := range valid {
+		got, err := parseBBox(raw)
+		if err != nil || got != want {
+			t.Errorf("parseBBox(%q) = %+v, %v; want %+v", raw, got, err, want)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                      "expected lon1,lat1,lon2,lat2",
+		"1,2,3":                 "expected lon1,lat1,lon2,lat2",
+		"1,2,3,4,5":             "expected lon1,lat1,lon2,lat2",
+		"a,51.3,0.3,51.7":       "is not a number",
+		"NaN,51.3,0.3,51.7":     "is not a number",
+		"-0.6,51.3,Inf,51.7":    "is not a number",
+		"-180.1,51.3,0.3,51.7":  "longitudes must be -180 to 180",
+		"-0.6,51.3,180.1,51.7":  "longitudes must be -180 to 180",
+		"-0.6,-90.1,0.3,51.7":   "latitudes must be -90 to 90",
+		"-0.6,51.3,0.3,90.1":    "latitudes must be -90 to 90",
+		"0.3,51.3,-0.6,51.7":    "lon1 must be west of lon2",
+		"0.3,51.3,0.3,51.7":     "lon1 must be west of lon2",
+		"-0.6,51.7,0.3,51.3":    "lat1 must be south of lat2",
+		"170,51.3,-170,51.7":    "lon1 must be west of lon2",
+		"-0.6,51.3,0.3,51.3000": "lat1 must be south of lat2",
+	}
+	for raw, want := range invalid {
+		if _, err := parseBBox(raw); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseBBox(%q) error %v, want one saying %q", raw, err, want)
+		}
+	}
+}
+
+func TestParseBoxZoom(t *testing.T) {
+	for raw, want := range map[string]int{"": defaultBoxZoom, "1": 1, "12": 12, "18": maxTileZoom} {
+		if got, err := parseBoxZoom(raw); err != nil || got != want {
+			t.Errorf("parseBoxZoom(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"0", "-1", "19", "1.5", "ten"} {
+		if _, err := parseBoxZoom(raw); err == nil {
+			t.Errorf("parseBoxZoom(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestBoxDataParse(t *testing.T) {
+	var data BoxData
+	if err := json.Unmarshal([]byte(boxJSON), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Cnt != 2 || len(data.List) != 2 {
+		t.Fatalf("cnt %d, %d cities; want 2 and 2", data.Cnt, len(data.List))
+	}
+	c := data.List[0]
+	if c.Name != "London" || c.Coord.Lon != -0.1257 || c.Coord.Lat != 51.5085 || c.Clouds.Today != 75 || c.Main.Temp != 288.15 {
+		t.Errorf("London parsed as %+v", c)
+	}
+	if data.List[1].Main.FeelsLike != 0 {
+		t.Errorf("Slough feels_like = %v, want 0 for missing", data.List[1].Main.FeelsLike)
+	}
+}
+
+// TestWeatherBoxEndpoint serves boxJSON as OpenWeather and checks the
+// request sent upstream and both output formats.
+func TestWeatherBoxEndpoint(t *testing.T) {
+	var gotBBox string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBBox = r.URL.Query().Get("bbox")
+		w.Write([]byte(boxJSON))
+	}))
+	defer upstream.Close()
+	t.Setenv("OPENWEATHER_ENDPOINT_BOX", upstream.URL)
+	h := newServer(newTestClient(t, upstream), NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries)).handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/weather/box?bbox=-0.6,51.3,0.3,51.7&zoom=8&units=metric")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotBBox != "-0.6,51.3,0.3,51.7,8" {
+		t.Errorf("upstream bbox = %q, want -0.6,51.3,0.3,51.7,8", gotBBox)
+	}
+	var cities []boxCityResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &cities); err != nil || len(cities) != 2 {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	if c := cities[0]; c.Name != "London" || c.Temperature == nil || *c.Temperature != 15 || c.Lon != -0.1257 {
+		t.Errorf("London = %+v", c)
+	}
+	if !strings.Contains(rec.Body.String(), `"feels_like":null`) {
+		t.Errorf("Slough's missing feels_like isn't null: %s", rec.Body.String())
+	}
+
+	rec = get("/weather/box?bbox=-0.6,51.3,0.3,51.7&format=summary&units=metric")
+	for _, want := range []string{"Cities:       2", "Average:      16.0°C", "Warmest:      Slough, 17.0°C", "Coldest:      London, 15.0°C", "Clouds (2 of 2)"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("summary lacks %q:\n%s", want, rec.Body.String())
+		}
+	}
+
+	for _, path := range []string{"/weather/box", "/weather/box?bbox=1,2,3", "/weather/box?bbox=-0.6,51.3,0.3,51.7&zoom=0", "/weather/box?bbox=-0.6,51.3,0.3,51.7&format=xml"} {
+		if rec := get(path); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("openweather is unavailable (circuit breaker open)")
+
+// circuitBreaker stops calling OpenWeather after threshold consecutive
+// failures. Once cooldown has passed a single trial request is let
+// through; its outcome closes or re-opens the circuit.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		state:     breakerClosed,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent now.
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// The trial request is still in flight.
+		return false
+	}
+	return true
+}
+
+// Record feeds the outcome of an allowed request back into the breaker.
+func (b *circuitBreaker) Record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// countsAsFailure reports whether err means OpenWeather itself is in
+// trouble, as opposed to the request being bad (unknown city, bad key).
+func countsAsFailure(err error) bool {
+	// A call refused by our own quota never reached OpenWeather.
+	if errors.Is(err, errQuotaExhausted) {
+		return false
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode >= 500 || upstreamErr.StatusCode == 429
+	}
+	return true
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL        = 10 * time.Minute
+	defaultCacheStaleTTL   = 5 * time.Minute
+	defaultCacheMaxEntries = 1000
+	defaultCacheMaxStale   = 6 * time.Hour
+)
+
+// Cache statuses reported in the X-Cache header.
+const (
+	cacheHit          = "HIT"
+	cacheStale        = "STALE"
+	cacheStaleOnError = "STALE-ON-ERROR"
+	cacheMiss         = "MISS"
+)
+
+type cacheEntry struct {
+	key       string
+	data      WeatherData
+	fetchedAt time.Time
+	// ttl is this entry's share of Cache.ttl after jitter.
+	ttl time.Duration
+}
+
+// Cache keeps weather responses in memory. Entries younger than ttl are
+// served as-is; entries within the following staleTTL window are served
+// immediately while a single background refresh replaces them. Once
+// maxEntries is reached the least recently used entry is evicted.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // front is most recently used
+	refreshing map[string]bool
+	ttl        time.Duration
+	staleTTL   time.Duration
+	maxEntries int
+	evictions  int
+	now        func() time.Time
+	// watchers are told about new data for a key, see Watch.
+	watchers map[string]map[chan WeatherData]struct{}
+	// lastFetchOK and lastStaleOnError drive ServingStale.
+	lastFetchOK      time.Time
+	lastStaleOnError time.Time
+	// maxStaleAge caps how old an entry served on upstream error may be;
+	// past it the error is returned instead. Zero means no cap.
+	maxStaleAge time.Duration
+	// ttlJitter spreads expiry of entries stored together, such as those
+	// from warmCache, so they aren't all refreshed at once: each entry's
+	// TTL is ttl ± up to this fraction of it. rand is rand.Float64 outside
+	// tests.
+	ttlJitter float64
+	rand      func() float64
+}
+
+// NewCache creates a cache holding at most maxEntries entries, or an
+// unbounded one if maxEntries is zero.
+func NewCache(ttl, staleTTL time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		refreshing: make(map[string]bool),
+		ttl:        ttl,
+		staleTTL:   staleTTL,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		rand:       rand.Float64,
+		watchers:   make(map[string]map[chan WeatherData]struct{}),
+
+		maxStaleAge: defaultCacheMaxStale,
+	}
+}
+
+func cacheKey(parts ...string) string {
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(p))
+	}
+	return strings.Join(parts, "|")
+}
+
+// loadTTLJitter reads CACHE_TTL_JITTER, a percentage below 100. Other
+// values are logged and ignored.
+func loadTTLJitter() float64 {
+	p := envInt("CACHE_TTL_JITTER", 0)
+	if p < 0 || p >= 100 {
+		log.Printf("ignoring CACHE_TTL_JITTER=%d: expected a percentage from 0 to 99", p)
+		return 0
+	}
+	return float64(p) / 100
+}
+
+// cacheStatusMetrics names the counter each Get outcome increments.
+var cacheStatusMetrics = map[string]string{
+	cacheHit:          "cache_hits",
+	cacheStale:        "cache_stale",
+	cacheStaleOnError: "cache_stale_on_error",
+	cacheMiss:         "cache_misses",
+}
+
+// Get returns the cached value for key, calling fetch on a miss. If fetch
+// fails but an expired entry is still held, that entry is served instead
+// with cacheStaleOnError so an upstream outage doesn't become an error.
+// fetch is passed ctx on a miss; a background refresh outlives the
+// request, so it keeps ctx's values but not its deadline.
+func (c *Cache) Get(ctx context.Context, key string, fetch func(context.Context) (WeatherData, error)) (WeatherData, string, error) {
+	data, status, err := c.get(ctx, key, fetch)
+	metrics.Inc(cacheStatusMetrics[status])
+	return data, status, err
+}
+
+func (c *Cache) get(ctx context.Context, key string, fetch func(context.Context) (WeatherData, error)) (WeatherData, string, error) {
+	c.mu.Lock()
+	var entry cacheEntry
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+		entry = *el.Value.(*cacheEntry)
+		age := c.now().Sub(entry.fetchedAt)
+		entry.data.FetchedAt, entry.data.ExpiresAt = entry.fetchedAt, entry.fetchedAt.Add(entry.ttl)
+		if age < entry.ttl {
+			c.mu.Unlock()
+			return entry.data, cacheHit, nil
+		}
+		if age < entry.ttl+c.staleTTL {
+			if !c.refreshing[key] {
+				c.refreshing[key] = true
+				go c.refresh(context.WithoutCancel(ctx), key, fetch)
+			}
+			c.mu.Unlock()
+			return entry.data, cacheStale, nil
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := fetch(ctx)
+	if err != nil {
+		if ok && c.maxStaleAge > 0 && c.now().Sub(entry.fetchedAt) > c.maxStaleAge {
+			log.Printf("not serving %q after upstream error: cached copy is older than %s", key, c.maxStaleAge)
+			return WeatherData{}, cacheMiss, err
+		}
+		if ok {
+			log.Printf("serving stale %q after upstream error: %v", key, err)
+			c.mu.Lock()
+			c.lastStaleOnError = c.now()
+			c.mu.Unlock()
+			return entry.data, cacheStaleOnError, nil
+		}
+		return WeatherData{}, cacheMiss, err
+	}
+	c.mu.Lock()
+	c.lastFetchOK = c.now()
+	stored := c.set(key, data)
+	c.mu.Unlock()
+	data.FetchedAt, data.ExpiresAt = stored.fetchedAt, stored.fetchedAt.Add(stored.ttl)
+	return data, cacheMiss, nil
+}
+
+func (c *Cache) Set(key string, data WeatherData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, data)
+}
+
+// set stores data under key and returns the new entry; c.mu must be
+// held.
+func (c *Cache) set(key string, data WeatherData) *cacheEntry {
+	entry := &cacheEntry{key: key, data: data, fetchedAt: c.now(), ttl: c.entryTTL()}
+	if el, ok := c.entries[key]; ok {
+		old := el.Value.(*cacheEntry).data
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		if !reflect.DeepEqual(old, data) {
+			c.notify(key, data)
+		}
+		return entry
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.notify(key, data)
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.remove(oldest)
+		c.evictions++
+	}
+	return entry
+}
+
+// entryTTL is ttl moved by a random amount within ttlJitter of it.
+func (c *Cache) entryTTL() time.Duration {
+	if c.ttlJitter == 0 {
+		return c.ttl
+	}
+	return time.Duration(float64(c.ttl) * (1 + c.ttlJitter*(2*c.rand()-1)))
+}
+
+// ttlRemaining is how long an entry expiring at expiresAt stays fresh,
+// rounded up to whole seconds so a client that waits that long finds it
+// expired rather than about to be.
+func (c *Cache) ttlRemaining(expiresAt time.Time) int {
+	left := expiresAt.Sub(c.now())
+	return max(0, int(math.Ceil(left.Seconds())))
+}
+
+// setCacheHeaders reports how a Get was answered in X-Cache and, for a
+// hit, the seconds until the entry expires in X-Cache-TTL-Remaining.
+// X-Upstream-Status is OpenWeather's status on a miss and "cache" when
+// the data was already held, fresh or stale.
+func (c *Cache) setCacheHeaders(w http.ResponseWriter, status string, data WeatherData) {
+	w.Header().Set("X-Cache", status)
+	if status == cacheHit {
+		w.Header().Set("X-Cache-TTL-Remaining", strconv.Itoa(c.ttlRemaining(data.ExpiresAt)))
+	}
+	switch {
+	case status != cacheMiss:
+		w.Header().Set("X-Upstream-Status", "cache")
+	case data.UpstreamStatus != 0:
+		w.Header().Set("X-Upstream-Status", strconv.Itoa(data.UpstreamStatus))
+	}
+}
+
+// remove drops el from the cache; c.mu must be held.
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *Cache) refresh(ctx context.Context, key string, fetch func(context.Context) (WeatherData, error)) {
+	data, err := fetch(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+	if err != nil {
+		log.Printf("background refresh of %q failed: %v", key, err)
+		return
+	}
+	c.lastFetchOK = c.now()
+	c.set(key, data)
+}
+
+// Watch returns a channel that receives the data stored under key each
+// time it changes, and a function to stop watching. A slow reader only
+// ever sees the latest value.
+func (c *Cache) Watch(key string) (<-chan WeatherData, func()) {
+	ch := make(chan WeatherData, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.watchers[key] == nil {
+		c.watchers[key] = make(map[chan WeatherData]struct{})
+	}
+	c.watchers[key][ch] = struct{}{}
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.watchers[key], ch)
+		if len(c.watchers[key]) == 0 {
+			delete(c.watchers, key)
+		}
+	}
+}
+
+// notify hands data to key's watchers without blocking; c.mu must be held.
+func (c *Cache) notify(key string, data WeatherData) {
+	for ch := range c.watchers[key] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- data
+	}
+}
+
+// ServingStale reports whether the cache has fallen back to stale data
+// since its last successful fetch, i.e. upstream is failing right now.
+func (c *Cache) ServingStale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastStaleOnError.After(c.lastFetchOK)
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Evictions returns how many entries have been dropped to respect
+// maxEntries.
+func (c *Cache) Evictions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
+
+// Clear evicts every entry and returns the number removed.
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.lru.Len()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return n
+}
+
+// ClearCity evicts every entry cached for city, whatever its other key
+// parts, and returns the number removed. A bare city also clears the
+// entries stored under "city,country"; a city with a country only clears
+// that country's.
+func (c *Cache) ClearCity(city string) int {
+	want := cacheKey(city)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, el := range c.entries {
+		name, _, _ := strings.Cut(key, "|")
+		if name == want || !strings.Contains(want, ",") && strings.HasPrefix(name, want+",") {
+			c.remove(el)
+			n++
+		}
+	}
+	return n
+}
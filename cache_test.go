@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Cache.now that tests move forward by hand, safe to read
+// from any goroutine.
+type fakeClock struct{ nanos atomic.Int64 }
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{}
+	c.nanos.Store(time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC).UnixNano())
+	return c
+}
+
+func (c *fakeClock) Now() time.Time          { return time.Unix(0, c.nanos.Load()).UTC() }
+func (c *fakeClock) Advance(d time.Duration) { c.nanos.Add(int64(d)) }
+
+// TestCacheGet checks the status and the fetches for an entry of each age:
+// fresh entries are served, stale ones are served while a refresh runs in
+// the background, and expired ones are fetched again.
+func TestCacheGet(t *testing.T) {
+	const ttl, staleTTL = 10 * time.Minute, 5 * time.Minute
+	tests := []struct {
+		age         time.Duration
+		wantStatus  string
+		wantTemp    float64
+		wantFetches int
+	}{
+		{0, cacheHit, 280, 0},
+		{ttl - time.Second, cacheHit, 280, 0},
+		{ttl, cacheStale, 280, 1},
+		{ttl + staleTTL - time.Second, cacheStale, 280, 1},
+		{ttl + staleTTL, cacheMiss, 290, 1},
+	}
+	for _, tt := range tests {
+		now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+		c := NewCache(ttl, staleTTL, 0)
+		c.now = func() time.Time { return now }
+		cached := WeatherData{Name: "London"}
+		cached.Main.Temp = 280
+		c.Set("london", cached)
+		now = now.Add(tt.age)
+
+		fetches := make(chan struct{}, 2)
+		got, status, err := c.Get(context.Background(), "london", func(context.Context) (WeatherData, error) {
+			fetches <- struct{}{}
+			w := WeatherData{Name: "London"}
+			w.Main.Temp = 290
+			return w, nil
+		})
+		if err != nil || status != tt.wantStatus || got.Main.Temp != tt.wantTemp {
+			t.Errorf("age %s: got %v K, %s, %v; want %v K, %s", tt.age, got.Main.Temp, status, err, tt.wantTemp, tt.wantStatus)
+		}
+		waitForRefreshes(t, c)
+		if n := len(fetches); n != tt.wantFetches {
+			t.Errorf("age %s: %d fetches, want %d", tt.age, n, tt.wantFetches)
+		}
+	}
+}
+
+// TestEntryTTLJitter checks each entry's TTL stays within CACHE_TTL_JITTER
+// of the configured TTL, at both ends of the random source, and that a
+// hit reports the time left on its own TTL.
+func TestEntryTTLJitter(t *testing.T) {
+	const ttl = 10 * time.Minute
+	tests := []struct {
+		jitter, rand float64
+		want         time.Duration
+	}{
+		{0, 0.9, ttl},
+		{0.2, 0, 8 * time.Minute},
+		{0.2, 0.5, ttl},
+		{0.2, 0.999, 12*time.Minute - 240*time.Millisecond},
+		{0.5, 0.25, 7*time.Minute + 30*time.Second},
+	}
+	for _, tt := range tests {
+		c := NewCache(ttl, 0, 0)
+		c.ttlJitter = tt.jitter
+		c.rand = func() float64 { return tt.rand }
+		if got := c.entryTTL(); got != tt.want {
+			t.Errorf("jitter %v, rand %v: entryTTL() = %s, want %s", tt.jitter, tt.rand, got, tt.want)
+		}
+	}
+
+	c := NewCache(ttl, 0, 0)
+	c.ttlJitter = 0.3
+	for range 1000 {
+		if got := c.entryTTL(); got < 7*time.Minute || got > 13*time.Minute {
+			t.Fatalf("entryTTL() = %s, want within 30%% of %s", got, ttl)
+		}
+	}
+
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.rand = func() float64 { return 0 }
+	c.Set("london", WeatherData{Name: "London"})
+	now = now.Add(6 * time.Minute)
+	data, status, _ := c.Get(context.Background(), "london", nil)
+	if status != cacheHit || c.ttlRemaining(data.ExpiresAt) != 60 {
+		t.Errorf("after 6m of a 7m TTL: %s with %ds left, want a hit with 60s", status, c.ttlRemaining(data.ExpiresAt))
+	}
+}
+
+func TestLoadTTLJitter(t *testing.T) {
+	for env, want := range map[string]float64{"": 0, "0": 0, "25": 0.25, "99": 0.99, "100": 0, "-5": 0} {
+		t.Setenv("CACHE_TTL_JITTER", env)
+		if got := loadTTLJitter(); got != want {
+			t.Errorf("CACHE_TTL_JITTER=%q: %v, want %v", env, got, want)
+		}
+	}
+}
+
+// TestCacheGetError checks a failed fetch is returned when nothing is
+// cached, and answered from an expired entry when one is still held.
+func TestCacheGetError(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	c := NewCache(time.Minute, time.Minute, 0)
+	c.now = func() time.Time { return now }
+	failed := errors.New("upstream down")
+	fail := func(context.Context) (WeatherData, error) { return WeatherData{}, failed }
+
+	if _, status, err := c.Get(context.Background(), "london", fail); err != failed || status != cacheMiss {
+		t.Errorf("Get = %s, %v; want %s, %v", status, err, cacheMiss, failed)
+	}
+	if _, ok := c.entries["london"]; ok {
+		t.Error("a failed fetch was cached")
+	}
+
+	c.Set("london", WeatherData{Name: "London"})
+	now = now.Add(time.Hour)
+	got, status, err := c.Get(context.Background(), "london", fail)
+	if err != nil || status != cacheStaleOnError || got.Name != "London" {
+		t.Errorf("Get = %q, %s, %v; want London, %s", got.Name, status, err, cacheStaleOnError)
+	}
+}
+
+// TestCacheMaxStaleAge checks an entry is only served on upstream error
+// while it is younger than maxStaleAge, and that zero lifts the cap.
+func TestCacheMaxStaleAge(t *testing.T) {
+	failed := errors.New("upstream down")
+	fail := func(context.Context) (WeatherData, error) { return WeatherData{}, failed }
+	tests := []struct {
+		maxStale   time.Duration
+		age        time.Duration
+		wantStatus string
+		wantErr    error
+	}{
+		{6 * time.Hour, time.Hour, cacheStaleOnError, nil},
+		{6 * time.Hour, 6 * time.Hour, cacheStaleOnError, nil},
+		{6 * time.Hour, 6*time.Hour + time.Second, cacheMiss, failed},
+		{0, 72 * time.Hour, cacheStaleOnError, nil},
+	}
+	for _, tt := range tests {
+		clock := newFakeClock()
+		c := NewCache(time.Minute, time.Minute, 0)
+		c.now = clock.Now
+		c.maxStaleAge = tt.maxStale
+		c.Set("london", WeatherData{Name: "London"})
+		clock.Advance(tt.age)
+		_, status, err := c.Get(context.Background(), "london", fail)
+		if status != tt.wantStatus || err != tt.wantErr {
+			t.Errorf("max %s, age %s: Get = %s, %v; want %s, %v", tt.maxStale, tt.age, status, err, tt.wantStatus, tt.wantErr)
+		}
+	}
+}
+
+func TestCacheClearCity(t *testing.T) {
+	tests := []struct {
+		city string
+		want []string
+	}{
+		{"London", []string{"paris|metric"}},
+		{" LONDON ", []string{"paris|metric"}},
+		{"London,GB", []string{"london|metric", "london,ca|metric", "paris|metric"}},
+		{"Lond", []string{"london|metric", "london,gb|metric", "london,ca|metric", "paris|metric"}},
+	}
+	for _, tt := range tests {
+		c := NewCache(time.Minute, time.Minute, 0)
+		for _, key := range []string{"london|metric", "london,gb|metric", "london,ca|metric", "paris|metric"} {
+			c.Set(key, WeatherData{})
+		}
+		n := c.ClearCity(tt.city)
+		if n != 4-len(tt.want) || len(c.entries) != len(tt.want) {
+			t.Errorf("ClearCity(%q) cleared %d, left %v; want %v left", tt.city, n, c.entries, tt.want)
+		}
+		for _, key := range tt.want {
+			if _, ok := c.entries[key]; !ok {
+				t.Errorf("ClearCity(%q) removed %q", tt.city, key)
+			}
+		}
+	}
+}
+
+// TestCacheConcurrentAccess hammers one key with Get, Set and refresh
+// while the clock moves it through fresh, stale and expired between
+// rounds. Run it with -race; on top of that, a hit must never be older
+// than the TTL.
+func TestCacheConcurrentAccess(t *testing.T) {
+	const (
+		ttl      = 10 * time.Second
+		staleTTL = 5 * time.Second
+		workers  = 32
+		rounds   = 40
+	)
+	clock := newFakeClock()
+	c := NewCache(ttl, staleTTL, 4)
+	c.now = clock.Now
+
+	// Each value carries the time it was produced in Dt, so a Get can
+	// tell how old the entry it was handed is.
+	var fetches atomic.Int64
+	stamped := func() WeatherData {
+		return WeatherData{Name: "London", Dt: clock.Now().Unix()}
+	}
+	fetch := func(context.Context) (WeatherData, error) {
+		fetches.Add(1)
+		return stamped(), nil
+	}
+	key := cacheKey("London")
+
+	for round := range rounds {
+		var wg sync.WaitGroup
+		for i := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				switch (i + round) % 3 {
+				case 0:
+					c.Set(key, stamped())
+				case 1:
+					c.refresh(context.Background(), key, fetch)
+				}
+				data, status, err := c.Get(context.Background(), key, fetch)
+				if err != nil {
+					t.Errorf("Get: %v", err)
+					return
+				}
+				if data.Name != "London" {
+					t.Errorf("Get returned %q, want London", data.Name)
+					return
+				}
+				if age := clock.Now().Sub(time.Unix(data.Dt, 0)); status == cacheHit && age >= ttl {
+					t.Errorf("HIT for an entry %s old, past the %s TTL", age, ttl)
+				}
+			}()
+		}
+		wg.Wait()
+		waitForRefreshes(t, c)
+		clock.Advance(time.Duration(round%4) * 4 * time.Second)
+	}
+
+	if fetches.Load() == 0 {
+		t.Error("fetch was never called")
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len() = %d, want 1", n)
+	}
+}
+
+// TestCacheExpiryUnderConcurrentGets checks that once an entry is past its
+// stale window, concurrent Gets fetch again rather than serve it.
+func TestCacheExpiryUnderConcurrentGets(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, time.Minute, 0)
+	c.now = clock.Now
+	key := cacheKey("London")
+	c.Set(key, WeatherData{Name: "London"})
+	clock.Advance(3 * time.Minute)
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, status, err := c.Get(context.Background(), key, func(context.Context) (WeatherData, error) { return WeatherData{Name: "London"}, nil })
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			if status != cacheMiss && status != cacheHit {
+				t.Errorf("status = %s for an expired entry, want %s or %s", status, cacheMiss, cacheHit)
+			}
+		}()
+	}
+	wg.Wait()
+	waitForRefreshes(t, c)
+}
+
+// waitForRefreshes waits for background refreshes started by Get, so they
+// don't outlive the test.
+func waitForRefreshes(t *testing.T, c *Cache) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		n := len(c.refreshing)
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d background refreshes still running", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleForecastCalendar shows the daily rollup as a week view, one row
+// per day with its symbol, high and low. ?tz= moves the day boundaries
+// from the city's midnight to that zone's.
+func (s *server) handleForecastCalendar(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseForecastDays(r.URL.Query().Get("days"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	theme, err := parseTheme(r.URL.Query().Get("theme"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	zone, err := parseTZ(r.URL.Query().Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := s.provider.Forecast(r.Context(), city, 0, fetchUnits(units))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if zone == nil {
+		zone = time.FixedZone("", data.City.Timezone)
+	}
+	days := dailyRollupIn(data, zone)
+	if limit > 0 && len(days) > limit {
+		days = days[:limit]
+	}
+	setLocationHeaders(w, data.Location())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(formatCalendar(data, days, units, theme)))
+}
+
+// formatCalendar lines the days up in columns, with the temperatures
+// right-aligned so their digits line up whatever their sign or length.
+func formatCalendar(f ForecastData, days []DailyForecast, units, theme string) string {
+	label := unitSetFor(units).Temp
+	rows := [][]string{{"", "", "High", "Low"}}
+	for _, d := range days {
+		rows = append(rows, []string{
+			d.Date.Format("Mon 02 Jan"),
+			conditionSymbol(theme, d.Condition, 0),
+			formatTemp(convertTemp(d.Max, unitsStandard, units), 1) + label,
+			formatTemp(convertTemp(d.Min, unitsStandard, units), 1) + label,
+		})
+	}
+	rightAligned := []bool{false, false, true, true}
+
+	widths := make([]int, len(rightAligned))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Week at a glance for %s 🌍\n", f.Location())
+	fmt.Fprintf(&b, "==================================\n")
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			pad := strings.Repeat(" ", widths[i]-displayWidth(cell))
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			if rightAligned[i] {
+				line.WriteString(pad + cell)
+			} else {
+				line.WriteString(cell + pad)
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	return b.String()
+}
+
+// displayWidth estimates how many terminal columns s takes. It's only
+// meant for the calendar's cells: pictographs such as 🌈 are two columns,
+// and so is a symbol like ☀ followed by the emoji variation selector,
+// whose rune counts would otherwise differ. text/tabwriter counts runes,
+// which is why the calendar doesn't use it.
+func displayWidth(s string) int {
+	n, prevWide := 0, false
+	for _, r := range s {
+		switch {
+		case r == '\uFE0F':
+			if !prevWide {
+				n++
+			}
+			prevWide = true
+		case r >= 0x1F000:
+			n += 2
+			prevWide = true
+		default:
+			n++
+			prevWide = false
+		}
+	}
+	return n
+}
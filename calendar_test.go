@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	for s, want := range map[string]int{
+		"":           0,
+		"High":       4,
+		"-12.5°C":    7,
+		"☀️":         2,
+		"☁️":         2,
+		"🌧️":         2,
+		"🌈":          2,
+		"[sun]":      5,
+		"Wed 19 Jun": 10,
+	} {
+		if got := displayWidth(s); got != want {
+			t.Errorf("displayWidth(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+// TestFormatCalendarAlignment checks every row comes out the same width,
+// so the right-aligned temperatures line up, with negative, single-digit
+// and Kelvin values under each theme.
+func TestFormatCalendarAlignment(t *testing.T) {
+	day := func(offset int, condition string, minC, maxC float64) DailyForecast {
+		return DailyForecast{
+			Date:      time.Date(2024, 6, 19+offset, 0, 0, 0, 0, time.UTC),
+			Min:       minC + 273.15,
+			Max:       maxC + 273.15,
+			Condition: condition,
+		}
+	}
+	days := []DailyForecast{
+		day(0, "Clear", -12.5, 3),
+		day(1, "Rain", 4, 21.2),
+		day(2, "Clouds", -0.4, 9),
+		day(3, "Volcano", 15, 30),
+	}
+	for _, theme := range []string{"emoji", "ascii", "nerdfont"} {
+		for _, units := range []string{unitsMetric, unitsImperial, unitsStandard} {
+			out := formatCalendar(sampleForecast(), days, units, theme)
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")[2:]
+			if len(lines) != len(days)+1 {
+				t.Fatalf("%s %s: %d rows, want a header and %d days:\n%s", theme, units, len(lines), len(days), out)
+			}
+			for _, line := range lines[1:] {
+				if displayWidth(line) != displayWidth(lines[0]) {
+					t.Errorf("%s %s: rows differ in width:\n%s", theme, units, out)
+					break
+				}
+			}
+		}
+	}
+}
+
+// TestForecastCalendarTZ checks ?tz= moves the day boundaries: London's
+// three steps on 19 June UTC span two days in Auckland.
+func TestForecastCalendarTZ(t *testing.T) {
+	h := newTestServer().routes()
+	for path, want := range map[string][]string{
+		"/forecast/London/calendar":                       {"Wed 19 Jun"},
+		"/forecast/London/calendar?tz=Pacific/Auckland":   {"Wed 19 Jun", "Thu 20 Jun"},
+		"/forecast/London/calendar?units=imperial&days=1": {"Wed 19 Jun"},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || strings.Count(body, " Jun") != len(want) {
+			t.Errorf("GET %s = %d, want %d days:\n%s", path, rec.Code, len(want), body)
+			continue
+		}
+		for _, date := range want {
+			if !strings.Contains(body, date) {
+				t.Errorf("GET %s lacks %s:\n%s", path, date, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsUTF8 reports whether the client's Accept-Charset allows UTF-8.
+// No header means any charset is fine.
+func acceptsUTF8(r *http.Request) bool {
+	header := r.Header.Get("Accept-Charset")
+	if header == "" {
+		return true
+	}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "utf-8" && name != "*" {
+			continue
+		}
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// toASCII replaces every non-ASCII rune with '?', for clients that can't
+// take UTF-8; the plain report is otherwise ASCII already, but city names
+// and descriptions from OpenWeather may not be.
+func toASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > 127 {
+			return '?'
+		}
+		return r
+	}, s)
+}
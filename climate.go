@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// monthlyNormals are a place's average temperature for each month, January
+// first, in °C.
+type monthlyNormals [12]float64
+
+// builtinNormals is a small table of approximate 1991-2020 monthly means,
+// keyed like climateKey. CLIMATE_NORMALS_FILE adds to or replaces it.
+var builtinNormals = map[string]monthlyNormals{
+	"london,gb":   {5.2, 5.3, 7.6, 9.9, 13.3, 16.5, 18.7, 18.5, 15.7, 12.0, 8.0, 5.5},
+	"paris,fr":    {5.0, 5.6, 8.8, 11.6, 15.2, 18.4, 20.6, 20.4, 16.9, 13.0, 8.4, 5.4},
+	"new york,us": {0.5, 1.6, 5.5, 11.6, 17.1, 22.3, 25.3, 24.7, 20.9, 14.8, 9.1, 3.9},
+	"tokyo,jp":    {5.4, 6.1, 9.4, 14.3, 18.8, 21.9, 25.7, 26.9, 23.3, 18.0, 12.5, 7.7},
+	"sydney,au":   {23.1, 23.1, 21.9, 19.4, 16.4, 14.1, 13.3, 14.2, 16.7, 18.7, 20.3, 22.1},
+	"lagos,ng":    {27.3, 28.3, 28.5, 28.0, 27.1, 25.8, 25.1, 25.0, 25.6, 26.3, 27.3, 27.2},
+	"nairobi,ke":  {19.4, 20.2, 20.6, 19.9, 18.8, 17.3, 16.4, 16.9, 18.3, 19.3, 18.9, 18.8},
+}
+
+var climateNormals = loadClimateNormals()
+
+// loadClimateNormals merges CLIMATE_NORMALS_FILE, a JSON object of
+// "city" or "city,CC" to twelve monthly means in °C, over builtinNormals.
+// A file that can't be read is logged and ignored.
+func loadClimateNormals() map[string]monthlyNormals {
+	normals := make(map[string]monthlyNormals, len(builtinNormals))
+	for k, v := range builtinNormals {
+		normals[k] = v
+	}
+	filename := os.Getenv("CLIMATE_NORMALS_FILE")
+	if filename == "" {
+		return normals
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.Printf("ignoring CLIMATE_NORMALS_FILE: %v", err)
+		return normals
+	}
+	var extra map[string]monthlyNormals
+	if err := json.Unmarshal(data, &extra); err != nil {
+		log.Printf("ignoring CLIMATE_NORMALS_FILE %s: %v", filename, err)
+		return normals
+	}
+	for k, v := range extra {
+		city, country, _ := strings.Cut(k, ",")
+		normals[climateKey(city, country)] = v
+	}
+	return normals
+}
+
+// climateKey is the lookup key for a city: the lower-cased name, with
+// ",cc" when the country is known.
+func climateKey(city, country string) string {
+	key := strings.ToLower(strings.Join(strings.Fields(city), " "))
+	if country = strings.TrimSpace(country); country != "" {
+		key += "," + strings.ToLower(country)
+	}
+	return key
+}
+
+// normalsFor finds the city's normals, preferring an entry for its
+// country over one for the bare name.
+func normalsFor(loc Location) (monthlyNormals, bool) {
+	if n, ok := climateNormals[climateKey(loc.Name, loc.Country)]; ok {
+		return n, true
+	}
+	n, ok := climateNormals[climateKey(loc.Name, "")]
+	return n, ok
+}
+
+// seasonalComparison is the current temperature set against the month's
+// average, both in the requested units.
+type seasonalComparison struct {
+	Location    Location `json:"location"`
+	Units       string   `json:"units"`
+	Month       string   `json:"month"`
+	Temperature float64  `json:"temperature"`
+	Average     float64  `json:"average"`
+	Difference  float64  `json:"difference"`
+	Message     string   `json:"message"`
+}
+
+// compareToNormal rounds the difference to a tenth of a degree; anything
+// within half a degree of the average counts as close to it.
+func compareToNormal(w WeatherData, normals monthlyNormals, units string) seasonalComparison {
+	observed := w.localTime(w.Dt)
+	if w.Dt == 0 {
+		observed = time.Now().In(time.FixedZone("", w.Timezone))
+	}
+	month := observed.Month()
+	avgC := normals[month-1]
+	diffC := w.celsius(w.Main.Temp) - avgC
+
+	label := unitSetFor(units).Temp
+	diff := diffC
+	if units == unitsImperial {
+		diff = diffC * 9 / 5
+	}
+	c := seasonalComparison{
+		Location:    w.Location(),
+		Units:       units,
+		Month:       month.String(),
+		Temperature: roundTemp(convertTemp(w.Main.Temp, w.Units, units), 1),
+		Average:     roundTemp(convertTemp(avgC, unitsMetric, units), 1),
+		Difference:  roundTemp(diff, 1),
+	}
+	switch {
+	case math.Abs(diffC) < 0.5:
+		c.Message = fmt.Sprintf("close to the %s average of %s%s", c.Month, formatTemp(c.Average, 1), label)
+	case diffC > 0:
+		c.Message = fmt.Sprintf("%s%s above the %s average", formatTemp(c.Difference, 1), label, c.Month)
+	default:
+		c.Message = fmt.Sprintf("%s%s below the %s average", formatTemp(-c.Difference, 1), label, c.Month)
+	}
+	return c
+}
+
+// handleSeasonalNormal compares the city's current temperature with its
+// average for the month, from climateNormals.
+func (s *server) handleSeasonalNormal(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	upstreamUnits := fetchUnits(units)
+	data, _, err := s.cache.Get(r.Context(), cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, upstreamUnits)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	setLocationHeaders(w, data.Location())
+	normals, ok := normalsFor(data.Location())
+	if !ok {
+		http.Error(w, fmt.Sprintf("no seasonal averages are known for %s", data.Location()), http.StatusNotFound)
+		return
+	}
+	c := compareToNormal(data, normals, units)
+	if r.URL.Query().Get("format") == "json" || wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s: %s\n", c.Location, c.Message)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Suggested background colors by condition, for frontends that theme
+// their UI on the weather. Night shades are darker variants of the day ones.
+var (
+	dayColors = map[string]string{
+		"clear":        "#4A90E2",
+		"clouds":       "#9FB3C8",
+		"rain":         "#5D6D7E",
+		"drizzle":      "#7F8C8D",
+		"thunderstorm": "#34495E",
+		"snow":         "#E8F1F8",
+		"mist":         "#B0BEC5",
+		"fog":          "#B0BEC5",
+	}
+	nightColors = map[string]string{
+		"clear":        "#0B1D3A",
+		"clouds":       "#2C3E50",
+		"rain":         "#1C2833",
+		"drizzle":      "#212F3D",
+		"thunderstorm": "#17202A",
+		"snow":         "#5D6D7E",
+		"mist":         "#424949",
+		"fog":          "#424949",
+	}
+)
+
+const (
+	defaultDayColor   = "#87CEEB"
+	defaultNightColor = "#1B2631"
+)
+
+// colorHint returns a hex color suited to the condition and time of day.
+func colorHint(condition string, isDay bool) string {
+	condition = strings.ToLower(condition)
+	if isDay {
+		if c, ok := dayColors[condition]; ok {
+			return c
+		}
+		return defaultDayColor
+	}
+	if c, ok := nightColors[condition]; ok {
+		return c
+	}
+	return defaultNightColor
+}
+
+// isDaytime reports whether at falls between sunrise and sunset. All
+// three are Unix timestamps.
+func isDaytime(sunrise, sunset, at int64) bool {
+	return at >= sunrise && at < sunset
+}
+
+// IsDay reports whether it was day at the location when the observation
+// was taken. During polar day or night OpenWeather omits sunrise/sunset;
+// the icon code's d/n suffix is used instead, and if that's missing too
+// known is false.
+func (w WeatherData) IsDay() (isDay, known bool) {
+	at := w.Dt
+	if at == 0 {
+		at = time.Now().Unix()
+	}
+	if w.Sys.Sunrise != 0 && w.Sys.Sunset != 0 {
+		return isDaytime(w.Sys.Sunrise, w.Sys.Sunset, at), true
+	}
+	if len(w.Weather) > 0 {
+		switch {
+		case strings.HasSuffix(w.Weather[0].Icon, "d"):
+			return true, true
+		case strings.HasSuffix(w.Weather[0].Icon, "n"):
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// Daylight is the time between sunrise and sunset. In polar day or night,
+// where OpenWeather omits both, it is 24h or 0 going by IsDay, and known
+// is false when even that can't tell.
+func (w WeatherData) Daylight() (d time.Duration, known bool) {
+	if w.Sys.Sunrise != 0 && w.Sys.Sunset != 0 {
+		d = time.Duration(w.Sys.Sunset-w.Sys.Sunrise) * time.Second
+		return min(max(d, 0), 24*time.Hour), true
+	}
+	isDay, known := w.IsDay()
+	if isDay {
+		return 24 * time.Hour, known
+	}
+	return 0, known
+}
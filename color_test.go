@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColorHint(t *testing.T) {
+	tests := []struct {
+		condition string
+		day       bool
+		want      string
+	}{
+		{"Clear", true, "#4A90E2"},
+		{"Clear", false, "#0B1D3A"},
+		{"RAIN", true, "#5D6D7E"},
+		{"rain", false, "#1C2833"},
+		{"Fog", true, "#B0BEC5"},
+		{"Tornado", true, defaultDayColor},
+		{"Tornado", false, defaultNightColor},
+		{"", true, defaultDayColor},
+	}
+	for _, tt := range tests {
+		if got := colorHint(tt.condition, tt.day); got != tt.want {
+			t.Errorf("colorHint(%q, day=%v) = %s, want %s", tt.condition, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestIsDaytime(t *testing.T) {
+	const sunrise, sunset = 1000, 2000
+	for at, want := range map[int64]bool{999: false, 1000: true, 1500: true, 1999: true, 2000: false} {
+		if got := isDaytime(sunrise, sunset, at); got != want {
+			t.Errorf("isDaytime at %d = %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestDaylight(t *testing.T) {
+	polar := func(icon string) WeatherData {
+		w := sampleWeather()
+		w.Sys.Sunrise, w.Sys.Sunset = 0, 0
+		w.Weather[0].Icon = icon
+		return w
+	}
+	tests := []struct {
+		name      string
+		w         WeatherData
+		want      time.Duration
+		wantKnown bool
+	}{
+		{"London in June", sampleWeather(), 59960 * time.Second, true},
+		{"polar day", polar("01d"), 24 * time.Hour, true},
+		{"polar night", polar("01n"), 0, true},
+		{"no sun times or icon", polar(""), 0, false},
+	}
+	for _, tt := range tests {
+		if got, known := tt.w.Daylight(); got != tt.want || known != tt.wantKnown {
+			t.Errorf("%s: Daylight() = %s, %v; want %s, %v", tt.name, got, known, tt.want, tt.wantKnown)
+		}
+	}
+}
+
+func TestFormatDaylight(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                            "0h 00m",
+		59960 * time.Second:          "16h 39m",
+		9*time.Hour + 42*time.Minute: "9h 42m",
+		24 * time.Hour:               "24h 00m",
+		90 * time.Second:             "0h 02m",
+	} {
+		if got := formatDaylight(d); got != want {
+			t.Errorf("formatDaylight(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipLevel is GZIP_LEVEL, from gzip.BestSpeed (1) to gzip.BestCompression
+// (9); 0 turns compression off. Higher levels shrink the larger forecast
+// and batch responses further at the cost of CPU.
+var gzipLevel = loadGzipLevel()
+
+const defaultGzipLevel = 6
+
+func loadGzipLevel() int {
+	level := envInt("GZIP_LEVEL", defaultGzipLevel)
+	if level != gzip.NoCompression && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+		log.Printf("ignoring GZIP_LEVEL=%d: expected 0 (off) or %d..%d", level, gzip.BestSpeed, gzip.BestCompression)
+		return defaultGzipLevel
+	}
+	return level
+}
+
+// gzipWriters reuses writers at gzipLevel, which are costly to allocate.
+var gzipWriters = sync.Pool{New: func() any {
+	gz, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+	return gz
+}}
+
+// withCompression gzips responses for clients that accept it. Event
+// streams, images other than SVG and bodies a handler already encoded
+// are sent as they are.
+func withCompression(next http.Handler) http.Handler {
+	if gzipLevel == gzip.NoCompression {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip without q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+func compressible(code int, h http.Header) bool {
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return false
+	case strings.HasPrefix(contentType, "image/"):
+		return strings.HasPrefix(contentType, "image/svg+xml")
+	}
+	return true
+}
+
+// gzipWriter decides on the first WriteHeader or Write whether to compress,
+// once the handler has set its headers.
+type gzipWriter struct {
+	http.ResponseWriter
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if gw.decided {
+		return
+	}
+	gw.decided = true
+	if h := gw.Header(); compressible(code, h) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		gw.gz = gzipWriters.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *gzipWriter) Write(b []byte) (int, error) {
+	if !gw.decided {
+		// Sniff now, as net/http would, rather than from compressed bytes.
+		if gw.Header().Get("Content-Type") == "" {
+			gw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+	return gw.gz.Write(b)
+}
+
+// Flush sends what has been compressed so far.
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+// Close finishes the gzip stream and returns the writer to the pool.
+func (gw *gzipWriter) Close() {
+	if gw.gz == nil {
+		return
+	}
+	gw.gz.Close()
+	gzipWriters.Put(gw.gz)
+	gw.gz = nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
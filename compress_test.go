@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// fullForecastJSON is the JSON forecast for London over five days of
+// three-hourly steps, the size of the responses GZIP_LEVEL is for.
+func fullForecastJSON() []byte {
+	f := sampleForecast()
+	steps := f.List
+	f.List = nil
+	for i := range 40 {
+		e := steps[i%len(steps)]
+		e.Dt = steps[0].Dt + int64(i)*3*3600
+		e.Main.Temp += float64(i%7) / 10
+		f.List = append(f.List, e)
+	}
+	f.Cnt = len(f.List)
+	return []byte(f.FormatJSON(unitsMetric))
+}
+
+// BenchmarkGzipLevel compresses a full forecast at each GZIP_LEVEL and
+// reports the compressed size alongside the time, to weigh one against the
+// other when picking a level.
+func BenchmarkGzipLevel(b *testing.B) {
+	body := fullForecastJSON()
+	for level := gzip.BestSpeed; level <= gzip.BestCompression; level++ {
+		b.Run(fmt.Sprintf("level=%d", level), func(b *testing.B) {
+			gz, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var out bytes.Buffer
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for range b.N {
+				out.Reset()
+				gz.Reset(&out)
+				gz.Write(body)
+				gz.Close()
+			}
+			b.ReportMetric(float64(out.Len()), "gzip-bytes")
+			b.ReportMetric(float64(out.Len())/float64(len(body)), "ratio")
+		})
+	}
+}
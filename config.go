@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", name, raw, err)
+		return def
+	}
+	return n
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", name, raw, err)
+		return def
+	}
+	return d
+}
+
+// envList splits a comma-separated variable, dropping empty items.
+func envList(name string, def []string) []string {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
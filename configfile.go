@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeConfig parses a config file by extension: .yaml/.yml and .toml use
+// flat "key: value" and "key = value" lines, anything else (including the
+// default .apiConfig) is JSON. Keys are the same as the JSON field names.
+func decodeConfig(filename string, data []byte, v *ApiConfigData) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return decodeFlatConfig(data, ":", v)
+	case ".toml":
+		return decodeFlatConfig(data, "=", v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeFlatConfig handles the subset of YAML and TOML a config file with
+// top-level scalar values needs. Nested maps, tables and unknown keys are
+// rejected rather than silently ignored.
+func decodeFlatConfig(data []byte, sep string, v *ApiConfigData) error {
+	fields := make(map[string]reflect.Value)
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("json"), ",")
+		fields[name] = rv.Field(i)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripConfigComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return fmt.Errorf("line %d: tables are not supported", n)
+		}
+		key, value, found := strings.Cut(line, sep)
+		if !found {
+			return fmt.Errorf("line %d: expected key%svalue", n, sep)
+		}
+		key, err := unquoteConfigValue(strings.TrimSpace(key))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return fmt.Errorf("line %d: %s has no value; nested values are not supported", n, key)
+		}
+		if value, err = unquoteConfigValue(value); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %q", n, key)
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+		}
+	}
+	return scanner.Err()
+}
+
+// unquoteConfigValue strips the double or single quotes around s, if any.
+func unquoteConfigValue(s string) (string, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	if s[0] == '"' {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("malformed quoted value %s", s)
+		}
+		return unquoted, nil
+	}
+	if len(s) < 2 || s[len(s)-1] != '\'' {
+		return "", fmt.Errorf("malformed quoted value %s", s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// stripConfigComment drops a trailing # comment that isn't inside quotes.
+func stripConfigComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	tests := []struct {
+		name, file, data string
+		want             string
+		wantErr          string
+	}{
+		{"json", ".apiConfig", `{"OpenWeatherApiKey": "abc"}`, "abc", ""},
+		{"yaml bare", "c.yaml", "OpenWeatherApiKey: abc\n", "abc", ""},
+		{"yaml double quoted", "c.yml", "---\nOpenWeatherApiKey: \"a#b\"\n", "a#b", ""},
+		{"yaml single quoted", "c.yaml", "OpenWeatherApiKey: 'abc' # the key\n", "abc", ""},
+		{"yaml comments and blanks", "c.yaml", "# config\n\n  # indented comment\nOpenWeatherApiKey: abc # trailing\n", "abc", ""},
+		{"toml", "c.toml", "OpenWeatherApiKey = \"abc\"\n", "abc", ""},
+		{"toml quoted key", "c.toml", "\"OpenWeatherApiKey\" = 'abc'\n", "abc", ""},
+		{"toml comment", "c.TOML", "# config\nOpenWeatherApiKey = \"abc\" # trailing\n", "abc", ""},
+		{"yaml nested", "c.yaml", "openweather:\n  OpenWeatherApiKey: abc\n", "", "line 1: openweather has no value"},
+		{"toml table", "c.toml", "[openweather]\nOpenWeatherApiKey = \"abc\"\n", "", "line 1: tables are not supported"},
+		{"yaml unknown key", "c.yaml", "OpenWeatherApiKey: abc\nOpenWeatherAPIKey: def\n", "", `line 2: unknown key "OpenWeatherAPIKey"`},
+		{"toml unknown key", "c.toml", "api_key = \"abc\"\n", "", `line 1: unknown key "api_key"`},
+		{"toml wrong separator", "c.toml", "OpenWeatherApiKey: abc\n", "", "line 1: expected key=value"},
+		{"yaml unterminated quote", "c.yaml", "OpenWeatherApiKey: \"abc\n", "", "line 1: malformed quoted value"},
+		{"malformed json", ".apiConfig", `{"OpenWeatherApiKey": `, "", "unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		var got ApiConfigData
+		err := decodeConfig(tt.file, []byte(tt.data), &got)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.OpenWeatherApiKey != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got.OpenWeatherApiKey, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxCountryCities caps how many cities /weather/country/{code} reports,
+// whatever ?limit= asks for.
+var maxCountryCities = max(1, envInt("COUNTRY_MAX_CITIES", 10))
+
+// builtinCountryCities lists a few major cities per country, largest
+// first. COUNTRY_CITIES_FILE adds countries or replaces their lists.
+var builtinCountryCities = map[string][]string{
+	"AU": {"Sydney", "Melbourne", "Brisbane", "Perth", "Adelaide", "Canberra", "Hobart", "Darwin"},
+	"BR": {"São Paulo", "Rio de Janeiro", "Brasília", "Salvador", "Fortaleza", "Belo Horizonte", "Manaus", "Curitiba"},
+	"CA": {"Toronto", "Montreal", "Vancouver", "Calgary", "Edmonton", "Ottawa", "Winnipeg", "Quebec City"},
+	"DE": {"Berlin", "Hamburg", "Munich", "Cologne", "Frankfurt", "Stuttgart", "Düsseldorf", "Leipzig"},
+	"ES": {"Madrid", "Barcelona", "Valencia", "Seville", "Zaragoza", "Málaga", "Bilbao", "Palma"},
+	"FR": {"Paris", "Marseille", "Lyon", "Toulouse", "Nice", "Nantes", "Strasbourg", "Bordeaux"},
+	"GB": {"London", "Birmingham", "Manchester", "Glasgow", "Leeds", "Liverpool", "Edinburgh", "Bristol", "Cardiff", "Belfast"},
+	"IN": {"Mumbai", "Delhi", "Bengaluru", "Hyderabad", "Ahmedabad", "Chennai", "Kolkata", "Pune"},
+	"IT": {"Rome", "Milan", "Naples", "Turin", "Palermo", "Genoa", "Bologna", "Florence"},
+	"JP": {"Tokyo", "Yokohama", "Osaka", "Nagoya", "Sapporo", "Fukuoka", "Kobe", "Kyoto"},
+	"KE": {"Nairobi", "Mombasa", "Kisumu", "Nakuru", "Eldoret"},
+	"MX": {"Mexico City", "Guadalajara", "Monterrey", "Puebla", "Tijuana", "León", "Mérida", "Cancún"},
+	"NG": {"Lagos", "Kano", "Ibadan", "Abuja", "Port Harcourt", "Benin City", "Kaduna", "Enugu"},
+	"NZ": {"Auckland", "Wellington", "Christchurch", "Hamilton", "Tauranga", "Dunedin"},
+	"US": {"New York", "Los Angeles", "Chicago", "Houston", "Phoenix", "Philadelphia", "San Antonio", "San Diego", "Dallas", "San Francisco", "Seattle", "Miami"},
+	"ZA": {"Johannesburg", "Cape Town", "Durban", "Pretoria", "Port Elizabeth", "Bloemfontein"},
+}
+
+var countryCities = loadCountryCities()
+
+// loadCountryCities merges COUNTRY_CITIES_FILE, a JSON object of country
+// code to city names, over builtinCountryCities. A file that can't be
+// read is logged and ignored.
+func loadCountryCities() map[string][]string {
+	cities := make(map[string][]string, len(builtinCountryCities))
+	for k, v := range builtinCountryCities {
+		cities[k] = v
+	}
+	filename := os.Getenv("COUNTRY_CITIES_FILE")
+	if filename == "" {
+		return cities
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.Printf("ignoring COUNTRY_CITIES_FILE: %v", err)
+		return cities
+	}
+	var extra map[string][]string
+	if err := json.Unmarshal(data, &extra); err != nil {
+		log.Printf("ignoring COUNTRY_CITIES_FILE %s: %v", filename, err)
+		return cities
+	}
+	for k, v := range extra {
+		cities[strings.ToUpper(k)] = v
+	}
+	return cities
+}
+
+// countryCityResult is one city's outcome; a failed city carries Error and
+// Status instead of Weather so the others are still returned.
+type countryCityResult struct {
+	City    string          `json:"city"`
+	Weather json.RawMessage `json:"weather,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Status  int             `json:"status,omitempty"`
+
+	data WeatherData
+}
+
+// handleCountryWeather reports the current weather in the country's major
+// cities, fetched by batchWorkers workers through the cache, as a JSON
+// array in list order or, with ?format=text, as a table. Failed cities are
+// reported inline.
+func (s *server) handleCountryWeather(w http.ResponseWriter, r *http.Request) {
+	code, format := splitFormatSuffix(r.PathValue("name"))
+	code = strings.ToUpper(code)
+	if len(code) != 2 || !isASCIILetters(code) {
+		http.Error(w, fmt.Sprintf("invalid country code %q: expected two letters", stripControl(code)), http.StatusBadRequest)
+		return
+	}
+	cities := countryCities[code]
+	if len(cities) == 0 {
+		http.Error(w, fmt.Sprintf("no major cities are known for %s", code), http.StatusNotFound)
+		return
+	}
+	limit := maxCountryCities
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q: expected a positive integer", raw), http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCountryCities)
+	}
+	cities = cities[:min(len(cities), limit)]
+	if format == "" {
+		format = r.URL.Query().Get("format")
+	}
+	if format != "" && format != "json" && format != "text" {
+		http.Error(w, fmt.Sprintf("unsupported format %q: expected json or text", format), http.StatusBadRequest)
+		return
+	}
+	units := requestUnits(r)
+	formatter, err := selectFormatter(units, "json", ReportOptions{
+		Advice: r.URL.Query().Get("advice") == "true",
+		Round:  queryBool(r, "round", false),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upstreamUnits := fetchUnits(units)
+	ctx, cancel := batchContext(r)
+	defer cancel()
+	results, done := collectUntil(ctx, len(cities), batchWorkers, func(ctx context.Context, i int) (res countryCityResult) {
+		res.City = cities[i]
+		city, err := parseCityQuery(cities[i] + "," + code)
+		if err != nil {
+			res.Error, res.Status = err.Error(), cityErrorStatus(err)
+			return res
+		}
+		data, _, err := s.cache.Get(ctx, cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+			return s.fetchCurrent(ctx, city, upstreamUnits)
+		})
+		if err != nil {
+			res.Error, res.Status = err.Error(), queryErrorStatus(err)
+			return res
+		}
+		res.data = data
+		res.Weather = json.RawMessage(formatter.Format(data))
+		return res
+	})
+	timedOut := 0
+	for i := range results {
+		if !done[i] {
+			results[i] = countryCityResult{City: cities[i], Error: errBatchTimedOut.Error(), Status: http.StatusGatewayTimeout}
+			timedOut++
+		}
+	}
+	if timedOut > 0 {
+		w.Header().Set("Warning", fmt.Sprintf(`199 - "%d of %d cities timed out"`, timedOut, len(results)))
+	}
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(countryTable(results, units, queryBool(r, "round", false))))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// countryTable lines the cities up in columns, one per row, with a failed
+// city's error in place of its readings.
+func countryTable(results []countryCityResult, units string, round bool) string {
+	labels := unitSetFor(units)
+	temp := ReportOptions{Round: round}.temp
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "City\tTemp\tFeels like\tHumidity\tWind\tCondition")
+	for _, res := range results {
+		if res.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\terror: %s\n", stripControl(res.City), res.Error)
+			continue
+		}
+		d := res.data
+		tempText := func(v float64, known bool) string {
+			if !known {
+				return notAvailable
+			}
+			return temp(convertTemp(v, d.Units, units)) + labels.Temp
+		}
+		feels, _ := d.feelsLike()
+		condition := d.condition().Description
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d%%\t%s\t%s\n", stripControl(d.Location().Name),
+			tempText(d.Main.Temp, d.tempKnown(d.Main.Temp)), tempText(feels, d.feelsLikeKnown()),
+			d.Main.Humidity, d.windText(units, labels.Speed), condition)
+	}
+	tw.Flush()
+	return b.String()
+}
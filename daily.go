@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxForecastDays is how far ahead the free 5 day forecast reaches.
+const maxForecastDays = 5
+
+// parseForecastDays validates the optional days parameter. Zero means every
+// day in the forecast; values past maxForecastDays are clamped to it.
+func parseForecastDays(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid days %q: expected an integer between 1 and %d", raw, maxForecastDays)
+	}
+	return min(days, maxForecastDays), nil
+}
+
+// DailyForecast rolls the 3-hour forecast steps of one local calendar day
+// into a single summary. Temperatures are in Kelvin whatever units the
+// ForecastData was fetched in.
+type DailyForecast struct {
+	Date      time.Time
+	Min       float64
+	Max       float64
+	Avg       float64
+	Condition string
+}
+
+// dailyRollup groups forecast entries by date in the city's timezone and
+// computes each day's min, max, average and most frequent condition.
+func dailyRollup(f ForecastData) []DailyForecast {
+	return dailyRollupIn(f, time.FixedZone("", f.City.Timezone))
+}
+
+// dailyRollupIn is dailyRollup with days running midnight to midnight in
+// zone instead.
+func dailyRollupIn(f ForecastData, zone *time.Location) []DailyForecast {
+	var days []DailyForecast
+	var sum float64
+	var n int
+	var counts map[string]int
+	var order []string
+
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		d := &days[len(days)-1]
+		d.Avg = sum / float64(n)
+		best := 0
+		// Ties go to the condition seen first in the day.
+		for _, c := range order {
+			if counts[c] > best {
+				best, d.Condition = counts[c], c
+			}
+		}
+	}
+
+	for _, e := range f.List {
+		t := time.Unix(e.Dt, 0).In(zone)
+		date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, zone)
+		temp, tempMin, tempMax := f.kelvin(e.Main.Temp), f.kelvin(e.Main.TempMin), f.kelvin(e.Main.TempMax)
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			flush()
+			days = append(days, DailyForecast{Date: date, Min: tempMin, Max: tempMax})
+			sum, n = 0, 0
+			counts, order = make(map[string]int), nil
+		}
+		d := &days[len(days)-1]
+		d.Min = min(d.Min, tempMin, temp)
+		d.Max = max(d.Max, tempMax, temp)
+		sum += temp
+		n++
+		if len(e.Weather) > 0 {
+			c := e.Weather[0].Main
+			if counts[c] == 0 {
+				order = append(order, c)
+			}
+			counts[c]++
+		}
+	}
+	flush()
+	return days
+}
+
+// tempLabels are the temperature suffixes shown for each unit system.
+var tempLabels = map[string]string{unitsMetric: "°C", unitsImperial: "°F", unitsStandard: " K"}
+
+// formatDailyForecast renders the rollup as text, with temperatures in
+// units.
+func formatDailyForecast(f ForecastData, days []DailyForecast, units string) string {
+	var output strings.Builder
+
+	temp := func(k float64) string {
+		return formatTemp(convertTemp(k, unitsStandard, units), 1) + tempLabels[units]
+	}
+	fmt.Fprintf(&output, "Daily Forecast for %s 🌍\n", f.Location())
+	fmt.Fprintf(&output, "==================================\n")
+	for _, d := range days {
+		fmt.Fprintf(&output, "%s  min %s  max %s  avg %s  %s %s\n",
+			d.Date.Format("Mon 02 Jan"), temp(d.Min), temp(d.Max), temp(d.Avg),
+			conditionSymbol(defaultTheme, d.Condition, 0), d.Condition)
+	}
+
+	return output.String()
+}
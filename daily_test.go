@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyForecastUnits(t *testing.T) {
+	days := []DailyForecast{{Date: time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC), Min: 283.15, Max: 293.15, Avg: 288.15, Condition: "Clouds"}}
+	for units, want := range map[string]string{
+		unitsMetric:   "Wed 19 Jun  min 10.0°C  max 20.0°C  avg 15.0°C",
+		unitsImperial: "Wed 19 Jun  min 50.0°F  max 68.0°F  avg 59.0°F",
+		unitsStandard: "Wed 19 Jun  min 283.2 K  max 293.2 K  avg 288.2 K",
+	} {
+		if got := formatDailyForecast(sampleForecast(), days, units); !strings.Contains(got, want) {
+			t.Errorf("%s: text lacks %q:\n%s", units, want, got)
+		}
+	}
+}
+
+func TestDailyEndpointUnits(t *testing.T) {
+	h := newTestServer().routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/forecast/London/daily"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "°C") {
+		t.Errorf("daily: got %d, want °C by default:\n%s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/forecast/London/daily?units=imperial"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "°F") || strings.Contains(rec.Body.String(), "°C") {
+		t.Errorf("daily ?units=imperial: got %d, want °F only:\n%s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/forecast/London/daily?units=rankine"); rec.Code != http.StatusBadRequest {
+		t.Errorf("daily ?units=rankine: got %d, want 400", rec.Code)
+	}
+}
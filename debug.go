@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// configReporter is implemented by providers that can describe their own
+// settings for /debug/config.
+type configReporter interface {
+	debugConfig() map[string]any
+}
+
+// debugConfig reports the client's resolved settings. Keys are only
+// counted; the proxy URL has any password masked.
+func (c *WeatherClient) debugConfig() map[string]any {
+	keys := 0
+	state := c.keys.Load()
+	if state.err == nil {
+		keys = len(state.pool.keys)
+	}
+	proxy := ""
+	if raw := os.Getenv("UPSTREAM_PROXY"); raw != "" {
+		if u, err := url.Parse(raw); err == nil {
+			proxy = u.Redacted()
+		}
+	}
+	transport, _ := c.httpClient.Transport.(*http.Transport)
+	cfg := map[string]any{
+		"endpoints":             c.endpoints,
+		"api_keys_loaded":       keys,
+		"key_bench_duration":    keyBenchDuration.String(),
+		"concurrency":           cap(c.slots),
+		"retries":               c.retry.maxRetries,
+		"retry_backoff":         c.retry.backoff.String(),
+		"decode_retries":        c.retry.decodeRetries,
+		"breaker_threshold":     c.breaker.threshold,
+		"breaker_cooldown":      c.breaker.cooldown.String(),
+		"proxy":                 proxy,
+		"request_timeout":       c.httpClient.Timeout.String(),
+		"tile_timeout":          c.tileLimits.timeout.String(),
+		"tile_max_bytes":        c.tileLimits.maxBytes,
+		"onecall_enabled":       oneCallEnabled,
+		"strict_sanity":         strictSanity,
+		"absolute_zero_guard":   absoluteZeroGuard,
+		"always_fetch_standard": alwaysFetchStandard,
+	}
+	if transport != nil {
+		cfg["idle_conn_timeout"] = transport.IdleConnTimeout.String()
+		cfg["max_idle_conns"] = transport.MaxIdleConns
+		cfg["max_idle_conns_per_host"] = transport.MaxIdleConnsPerHost
+	}
+	return cfg
+}
+
+// conversionDebug lays the values as fetched next to what a report in
+// units shows for them, to spot a missing or doubled unit conversion.
+func conversionDebug(data WeatherData, units string, formatter Formatter) map[string]any {
+	if units == "" {
+		units = unitsMetric
+	}
+	feels, _ := data.feelsLike()
+	return map[string]any{
+		"upstream_units": data.Units,
+		"display_units":  units,
+		"raw": map[string]float64{
+			"temp":       data.Main.Temp,
+			"feels_like": data.Main.FeelsLike,
+			"temp_min":   data.Main.TempMin,
+			"temp_max":   data.Main.TempMax,
+			"wind_speed": data.Wind.Speed,
+		},
+		"converted": map[string]float64{
+			"temp":       convertTemp(data.Main.Temp, data.Units, units),
+			"feels_like": convertTemp(feels, data.Units, units),
+			"temp_min":   convertTemp(data.Main.TempMin, data.Units, units),
+			"temp_max":   convertTemp(data.Main.TempMax, data.Units, units),
+			"wind_speed": data.windSpeed(units),
+			"celsius":    data.celsius(data.Main.Temp),
+			"fahrenheit": data.fahrenheit(data.Main.Temp),
+			"kelvin":     data.kelvin(data.Main.Temp),
+		},
+		"report": formatter.Format(data),
+	}
+}
+
+// handleDebugConfig shows the configuration that actually took effect
+// after env and file values were applied. It sits behind requireServerKey.
+func (s *server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := map[string]any{
+		"addr":              listenAddr,
+		"default_units":     defaultUnits,
+		"cache_ttl":         s.cache.ttl.String(),
+		"cache_stale_ttl":   s.cache.staleTTL.String(),
+		"cache_ttl_jitter":  s.cache.ttlJitter,
+		"cache_max_entries": s.cache.maxEntries,
+		"max_stale_age":     s.cache.maxStaleAge.String(),
+		"tile_cache_ttl":    tileCacheTTL.String(),
+		"time_format":       clockLayout,
+		"emoji":             emojiByDefault,
+		"symbol_theme":      defaultTheme,
+		"attribution":       attributionEnabled,
+		"city_suggestions":  citySuggestionsEnabled,
+		"public_base_url":   publicBaseFallback,
+		"json_naming":       jsonNaming,
+		"log_level_debug":   debugLogging,
+		"gzip_level":        gzipLevel,
+	}
+	if rep, ok := s.provider.(configReporter); ok {
+		cfg["upstream"] = rep.debugConfig()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// TemperatureDelta is the expected temperature change between the first
+// forecast step and the one closest to 24 hours later. Temperatures are °C.
+type TemperatureDelta struct {
+	Location Location `json:"location"`
+	From     float64  `json:"from"`
+	To       float64  `json:"to"`
+	Delta    float64  `json:"delta"`
+	Hours    float64  `json:"hours"`
+	Trend    string   `json:"trend"`
+}
+
+var errSparseForecast = errors.New("not enough forecast data to compute a trend")
+
+// forecastDelta compares the first forecast step with the one closest to
+// 24h later. With a short forecast (e.g. a small cnt) the last step is
+// used and Hours says how far ahead it is.
+func forecastDelta(f ForecastData) (TemperatureDelta, error) {
+	if len(f.List) < 2 {
+		return TemperatureDelta{}, errSparseForecast
+	}
+	first := f.List[0]
+	target := first.Dt + int64((24 * time.Hour).Seconds())
+	best := f.List[1]
+	for _, e := range f.List[1:] {
+		if abs64(e.Dt-target) < abs64(best.Dt-target) {
+			best = e
+		}
+	}
+	d := TemperatureDelta{
+		Location: f.Location(),
+		From:     f.celsius(first.Main.Temp),
+		To:       f.celsius(best.Main.Temp),
+		Hours:    float64(best.Dt-first.Dt) / 3600,
+	}
+	d.Delta = d.To - d.From
+	// Changes under half a degree are within forecast noise.
+	switch {
+	case d.Delta >= 0.5:
+		d.Trend = "warming"
+	case d.Delta <= -0.5:
+		d.Trend = "cooling"
+	default:
+		d.Trend = "steady"
+	}
+	return d, nil
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (d TemperatureDelta) String() string {
+	if d.Trend == "steady" {
+		return fmt.Sprintf("Temperature in %s should stay around %s°C over the next %.0fh.\n", d.Location, formatTemp(d.From, 1), d.Hours)
+	}
+	verb := "rise"
+	if d.Trend == "cooling" {
+		verb = "fall"
+	}
+	return fmt.Sprintf("Temperature in %s is expected to %s by %s°C over the next %.0fh (%s°C → %s°C).\n",
+		d.Location, verb, formatTemp(math.Abs(d.Delta), 1), d.Hours, formatTemp(d.From, 1), formatTemp(d.To, 1))
+}
+
+func (s *server) handleForecastDelta(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	data, err := s.provider.Forecast(r.Context(), city, 0, fetchUnits(""))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	delta, err := forecastDelta(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	setLocationHeaders(w, delta.Location)
+	if r.URL.Query().Get("format") == "json" || wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(delta)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(delta.String()))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// weatherDetail gathers every value we derive from an observation. Units
+// are fixed, °C and m/s, so the field names can say what they hold.
+type weatherDetail struct {
+	Location           Location `json:"location"`
+	ObservedUnix       int64    `json:"observed_unix,omitempty"`
+	Condition          string   `json:"condition,omitempty"`
+	Description        string   `json:"description,omitempty"`
+	TemperatureC       float64  `json:"temperature_c"`
+	FeelsLikeC         float64  `json:"feels_like_c"`
+	FeelsLikeEstimated bool     `json:"feels_like_estimated,omitempty"`
+	// HeatIndexC is only set where the NWS formula applies: at least
+	// 80°F and 40% humidity.
+	HeatIndexC      *float64 `json:"heat_index_c,omitempty"`
+	DewPointC       *float64 `json:"dew_point_c,omitempty"`
+	Humidity        int      `json:"humidity"`
+	Comfort         string   `json:"comfort,omitempty"`
+	WindSpeedMS     *float64 `json:"wind_speed_ms"`
+	WindDeg         *int     `json:"wind_deg"`
+	WindCompass     string   `json:"wind_compass,omitempty"`
+	IsDay           *bool    `json:"is_day"`
+	DaylightSeconds *int64   `json:"daylight_seconds,omitempty"`
+	Daylight        string   `json:"daylight,omitempty"`
+	UVI             *float64 `json:"uvi,omitempty"`
+	UVIRisk         string   `json:"uvi_risk,omitempty"`
+	Warnings        []string `json:"warnings,omitempty"`
+	Advice          string   `json:"advice,omitempty"`
+	DataAge         string   `json:"data_age,omitempty"`
+	PossiblyStale   bool     `json:"possibly_stale,omitempty"`
+}
+
+// dewPoint is the Magnus approximation in °C, good to a few tenths of a
+// degree over normal weather. It is undefined at 0% humidity.
+func dewPoint(tempC, humidity float64) (float64, bool) {
+	if humidity <= 0 {
+		return 0, false
+	}
+	const a, b = 17.62, 243.12
+	g := math.Log(humidity/100) + a*tempC/(b+tempC)
+	return b * g / (a - g), true
+}
+
+// comfortLabel describes how humid the air feels from its dew point, on
+// the scale the NWS uses for summer forecasts.
+func comfortLabel(dewC float64) string {
+	switch {
+	case dewC < 10:
+		return "dry"
+	case dewC < 16:
+		return "comfortable"
+	case dewC < 18:
+		return "slightly humid"
+	case dewC < 21:
+		return "humid"
+	case dewC < 24:
+		return "muggy"
+	}
+	return "oppressive"
+}
+
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// compassDirection names the 16-point compass direction for a bearing.
+func compassDirection(deg int) string {
+	i := int(math.Round(float64(((deg%360)+360)%360)/22.5)) % len(compassPoints)
+	return compassPoints[i]
+}
+
+func newWeatherDetail(w WeatherData, now time.Time) weatherDetail {
+	r := func(v float64) float64 { return roundTemp(v, 1) }
+	tempC := w.celsius(w.Main.Temp)
+	feels, estimated := w.feelsLike()
+	d := weatherDetail{
+		Location:           w.Location(),
+		ObservedUnix:       w.Dt,
+		TemperatureC:       r(tempC),
+		FeelsLikeC:         r(w.celsius(feels)),
+		FeelsLikeEstimated: estimated,
+		Humidity:           w.Main.Humidity,
+		Warnings:           thresholds.warnings(tempC, "°"),
+		Advice:             recommend(w),
+	}
+	c := w.condition()
+	d.Condition, d.Description = c.Main, c.Description
+	if !w.NoWind {
+		speed := r(w.windSpeed(unitsMetric))
+		d.WindSpeedMS, d.WindDeg = &speed, &w.Wind.Deg
+		d.WindCompass = compassDirection(w.Wind.Deg)
+	}
+	if tempF, rh := w.fahrenheit(w.Main.Temp), float64(w.Main.Humidity); tempF >= 80 && rh >= 40 {
+		hi := r((heatIndex(tempF, rh) - 32) * 5 / 9)
+		d.HeatIndexC = &hi
+	}
+	if dew, ok := dewPoint(tempC, float64(w.Main.Humidity)); ok {
+		dew = r(dew)
+		d.DewPointC, d.Comfort = &dew, comfortLabel(dew)
+	}
+	if isDay, known := w.IsDay(); known {
+		d.IsDay = &isDay
+	}
+	if daylight, ok := w.Daylight(); ok {
+		secs := int64(daylight.Seconds())
+		d.DaylightSeconds, d.Daylight = &secs, formatDaylight(daylight)
+	}
+	if w.HasUVI {
+		d.UVI, d.UVIRisk = &w.UVI, uviRisk(w.UVI)
+	}
+	d.DataAge, d.PossiblyStale = dataAgeNote(w.observedAt(), w.FetchedAt, now)
+	return d
+}
+
+// handleWeatherDetail serves every derived value for a city as one JSON
+// object, from the same cache as /weather/{city}.
+func (s *server) handleWeatherDetail(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	requestStats.Record(city)
+	upstreamUnits := fetchUnits("")
+	data, status, err := s.cache.Get(r.Context(), cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, upstreamUnits)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	s.cache.setCacheHeaders(w, status, data)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newWeatherDetail(data, time.Now()))
+}
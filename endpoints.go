@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Names of the OpenWeather endpoints a WeatherClient calls.
+const (
+	endpointWeather          = "weather"
+	endpointForecast         = "forecast"
+	endpointOneCall          = "onecall"
+	endpointTimeMachine      = "onecall_timemachine"
+	endpointAirPollution     = "air_pollution"
+	endpointGeocoding        = "geocoding"
+	endpointGeocodingZip     = "geocoding_zip"
+	endpointGeocodingReverse = "geocoding_reverse"
+	endpointTiles            = "tiles"
+	endpointBox              = "box"
+)
+
+var defaultEndpoints = map[string]string{
+	endpointWeather:          openWeatherBaseURL + "/data/2.5/weather",
+	endpointForecast:         openWeatherBaseURL + "/data/2.5/forecast",
+	endpointOneCall:          openWeatherBaseURL + "/data/3.0/onecall",
+	endpointTimeMachine:      openWeatherBaseURL + "/data/3.0/onecall/timemachine",
+	endpointAirPollution:     openWeatherBaseURL + "/data/2.5/air_pollution",
+	endpointGeocoding:        openWeatherBaseURL + "/geo/1.0/direct",
+	endpointGeocodingZip:     openWeatherBaseURL + "/geo/1.0/zip",
+	endpointGeocodingReverse: openWeatherBaseURL + "/geo/1.0/reverse",
+	endpointTiles:            openWeatherTileURL + "/map",
+	endpointBox:              openWeatherBaseURL + "/data/2.5/box/city",
+}
+
+// loadEndpoints starts from defaultEndpoints and applies any
+// OPENWEATHER_ENDPOINT_<NAME> override, e.g. OPENWEATHER_ENDPOINT_FORECAST,
+// so each integration can be pointed at a mock or mirror on its own.
+// Every URL must be absolute http or https.
+func loadEndpoints() (map[string]string, error) {
+	endpoints := make(map[string]string, len(defaultEndpoints))
+	for name, def := range defaultEndpoints {
+		env := "OPENWEATHER_ENDPOINT_" + strings.ToUpper(name)
+		raw := os.Getenv(env)
+		if raw == "" {
+			raw = def
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", env, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q: expected an absolute http or https URL", env, u.Redacted())
+		}
+		endpoints[name] = strings.TrimSuffix(raw, "/")
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// withJSONEnvelope rewrites the server's own JSON responses on its way
+// out: each top-level object, and each object in a top-level array, gains
+// "generated_at", the server time the response was produced, and
+// jsonNaming is applied. Other content types, including event streams, and
+// JSON marked with rawJSONHeader pass through untouched.
+func withJSONEnvelope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jw := &jsonWriter{ResponseWriter: w}
+		next.ServeHTTP(jw, r)
+		if !jw.buffering {
+			return
+		}
+		body, err := stampGeneratedAt(jw.body.Bytes(), time.Now())
+		if err == nil && jsonNaming == "camel" {
+			body, err = camelCaseKeys(body)
+		}
+		if err != nil {
+			// Not valid JSON after all; send it as written.
+			body = jw.body.Bytes()
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(jw.code)
+		w.Write(body)
+	})
+}
+
+// stampGeneratedAt adds "generated_at" as the first field of every
+// top-level object in a stream of JSON values, and of every object directly
+// inside a top-level array, such as the results from /weather/points. Other
+// values have nowhere to put it and are left alone.
+func stampGeneratedAt(body []byte, at time.Time) ([]byte, error) {
+	field := `"generated_at":` + strconv.Quote(at.UTC().Format(time.RFC3339))
+	dec := json.NewDecoder(bytes.NewReader(body))
+	var out bytes.Buffer
+	for {
+		var v json.RawMessage
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v[0] == '[' {
+			var elems []json.RawMessage
+			if err := json.Unmarshal(v, &elems); err != nil {
+				return nil, err
+			}
+			out.WriteByte('[')
+			for i, elem := range elems {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				stampObject(&out, elem, field)
+			}
+			out.WriteByte(']')
+		} else {
+			stampObject(&out, v, field)
+		}
+		if end := dec.InputOffset(); end < int64(len(body)) && body[end] == '\n' {
+			out.WriteByte('\n')
+		}
+	}
+}
+
+// stampObject writes v to out with field first if v is an object, and
+// unchanged otherwise.
+func stampObject(out *bytes.Buffer, v json.RawMessage, field string) {
+	v = bytes.TrimSpace(v)
+	switch rest := bytes.TrimSpace(v[1:]); {
+	case v[0] != '{':
+		out.Write(v)
+	case rest[0] == '}':
+		out.WriteString("{" + field + "}")
+	default:
+		out.WriteString("{" + field + ",")
+		out.Write(rest)
+	}
+}
+
+// rawJSONHeader marks a JSON response whose shape is fixed by someone
+// else, such as a Slack message, so withJSONEnvelope leaves it as written.
+// The header itself is removed before the response is sent.
+const rawJSONHeader = "X-Raw-Json"
+
+// rawJSONFormatter is implemented by formatters whose output is a third
+// party's payload rather than one of the server's own.
+type rawJSONFormatter interface {
+	rawJSON()
+}
+
+// setFormatterHeaders sets the Content-Type for formatter's output, and
+// rawJSONHeader if that output mustn't be rewritten.
+func setFormatterHeaders(w http.ResponseWriter, formatter Formatter) {
+	w.Header().Set("Content-Type", formatter.ContentType())
+	if _, ok := formatter.(rawJSONFormatter); ok {
+		w.Header().Set(rawJSONHeader, "1")
+	}
+}
+
+// jsonWriter holds JSON responses back so withJSONEnvelope can rewrite
+// them, and passes everything else straight through.
+type jsonWriter struct {
+	http.ResponseWriter
+	decided   bool
+	buffering bool
+	code      int
+	body      bytes.Buffer
+}
+
+func (jw *jsonWriter) decide(code int) {
+	if jw.decided {
+		return
+	}
+	jw.decided, jw.code = true, code
+	raw := jw.Header().Get(rawJSONHeader) != ""
+	jw.Header().Del(rawJSONHeader)
+	jw.buffering = !raw && strings.HasPrefix(jw.Header().Get("Content-Type"), "application/json")
+	if !jw.buffering {
+		jw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (jw *jsonWriter) WriteHeader(code int) {
+	jw.decide(code)
+}
+
+func (jw *jsonWriter) Write(b []byte) (int, error) {
+	jw.decide(http.StatusOK)
+	if jw.buffering {
+		return jw.body.Write(b)
+	}
+	return jw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (jw *jsonWriter) Unwrap() http.ResponseWriter {
+	return jw.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEnvelopeOutputs checks both kinds of JSON under each JSON_NAMING: the
+// server's own report gains generated_at and is renamed, while a Slack
+// payload, fixed by Slack, is sent exactly as formatted.
+func TestEnvelopeOutputs(t *testing.T) {
+	defer func(naming string) { jsonNaming = naming }(jsonNaming)
+	h := newTestServer().handler()
+	get := func(path string) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s: %v: %s", path, err, rec.Body.String())
+		}
+		if rec.Header().Get(rawJSONHeader) != "" {
+			t.Errorf("GET %s: %s leaked into the response", path, rawJSONHeader)
+		}
+		return rec, body
+	}
+
+	slack := SlackFormatter{Units: unitsMetric}.Format(sampleWeather())
+	for naming, keys := range map[string][]string{
+		"snake": {"generated_at", "feels_like"},
+		"camel": {"generatedAt", "feelsLike"},
+	} {
+		jsonNaming = naming
+
+		_, report := get("/weather/London?format=json")
+		for _, key := range keys {
+			if _, ok := report[key]; !ok {
+				t.Errorf("%s: report has no %s: %v", naming, key, report)
+			}
+		}
+
+		rec, msg := get("/weather/London?format=slack")
+		if got := strings.TrimSpace(rec.Body.String()); got != strings.TrimSpace(slack) {
+			t.Errorf("%s: Slack payload rewritten:\n got %s\nwant %s", naming, got, slack)
+		}
+		if _, ok := msg["response_type"]; !ok {
+			t.Errorf("%s: Slack payload has no response_type: %v", naming, msg)
+		}
+		if _, ok := msg["generated_at"]; ok {
+			t.Errorf("%s: Slack payload was stamped with generated_at", naming)
+		}
+	}
+}
+
+func TestStampGeneratedAt(t *testing.T) {
+	at := time.Date(2024, 6, 19, 12, 0, 0, 0, time.FixedZone("", 3600))
+	tests := []struct{ in, want string }{
+		{`{"a":1}`, `{"generated_at":"2024-06-19T11:00:00Z","a":1}`},
+		{`{}`, `{"generated_at":"2024-06-19T11:00:00Z"}`},
+		{"{\"a\":1}\n{\"b\":2}\n", "{\"generated_at\":\"2024-06-19T11:00:00Z\",\"a\":1}\n{\"generated_at\":\"2024-06-19T11:00:00Z\",\"b\":2}\n"},
+		{`[{"a":1}, {}, 2]`, `[{"generated_at":"2024-06-19T11:00:00Z","a":1},{"generated_at":"2024-06-19T11:00:00Z"},2]`},
+		// Bare values have nowhere to put it.
+		{`"text"`, `"text"`},
+		{`[]`, `[]`},
+	}
+	for _, tt := range tests {
+		got, err := stampGeneratedAt([]byte(tt.in), at)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("stampGeneratedAt(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := stampGeneratedAt([]byte(`{"a":`), at); err == nil {
+		t.Error("stampGeneratedAt accepted truncated JSON")
+	}
+}
+
+// TestEnvelopePoints checks each result from /weather/points, a bare
+// array, is stamped in its own right.
+func TestEnvelopePoints(t *testing.T) {
+	p := &coordProvider{FakeProvider: &FakeProvider{}}
+	s := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/points?point=51.5,-0.12&point=48.85,2.35", nil))
+	var results []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("GET /weather/points = %d: %v: %s", rec.Code, err, rec.Body.String())
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %s", len(results), rec.Body.String())
+	}
+	for i, res := range results {
+		if _, ok := res["generated_at"]; !ok {
+			t.Errorf("result %d has no generated_at: %v", i, res)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EnvFormatter renders KEY=value lines a shell can eval. Temperatures are
+// given in the requested units as TEMP and also as TEMP_C and TEMP_F.
+type EnvFormatter struct {
+	Units string
+	ReportOptions
+}
+
+func (EnvFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+// shellQuote leaves plain words and numbers alone and single-quotes
+// anything else, so eval never expands or splits a value.
+func shellQuote(v string) string {
+	if v != "" && strings.Trim(v, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-+") == "" {
+		return v
+	}
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+func (f EnvFormatter) Format(w WeatherData) string {
+	units := f.Units
+	if units == "" {
+		units = unitsMetric
+	}
+	temp := f.temp
+	feels, _ := w.feelsLike()
+	loc := w.Location()
+	vars := [][2]string{
+		{"CITY", loc.Name},
+		{"COUNTRY", loc.Country},
+		{"UNITS", units},
+	}
+	// Missing readings are left out rather than exported as zeros.
+	if f.showsActual() && w.tempKnown(w.Main.Temp) {
+		vars = append(vars, [][2]string{
+			{"TEMP", temp(convertTemp(w.Main.Temp, w.Units, units))},
+			{"TEMP_C", temp(w.celsius(w.Main.Temp))},
+			{"TEMP_F", temp(w.fahrenheit(w.Main.Temp))},
+		}...)
+	}
+	if f.showsFeelsLike() && w.feelsLikeKnown() {
+		vars = append(vars, [2]string{"FEELS_LIKE", temp(convertTemp(feels, w.Units, units))})
+	}
+	vars = append(vars, [][2]string{
+		{"HUMIDITY", strconv.Itoa(w.Main.Humidity)},
+		{"PRESSURE", formatPressure(w.Main.Pressure, f.pressureUnit(units))},
+		{"PRESSURE_UNIT", f.pressureUnit(units)},
+	}...)
+	if !w.NoWind {
+		vars = append(vars, [2]string{"WIND_SPEED", fmt.Sprintf("%.1f", w.windSpeed(units))}, [2]string{"WIND_DEG", strconv.Itoa(w.Wind.Deg)})
+	}
+	if !w.NoClouds {
+		vars = append(vars, [2]string{"CLOUDS", strconv.Itoa(w.Clouds.All)})
+	}
+	c := w.condition()
+	vars = append(vars, [2]string{"CONDITION", c.Main}, [2]string{"DESCRIPTION", c.Description})
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s=%s\n", v[0], shellQuote(stripControl(v[1])))
+	}
+	return b.String()
+}
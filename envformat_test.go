@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"London":       "London",
+		"-3.5":         "-3.5",
+		"":             "''",
+		"New York":     "'New York'",
+		"$(rm -rf /)":  "'$(rm -rf /)'",
+		"it's raining": `'it'\''s raining'`,
+		"a;b`c`":       "'a;b`c`'",
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestEnvFormatter(t *testing.T) {
+	w := sampleWeather()
+	w.Name = "St John's"
+	out := EnvFormatter{Units: unitsMetric}.Format(w)
+	for _, want := range []string{"CITY='St John'\\''s'\n", "UNITS=metric\n", "HUMIDITY="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
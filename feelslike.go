@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// feelsLike returns the upstream feels-like temperature in w.Units, or an
+// estimate from temperature, humidity and wind when OpenWeather sent none
+// (older and alternate responses leave it zero).
+func (w WeatherData) feelsLike() (v float64, estimated bool) {
+	if w.Main.FeelsLike != 0 {
+		return w.Main.FeelsLike, false
+	}
+	c := estimateFeelsLike(w.celsius(w.Main.Temp), float64(w.Main.Humidity), w.windSpeed(unitsMetric))
+	return convertTemp(c, unitsMetric, w.Units), true
+}
+
+// estimateFeelsLike returns the apparent temperature in °C: the NWS heat
+// index when it is hot and humid, the Environment Canada wind chill when
+// it is cold and windy, and the air temperature otherwise.
+func estimateFeelsLike(tempC, humidity, windMS float64) float64 {
+	tempF := tempC*9/5 + 32
+	windKMH := windMS * 3.6
+	switch {
+	case tempF >= 80 && humidity >= 40:
+		return (heatIndex(tempF, humidity) - 32) * 5 / 9
+	case tempC <= 10 && windKMH > 4.8:
+		v := math.Pow(windKMH, 0.16)
+		return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v
+	}
+	return tempC
+}
+
+// heatIndex is the Rothfusz regression in °F, as used by the NWS.
+func heatIndex(t, rh float64) float64 {
+	return -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+}
+
+// feelsLikeComparison is how far the feels-like temperature is from the
+// actual one, and the likely reason.
+type feelsLikeComparison struct {
+	Location           Location `json:"location"`
+	Units              string   `json:"units"`
+	Temperature        float64  `json:"temperature"`
+	FeelsLike          float64  `json:"feels_like"`
+	FeelsLikeEstimated bool     `json:"feels_like_estimated,omitempty"`
+	Difference         float64  `json:"difference"`
+	Cause              string   `json:"cause,omitempty"`
+	Message            string   `json:"message"`
+}
+
+// feelsLikeCause guesses why it feels diffC degrees (°C, feels-like minus
+// actual) off: wind chill makes it feel colder, humidity warmer, and dry
+// air colder when it's calm. "" means no clear cause.
+func feelsLikeCause(diffC, windMS float64, humidity int) string {
+	switch {
+	case diffC < 0 && windMS >= 1.5:
+		return "wind"
+	case diffC < 0 && humidity < 40:
+		return "dry air"
+	case diffC > 0 && humidity >= 40:
+		return "humidity"
+	}
+	return ""
+}
+
+// compareFeelsLike rounds to a tenth of a degree; within a degree of the
+// actual temperature, as differences in how people feel go, counts as
+// the same.
+func compareFeelsLike(w WeatherData, units string) feelsLikeComparison {
+	feels, estimated := w.feelsLike()
+	diffC := w.celsius(feels) - w.celsius(w.Main.Temp)
+
+	label := unitSetFor(units).Temp
+	diff := diffC
+	if units == unitsImperial {
+		diff = diffC * 9 / 5
+	}
+	c := feelsLikeComparison{
+		Location:           w.Location(),
+		Units:              units,
+		Temperature:        roundTemp(convertTemp(w.Main.Temp, w.Units, units), 1),
+		FeelsLike:          roundTemp(convertTemp(feels, w.Units, units), 1),
+		FeelsLikeEstimated: estimated,
+		Difference:         roundTemp(diff, 1),
+	}
+	if math.Abs(diffC) < 1 {
+		c.Message = "feels about the same as the actual temperature"
+		return c
+	}
+	if w.NoWind {
+		c.Cause = feelsLikeCause(diffC, 0, w.Main.Humidity)
+	} else {
+		c.Cause = feelsLikeCause(diffC, w.windSpeed(unitsMetric), w.Main.Humidity)
+	}
+	direction := "warmer"
+	if diffC < 0 {
+		direction = "colder"
+	}
+	c.Message = fmt.Sprintf("feels %s%s %s", formatTemp(math.Abs(c.Difference), 1), label, direction)
+	if c.Cause != "" {
+		c.Message += " due to " + c.Cause
+	}
+	return c
+}
+
+// handleFeelsLike reports how much warmer or colder it feels than the
+// actual temperature.
+func (s *server) handleFeelsLike(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	upstreamUnits := fetchUnits(units)
+	data, status, err := s.cache.Get(r.Context(), cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, upstreamUnits)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	s.cache.setCacheHeaders(w, status, data)
+	setLocationHeaders(w, data.Location())
+	c := compareFeelsLike(data, units)
+	if r.URL.Query().Get("format") == "json" || wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s: %s\n", c.Location, c.Message)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestEstimateFeelsLike checks the formulas against published values: the
+// NWS heat index table and Environment Canada's wind chill chart.
+func TestEstimateFeelsLike(t *testing.T) {
+	fToC := func(f float64) float64 { return (f - 32) * 5 / 9 }
+	tests := []struct {
+		name                   string
+		tempC, humidity, windM float64
+		want                   float64
+	}{
+		{"heat index 90°F 70%", fToC(90), 70, 0, fToC(106)},
+		{"heat index 96°F 65%", fToC(96), 65, 0, fToC(121)},
+		{"wind chill -10°C 20 km/h", -10, 50, 20 / 3.6, -17.9},
+		{"mild", 15, 60, 3, 15},
+		{"hot but dry", fToC(90), 20, 0, fToC(90)},
+		{"cold and calm", -10, 50, 1, -10},
+	}
+	for _, tt := range tests {
+		if got := estimateFeelsLike(tt.tempC, tt.humidity, tt.windM); math.Abs(got-tt.want) > 0.6 {
+			t.Errorf("%s: got %.1f°C, want %.1f°C", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFeelsLikeFallback(t *testing.T) {
+	w := sampleWeather()
+	if v, estimated := w.feelsLike(); estimated || v != w.Main.FeelsLike {
+		t.Errorf("feelsLike() = %v, %v; want upstream %v", v, estimated, w.Main.FeelsLike)
+	}
+	w.Main.FeelsLike = 0
+	w.Main.Temp = 263.15
+	w.Wind.Speed = 20 / 3.6
+	v, estimated := w.feelsLike()
+	if !estimated || math.Abs(w.celsius(v)+17.9) > 0.1 {
+		t.Errorf("feelsLike() = %.2f K, %v; want about -17.9°C, estimated", v, estimated)
+	}
+}
+
+func TestFeelsLikeCause(t *testing.T) {
+	tests := []struct {
+		diffC, windMS float64
+		humidity      int
+		want          string
+	}{
+		{-4, 5, 80, "wind"},
+		{-4, 1.5, 20, "wind"},
+		{-4, 1, 20, "dry air"},
+		{-4, 1, 60, ""},
+		{3, 5, 70, "humidity"},
+		{3, 0, 40, "humidity"},
+		{3, 0, 30, ""},
+	}
+	for _, tt := range tests {
+		if got := feelsLikeCause(tt.diffC, tt.windMS, tt.humidity); got != tt.want {
+			t.Errorf("feelsLikeCause(%v, %v, %d) = %q, want %q", tt.diffC, tt.windMS, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+func TestCompareFeelsLike(t *testing.T) {
+	weather := func(temp, feels float64, humidity int) WeatherData {
+		w := sampleWeather()
+		w.Units = unitsMetric
+		w.Main.Temp, w.Main.FeelsLike, w.Main.Humidity = temp, feels, humidity
+		return w
+	}
+	calm := weather(30, 34.5, 70)
+	calm.Wind.Speed = 0
+	tests := []struct {
+		name  string
+		w     WeatherData
+		units string
+		diff  float64
+		msg   string
+	}{
+		{"windy", weather(10, 6, 80), unitsMetric, -4, "feels 4.0°C colder due to wind"},
+		{"windy in °F", weather(10, 6, 80), unitsImperial, -7.2, "feels 7.2°F colder due to wind"},
+		{"humid", calm, unitsMetric, 4.5, "feels 4.5°C warmer due to humidity"},
+		{"within a degree", weather(10, 9.5, 80), unitsMetric, -0.5, "feels about the same as the actual temperature"},
+	}
+	for _, tt := range tests {
+		c := compareFeelsLike(tt.w, tt.units)
+		if c.Difference != tt.diff || c.Message != tt.msg {
+			t.Errorf("%s: got %v %q, want %v %q", tt.name, c.Difference, c.Message, tt.diff, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+// FixtureProvider serves a fixed OpenWeather response from disk, so the
+// server can run end to end without network access. The fixture is a
+// current-weather response in standard units; forecasts are unavailable.
+type FixtureProvider struct {
+	weather WeatherData
+}
+
+func NewFixtureProvider(filename string) (*FixtureProvider, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var weather WeatherData
+	if err := json.Unmarshal(bytes, &weather); err != nil {
+		return nil, err
+	}
+	weather.Units = unitsStandard
+	return &FixtureProvider{weather: weather}, nil
+}
+
+func (f *FixtureProvider) Current(_ context.Context, city, units string) (WeatherData, error) {
+	return f.weather, nil
+}
+
+func (f *FixtureProvider) Forecast(_ context.Context, city string, cnt int, units string) (ForecastData, error) {
+	return ForecastData{}, &UpstreamError{StatusCode: http.StatusServiceUnavailable, Message: "forecast is not available in offline mode"}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxForecastCount is the number of 3-hour steps in OpenWeather's 5 day
+// forecast, and the largest cnt it accepts.
+const maxForecastCount = 40
+
+type ForecastEntry struct {
+	Dt   int64 `json:"dt"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int     `json:"pressure"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Weather []Condition `json:"weather"`
+	Wind    struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+	} `json:"wind"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	// Rain and Snow are the accumulation in mm over the 3-hour step; the
+	// objects are absent when there is none.
+	Rain struct {
+		ThreeHour float64 `json:"3h"`
+	} `json:"rain"`
+	Snow struct {
+		ThreeHour float64 `json:"3h"`
+	} `json:"snow"`
+	Pop   float64 `json:"pop"`
+	DtTxt string  `json:"dt_txt"`
+}
+
+type ForecastData struct {
+	Cnt  int             `json:"cnt"`
+	List []ForecastEntry `json:"list"`
+	City struct {
+		Name  string `json:"name"`
+		Coord struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"coord"`
+		Country  string `json:"country"`
+		Timezone int    `json:"timezone"`
+		Sunrise  int64  `json:"sunrise"`
+		Sunset   int64  `json:"sunset"`
+	} `json:"city"`
+
+	// Units is the unit system the forecast was fetched in; "" is
+	// standard (Kelvin).
+	Units string `json:"-"`
+	// DisplayZone, set from ?tz=, replaces the city's offset in the times
+	// the forecast shows.
+	DisplayZone *time.Location `json:"-"`
+}
+
+// zone is where the forecast's times are shown: DisplayZone if the client
+// chose one, otherwise the city's own offset.
+func (f ForecastData) zone() *time.Location {
+	if f.DisplayZone != nil {
+		return f.DisplayZone
+	}
+	return time.FixedZone("", f.City.Timezone)
+}
+
+// parseForecastCount validates the optional cnt parameter. Zero means the
+// full forecast.
+func parseForecastCount(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	cnt, err := strconv.Atoi(raw)
+	if err != nil || cnt < 1 || cnt > maxForecastCount {
+		return 0, fmt.Errorf("invalid cnt %q: expected an integer between 1 and %d", raw, maxForecastCount)
+	}
+	return cnt, nil
+}
+
+func (c *WeatherClient) Forecast(ctx context.Context, city string, cnt int, units string) (ForecastData, error) {
+	params := url.Values{"q": {city}}
+	if cnt > 0 {
+		params.Set("cnt", strconv.Itoa(cnt))
+	}
+	if units != unitsStandard {
+		params.Set("units", units)
+	}
+	var forecast ForecastData
+	if err := c.fetch(ctx, endpointForecast, params, &forecast); err != nil {
+		return ForecastData{}, err
+	}
+	forecast.Units = units
+	return forecast, nil
+}
+
+// FormatOutput renders the forecast as text in units, where "" is metric.
+// Like the current-weather report, each temperature has a secondary scale
+// in brackets: °F for metric, °C for imperial and standard.
+func (f ForecastData) FormatOutput(units string) string {
+	var output strings.Builder
+
+	fmt.Fprintf(&output, "Forecast for %s 🌍\n", f.Location())
+	fmt.Fprintf(&output, "==================================\n")
+	if units == "" {
+		units = unitsMetric
+	}
+	secondary := unitsImperial
+	if units != unitsMetric {
+		secondary = unitsMetric
+	}
+	temp := func(v float64, to string) string {
+		return formatTemp(convertTemp(v, f.Units, to), 2) + unitSetFor(to).Temp
+	}
+	zone := f.zone()
+	for _, e := range f.List {
+		when := formatDateTime(time.Unix(e.Dt, 0).In(zone))
+		fmt.Fprintf(&output, "%s  %s (%s)", when, temp(e.Main.Temp, units), temp(e.Main.Temp, secondary))
+		c := primaryCondition(e.Weather)
+		fmt.Fprintf(&output, "  %s %s (%s)", conditionSymbol(defaultTheme, c.Main, c.ID), c.Main, c.Description)
+		if e.Rain.ThreeHour > 0 {
+			fmt.Fprintf(&output, "  🌧️ %.1f mm rain", e.Rain.ThreeHour)
+		}
+		if e.Snow.ThreeHour > 0 {
+			fmt.Fprintf(&output, "  ❄️ %.1f mm snow", e.Snow.ThreeHour)
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+type forecastEntryJSON struct {
+	Dt          int64   `json:"dt"`
+	Local       string  `json:"local"`
+	Temperature float64 `json:"temperature"`
+	FeelsLike   float64 `json:"feels_like"`
+	Humidity    int     `json:"humidity"`
+	Condition   string  `json:"condition,omitempty"`
+	Description string  `json:"description,omitempty"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Pop         float64 `json:"pop"`
+	Rain3h      float64 `json:"rain_3h,omitempty"`
+	Snow3h      float64 `json:"snow_3h,omitempty"`
+}
+
+// FormatJSON renders the forecast as JSON with temperatures and wind speed
+// in units, where "" is metric.
+func (f ForecastData) FormatJSON(units string) string {
+	if units == "" {
+		units = unitsMetric
+	}
+	out := struct {
+		Location Location            `json:"location"`
+		Units    string              `json:"units"`
+		List     []forecastEntryJSON `json:"list"`
+	}{Location: f.Location(), Units: units, List: []forecastEntryJSON{}}
+	zone := f.zone()
+	for _, e := range f.List {
+		entry := forecastEntryJSON{
+			Dt:          e.Dt,
+			Local:       time.Unix(e.Dt, 0).In(zone).Format(time.RFC3339),
+			Temperature: convertTemp(e.Main.Temp, f.Units, units),
+			FeelsLike:   convertTemp(e.Main.FeelsLike, f.Units, units),
+			Humidity:    e.Main.Humidity,
+			WindSpeed:   convertSpeed(e.Wind.Speed, f.Units, units),
+			Pop:         e.Pop,
+			Rain3h:      e.Rain.ThreeHour,
+			Snow3h:      e.Snow.ThreeHour,
+		}
+		c := primaryCondition(e.Weather)
+		entry.Condition, entry.Description = c.Main, c.Description
+		out.List = append(out.List, entry)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(b)
+}
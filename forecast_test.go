@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// londonForecastJSON is the first few steps of a forecast for London, in
+// standard units.
+const londonForecastJSON = `{
+	"cnt": 3,
+	"list": [
+		{"dt": 1718794800, "main": {"temp": 289.1, "feels_like": 288.7, "temp_min": 288.2, "temp_max": 289.1, "pressure": 1012, "humidity": 70},
+			"weather": [{"id": 803, "main": "Clouds", "description": "broken clouds", "icon": "04d"}], "wind": {"speed": 4.1, "deg": 250},
+			"clouds": {"all": 80}, "pop": 0.1, "dt_txt": "2024-06-19 11:00:00"},
+		{"dt": 1718805600, "main": {"temp": 291.4, "feels_like": 291.0, "temp_min": 291.4, "temp_max": 291.4, "pressure": 1011, "humidity": 61},
+			"weather": [{"id": 500, "main": "Rain", "description": "light rain", "icon": "10d"}], "wind": {"speed": 5.2, "deg": 260},
+			"clouds": {"all": 90}, "rain": {"3h": 0.4}, "pop": 0.5, "dt_txt": "2024-06-19 14:00:00"},
+		{"dt": 1718816400, "main": {"temp": 288.6, "feels_like": 288.1, "temp_min": 288.6, "temp_max": 288.6, "pressure": 1012, "humidity": 74},
+			"weather": [{"id": 800, "main": "Clear", "description": "clear sky", "icon": "01d"}], "wind": {"speed": 3.0, "deg": 270},
+			"clouds": {"all": 5}, "pop": 0, "dt_txt": "2024-06-19 17:00:00"}
+	],
+	"city": {"name": "London", "coord": {"lat": 51.5085, "lon": -0.1257}, "country": "GB", "timezone": 3600, "sunrise": 1718768580, "sunset": 1718828540}
+}`
+
+func sampleForecast() ForecastData {
+	var f ForecastData
+	if err := json.Unmarshal([]byte(londonForecastJSON), &f); err != nil {
+		panic(err)
+	}
+	f.Units = unitsStandard
+	return f
+}
+
+// TestForecastTimes checks the text forecast shows each step in the city's
+// own time, or the ?tz= zone, whatever the server's zone, in both clock
+// formats, and agrees with the JSON. London's first two steps are 11:00
+// and 14:00 UTC, an hour later locally.
+func TestForecastTimes(t *testing.T) {
+	defer func(layout string) { clockLayout = layout }(clockLayout)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		layout string
+		zone   *time.Location
+		text   []string
+		json   []string
+	}{
+		{"24h", clock24h, nil,
+			[]string{"Wed 19 Jun 12:00  ", "Wed 19 Jun 15:00  "},
+			[]string{`"local":"2024-06-19T12:00:00+01:00"`, `"local":"2024-06-19T15:00:00+01:00"`}},
+		{"12h", clock12h, nil,
+			[]string{"Wed 19 Jun 12:00 PM  ", "Wed 19 Jun 3:00 PM  "},
+			[]string{`"local":"2024-06-19T12:00:00+01:00"`, `"local":"2024-06-19T15:00:00+01:00"`}},
+		{"24h tz", clock24h, newYork,
+			[]string{"Wed 19 Jun 07:00  ", "Wed 19 Jun 10:00  "},
+			[]string{`"local":"2024-06-19T07:00:00-04:00"`, `"local":"2024-06-19T10:00:00-04:00"`}},
+		{"12h tz", clock12h, newYork,
+			[]string{"Wed 19 Jun 7:00 AM  ", "Wed 19 Jun 10:00 AM  "},
+			[]string{`"local":"2024-06-19T07:00:00-04:00"`, `"local":"2024-06-19T10:00:00-04:00"`}},
+	}
+	for _, tt := range tests {
+		clockLayout = tt.layout
+		f := sampleForecast()
+		f.DisplayZone = tt.zone
+		text, js := f.FormatOutput(""), f.FormatJSON("")
+		for _, want := range tt.text {
+			if !strings.Contains(text, want) {
+				t.Errorf("%s: text lacks %q:\n%s", tt.name, want, text)
+			}
+		}
+		for _, want := range tt.json {
+			if !strings.Contains(js, want) {
+				t.Errorf("%s: JSON lacks %s:\n%s", tt.name, want, js)
+			}
+		}
+	}
+}
+
+func TestForecastTZParam(t *testing.T) {
+	defer func(layout string) { clockLayout = layout }(clockLayout)
+	clockLayout = clock24h
+	h := newTestServer().handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London?tz=America/New_York", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Wed 19 Jun 07:00  ") {
+		t.Errorf("?tz=America/New_York: got %d, want 200 with 07:00:\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London?tz=Mars/Olympus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("?tz=Mars/Olympus: got %d, want 400", rec.Code)
+	}
+}
+
+func TestLoadClockLayout(t *testing.T) {
+	for env, want := range map[string]string{"": clock24h, "24h": clock24h, "12h": clock12h, "am/pm": clock24h} {
+		t.Setenv("TIME_FORMAT", env)
+		if got := loadClockLayout(); got != want {
+			t.Errorf("TIME_FORMAT=%q: layout %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestForecastUnitsParam(t *testing.T) {
+	h := newTestServer().routes()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London?units=imperial", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "60.71°F (15.95°C)") {
+		t.Errorf("?units=imperial: %d\n%s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London?units=kelvin", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("?units=kelvin: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
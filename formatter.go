@@ -0,0 +1,550 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter renders a weather report, converting from whichever units the
+// WeatherData was fetched in.
+type Formatter interface {
+	Format(WeatherData) string
+	ContentType() string
+}
+
+// emojiByDefault is false when EMOJI=false, for deployments whose clients
+// can't render emoji. Requests can still override it with ?emoji=.
+var emojiByDefault = os.Getenv("EMOJI") != "false"
+
+// attributionText is the credit OpenWeather's terms ask for.
+const attributionText = "Data provided by OpenWeather"
+
+// attributionEnabled is false when ATTRIBUTION=false, dropping the credit
+// from every report; ?attribution=false drops it from one.
+var attributionEnabled = os.Getenv("ATTRIBUTION") != "false"
+
+// ReportOptions are per-request toggles shared by every formatter.
+type ReportOptions struct {
+	Advice bool
+	// NoEmoji renders text reports in plain ASCII.
+	NoEmoji bool
+	// Round shows temperatures as whole degrees.
+	Round bool
+	// TempOrder is "celsius" or "fahrenheit" to pick which scale leads in
+	// the dual-unit display; "" leaves it to the units (°F for imperial).
+	TempOrder string
+	// NoAttribution leaves out the OpenWeather credit.
+	NoAttribution bool
+	// PressureUnit is hPa, inHg or mmHg; "" leaves it to the units.
+	PressureUnit string
+	// Theme names the condition symbols, see symbolThemes; "" is
+	// defaultTheme.
+	Theme string
+	// GoldenHour adds the morning and evening golden hours.
+	GoldenHour bool
+	// WindSpeeds adds the wind speed in every unit to JSON reports.
+	WindSpeeds bool
+	// Locale picks the decimal and thousands separators of text and HTML
+	// reports, see numberFormats; "" is defaultLocale.
+	Locale string
+	// Show is showActual or showFeelsLike to report only that temperature;
+	// "" reports both. JSON always carries both.
+	Show string
+}
+
+const (
+	showActual    = "actual"
+	showFeelsLike = "feelslike"
+	showBoth      = "both"
+)
+
+// parseShow validates ?show=. "both" is the default and comes back as "".
+func parseShow(raw string) (string, error) {
+	switch v := strings.ToLower(raw); v {
+	case "", showBoth:
+		return "", nil
+	case showActual, showFeelsLike:
+		return v, nil
+	}
+	return "", fmt.Errorf("unsupported show %q: expected actual, feelslike or both", raw)
+}
+
+// showsActual and showsFeelsLike report whether Show keeps that
+// temperature.
+func (o ReportOptions) showsActual() bool    { return o.Show != showFeelsLike }
+func (o ReportOptions) showsFeelsLike() bool { return o.Show != showActual }
+
+// pressureUnit returns the pressure unit to report in for units.
+func (o ReportOptions) pressureUnit(units string) string {
+	if o.PressureUnit != "" {
+		return o.PressureUnit
+	}
+	return unitSetFor(units).Pressure
+}
+
+// attribution returns the credit line, or "" when it's turned off.
+func (o ReportOptions) attribution() string {
+	if o.NoAttribution || !attributionEnabled {
+		return ""
+	}
+	return attributionText
+}
+
+const (
+	orderCelsius    = "celsius"
+	orderFahrenheit = "fahrenheit"
+)
+
+// defaultTempOrder is TEMP_ORDER, used when a request has no ?order=.
+var defaultTempOrder = loadTempOrder()
+
+func loadTempOrder() string {
+	order, err := parseTempOrder(os.Getenv("TEMP_ORDER"))
+	if err != nil {
+		log.Printf("ignoring TEMP_ORDER: %v", err)
+	}
+	return order
+}
+
+// parseTempOrder validates ?order=, accepting the scale name or its initial.
+func parseTempOrder(raw string) (string, error) {
+	switch strings.ToLower(raw) {
+	case "":
+		return "", nil
+	case "c", orderCelsius:
+		return orderCelsius, nil
+	case "f", orderFahrenheit:
+		return orderFahrenheit, nil
+	}
+	return "", fmt.Errorf("unsupported order %q: expected celsius or fahrenheit", raw)
+}
+
+// temp formats a temperature with two decimals, or as a whole number in
+// Round mode. Halves round away from zero, unlike %.0f which rounds them
+// to even.
+func (o ReportOptions) temp(v float64) string {
+	if o.Round {
+		return formatTemp(v, 0)
+	}
+	return formatTemp(v, 2)
+}
+
+// icon returns the emoji as a trailing " <emoji>", or nothing in
+// NoEmoji mode.
+func (o ReportOptions) icon(emoji string) string {
+	if o.NoEmoji {
+		return ""
+	}
+	return " " + emoji
+}
+
+// selectFormatter picks the formatter for the units and format query
+// parameters. Empty values fall back to the metric text report.
+func selectFormatter(units, format string, opts ReportOptions) (Formatter, error) {
+	switch units {
+	case "", "metric", "standard", "imperial":
+	default:
+		return nil, fmt.Errorf("unsupported units %q: expected metric, imperial or standard", units)
+	}
+	switch format {
+	case "", "text":
+	case "json":
+		return JSONFormatter{Units: units, ReportOptions: opts}, nil
+	case "html":
+		return HTMLFormatter{Units: units, ReportOptions: opts}, nil
+	case "slack":
+		return SlackFormatter{Units: units, ReportOptions: opts}, nil
+	case "env":
+		return EnvFormatter{Units: units, ReportOptions: opts}, nil
+	case "voice":
+		return VoiceFormatter{Units: units, ReportOptions: opts}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q: expected text, json, html, slack, env or voice", format)
+	}
+	switch units {
+	case unitsImperial:
+		return ImperialFormatter{opts}, nil
+	case unitsStandard:
+		return StandardFormatter{opts}, nil
+	}
+	return MetricFormatter{opts}, nil
+}
+
+// formatSuffixes maps file-extension style suffixes on the city path
+// segment to the format they select.
+var formatSuffixes = map[string]string{
+	".json": "json",
+	".txt":  "text",
+	".html": "html",
+}
+
+// splitFormatSuffix strips a known extension such as ".json" from a city
+// path segment. Unknown suffixes are left alone so "St. Louis" still works.
+func splitFormatSuffix(raw string) (city, format string) {
+	if i := strings.LastIndex(raw, "."); i >= 0 {
+		if f, ok := formatSuffixes[strings.ToLower(raw[i:])]; ok {
+			return raw[:i], f
+		}
+	}
+	return raw, ""
+}
+
+func (w WeatherData) FormatOutput() string {
+	return MetricFormatter{}.Format(w)
+}
+
+type MetricFormatter struct {
+	ReportOptions
+}
+
+func (MetricFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (f MetricFormatter) Format(w WeatherData) string {
+	return textReport(w, unitsMetric, f.ReportOptions)
+}
+
+type ImperialFormatter struct {
+	ReportOptions
+}
+
+func (ImperialFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (f ImperialFormatter) Format(w WeatherData) string {
+	return textReport(w, unitsImperial, f.ReportOptions)
+}
+
+// StandardFormatter reports in Kelvin, with °C in brackets.
+type StandardFormatter struct {
+	ReportOptions
+}
+
+func (StandardFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (f StandardFormatter) Format(w WeatherData) string {
+	return textReport(w, unitsStandard, f.ReportOptions)
+}
+
+// textReport renders the plain-text report in units. Temperatures are
+// shown in the primary scale with a secondary one in brackets: °F for
+// metric, °C for imperial and standard (Kelvin). Imperial also switches
+// wind speed to mph.
+func textReport(w WeatherData, units string, opts ReportOptions) string {
+	var output strings.Builder
+	icon := opts.icon
+	// Plain mode is pure ASCII, so the degree sign goes too.
+	deg, bearing := "°", "°"
+	if opts.NoEmoji {
+		deg, bearing = "", " deg"
+	}
+
+	labels, celsius, fahrenheit := unitSetFor(units), unitSetFor(unitsMetric), unitSetFor(unitsImperial)
+	if opts.NoEmoji {
+		labels, celsius, fahrenheit = labels.plain(), celsius.plain(), fahrenheit.plain()
+	}
+	primary, secondary := w.celsius, w.fahrenheit
+	primaryUnit, secondaryUnit := celsius.Temp, fahrenheit.Temp
+	fahrenheitFirst := units == unitsImperial
+	if opts.TempOrder != "" {
+		fahrenheitFirst = opts.TempOrder == orderFahrenheit
+	}
+	if fahrenheitFirst {
+		primary, secondary = secondary, primary
+		primaryUnit, secondaryUnit = secondaryUnit, primaryUnit
+	}
+	if units == unitsStandard {
+		primary, secondary = w.kelvin, w.celsius
+		primaryUnit, secondaryUnit = labels.Temp, celsius.Temp
+	}
+
+	fmt.Fprintf(&output, "Weather Report for %s%s\n", w.Location(), icon("🌍"))
+	fmt.Fprintf(&output, "==================================\n")
+	for _, warning := range w.tempWarnings(deg) {
+		fmt.Fprintf(&output, "WARNING: %s%s\n", warning, icon("⚠️"))
+	}
+	nf := opts.numbers()
+	t := func(v float64) string { return nf.format(opts.temp(v)) }
+	// minMax is one end of Min/Max with its unit, or N/A.
+	minMax := func(v float64) string {
+		if !w.tempKnown(v) {
+			return notAvailable
+		}
+		return t(primary(v)) + primaryUnit
+	}
+	if opts.showsActual() {
+		if w.tempKnown(w.Main.Temp) {
+			fmt.Fprintf(&output, "Temperature: %s%s (%s%s)%s\n", t(primary(w.Main.Temp)), primaryUnit, t(secondary(w.Main.Temp)), secondaryUnit, icon("🌡️"))
+		} else {
+			fmt.Fprintf(&output, "Temperature: %s%s\n", notAvailable, icon("🌡️"))
+		}
+	}
+	if opts.showsFeelsLike() {
+		feels, estimated := w.feelsLike()
+		note := ""
+		if estimated {
+			note = " (estimated)"
+		}
+		if w.feelsLikeKnown() {
+			fmt.Fprintf(&output, "Feels like: %s%s (%s%s)%s%s\n", t(primary(feels)), primaryUnit, t(secondary(feels)), secondaryUnit, note, icon("🤔"))
+		} else {
+			fmt.Fprintf(&output, "Feels like: %s%s\n", notAvailable, icon("🤔"))
+		}
+	}
+	fmt.Fprintf(&output, "Min/Max: %s / %s%s\n", minMax(w.Main.TempMin), minMax(w.Main.TempMax), icon("📊"))
+	fmt.Fprintf(&output, "Humidity: %d%%%s\n", w.Main.Humidity, icon("💧"))
+	pressure := opts.pressureUnit(units)
+	fmt.Fprintf(&output, "Pressure: %s %s%s\n", nf.format(formatPressure(w.Main.Pressure, pressure)), pressure, icon("🔬"))
+	if w.Main.SeaLevel != 0 {
+		fmt.Fprintf(&output, "Sea-level pressure: %s %s\n", nf.format(formatPressure(w.Main.SeaLevel, pressure)), pressure)
+	}
+	if w.Main.GrndLevel != 0 {
+		fmt.Fprintf(&output, "Ground-level pressure: %s %s\n", nf.format(formatPressure(w.Main.GrndLevel, pressure)), pressure)
+	}
+
+	c := w.condition()
+	if opts.NoEmoji {
+		fmt.Fprintf(&output, "Condition: %s (%s)\n", c.Main, c.Description)
+	} else {
+		fmt.Fprintf(&output, "Condition: %s %s (%s)\n", conditionSymbol(opts.Theme, c.Main, c.ID), c.Main, c.Description)
+	}
+
+	if w.NoWind {
+		fmt.Fprintf(&output, "Wind: %s%s\n", notAvailable, icon("🌬️"))
+	} else {
+		fmt.Fprintf(&output, "Wind: %s, Direction: %d%s (%s)%s\n", w.windTextIn(units, labels.Speed, nf), w.Wind.Deg, bearing, compassDirection(w.Wind.Deg), icon("🌬️"))
+	}
+	fmt.Fprintf(&output, "Cloudiness: %s%s\n", w.cloudsText(), icon("☁️"))
+	if w.HasUVI {
+		fmt.Fprintf(&output, "UV Index: %s (%s)%s\n", nf.format(strconv.FormatFloat(w.UVI, 'f', 1, 64)), uviRisk(w.UVI), icon("🕶️"))
+	}
+
+	sunrise := formatClock(w.localTime(w.Sys.Sunrise))
+	sunset := formatClock(w.localTime(w.Sys.Sunset))
+	fmt.Fprintf(&output, "Sunrise: %s%s, Sunset: %s%s\n", sunrise, icon("🌅"), sunset, icon("🌇"))
+	if daylight, known := w.Daylight(); known {
+		fmt.Fprintf(&output, "Daylight: %s\n", formatDaylight(daylight))
+	}
+	if g, ok := w.GoldenHours(); ok && opts.GoldenHour {
+		fmt.Fprintf(&output, "Golden hour: %s-%s and %s-%s (%s)%s\n",
+			formatClock(g.MorningStart), formatClock(g.MorningEnd), formatClock(g.EveningStart), formatClock(g.EveningEnd), w.zoneLabel(), icon("📷"))
+	}
+	switch {
+	case w.Historical:
+		fmt.Fprintf(&output, "Observed at: %s (%s)\n", w.localTime(w.Dt).Format("2006-01-02 15:04"), w.zoneLabel())
+	case w.Dt != 0:
+		fmt.Fprintf(&output, "Observed at: %s (%s)\n", formatClock(w.localTime(w.Dt)), w.zoneLabel())
+	}
+	// A past observation is old by design, so its age says nothing.
+	if !w.Historical {
+		if note, stale := dataAgeNote(w.observedAt(), w.FetchedAt, time.Now()); stale {
+			fmt.Fprintf(&output, "Data age: %s%s\n", note, icon("⚠️"))
+		} else if note != "" {
+			fmt.Fprintf(&output, "Data age: %s\n", note)
+		}
+	}
+	if isDay, known := w.IsDay(); known && isDay {
+		fmt.Fprintf(&output, "Time of day: Day%s\n", icon("🌞"))
+	} else if known {
+		fmt.Fprintf(&output, "Time of day: Night%s\n", icon("🌙"))
+	}
+
+	if opts.Advice {
+		if tip := recommend(w); tip != "" {
+			fmt.Fprintf(&output, "Advice: %s%s\n", tip, icon("💡"))
+		}
+	}
+	if credit := opts.attribution(); credit != "" {
+		fmt.Fprintf(&output, "%s\n", credit)
+	}
+
+	return output.String()
+}
+
+// weatherJSON is the JSON representation of a report. Temperatures are in
+// the requested units: °C for metric, °F for imperial and K for standard.
+// Fields marked deprecated keep their original names for older clients.
+type weatherJSON struct {
+	CityID        int      `json:"city_id,omitempty"`
+	City          string   `json:"city"`    // Deprecated: use location.name.
+	Country       string   `json:"country"` // Deprecated: use location.country.
+	Location      Location `json:"location"`
+	Units         string   `json:"units"`
+	Temperature   *float64 `json:"temperature"`
+	FeelsLike     *float64 `json:"feels_like"`
+	TempMin       *float64 `json:"temp_min"`
+	TempMax       *float64 `json:"temp_max"`
+	Humidity      int      `json:"humidity"`
+	Pressure      float64  `json:"pressure"`
+	PressureUnit  string   `json:"pressure_unit"`
+	SeaLevel      float64  `json:"sea_level,omitempty"`
+	GrndLevel     float64  `json:"grnd_level,omitempty"`
+	Condition     string   `json:"condition,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Icon          string   `json:"icon,omitempty"`
+	WindSpeed     *float64 `json:"wind_speed"`
+	WindDeg       *int     `json:"wind_deg"`
+	WindCompass   string   `json:"wind_compass,omitempty"`
+	Clouds        *int     `json:"clouds"`
+	Sunrise       int64    `json:"sunrise"` // Deprecated: use sunrise_unix.
+	Sunset        int64    `json:"sunset"`  // Deprecated: use sunset_unix.
+	SunriseUnix   int64    `json:"sunrise_unix"`
+	SunsetUnix    int64    `json:"sunset_unix"`
+	SunriseLocal  string   `json:"sunrise_local"`
+	SunsetLocal   string   `json:"sunset_local"`
+	UVI           *float64 `json:"uvi,omitempty"`
+	UVIRisk       string   `json:"uvi_risk,omitempty"`
+	ObservedUnix  int64    `json:"observed_unix,omitempty"`
+	ObservedLocal string   `json:"observed_local,omitempty"`
+	IsDay         *bool    `json:"is_day"`
+	ColorHint     string   `json:"color_hint"`
+	Advice        string   `json:"advice,omitempty"`
+
+	// FeelsLikeEstimated is set when feels_like was computed locally.
+	FeelsLikeEstimated bool     `json:"feels_like_estimated,omitempty"`
+	Warnings           []string `json:"warnings,omitempty"`
+	DataAge            string   `json:"data_age,omitempty"`
+	PossiblyStale      bool     `json:"possibly_stale,omitempty"`
+	// DaylightSeconds is 0 in polar night, so it is only omitted when
+	// unknown.
+	DaylightSeconds *int64 `json:"daylight_seconds,omitempty"`
+	Daylight        string `json:"daylight,omitempty"`
+	Attribution     string `json:"attribution,omitempty"`
+
+	GoldenHour *goldenHourJSON `json:"golden_hour,omitempty"`
+	WindSpeeds *windSpeedsJSON `json:"wind_speeds,omitempty"`
+}
+
+// windSpeedsJSON is the wind speed in each unit a client might want,
+// rounded to a tenth.
+type windSpeedsJSON struct {
+	MetersPerSecond float64 `json:"m_s"`
+	KilometersHour  float64 `json:"km_h"`
+	MilesHour       float64 `json:"mph"`
+	Knots           float64 `json:"knots"`
+}
+
+func newWindSpeeds(w WeatherData) *windSpeedsJSON {
+	ms := convertSpeed(w.Wind.Speed, w.Units, unitsMetric)
+	return &windSpeedsJSON{
+		MetersPerSecond: roundTemp(ms, 1),
+		KilometersHour:  roundTemp(ms*3.6, 1),
+		MilesHour:       roundTemp(metersPerSecondToMph(ms), 1),
+		Knots:           roundTemp(ms*1.943844, 1),
+	}
+}
+
+type goldenHourJSON struct {
+	MorningStart string `json:"morning_start"`
+	MorningEnd   string `json:"morning_end"`
+	EveningStart string `json:"evening_start"`
+	EveningEnd   string `json:"evening_end"`
+}
+
+type JSONFormatter struct {
+	Units string
+	ReportOptions
+}
+
+func (JSONFormatter) ContentType() string { return "application/json" }
+
+func (f JSONFormatter) Format(w WeatherData) string {
+	units := f.Units
+	if units == "" {
+		units = unitsMetric
+	}
+	temp := func(v float64) *float64 {
+		if !w.tempKnown(v) {
+			return nil
+		}
+		t := convertTemp(v, w.Units, units)
+		if f.Round {
+			t = roundTemp(t, 0)
+		}
+		return &t
+	}
+	wind := w.windSpeed(units)
+	pressure := func(hPa int) float64 { return pressureIn(hPa, f.pressureUnit(units)) }
+
+	out := weatherJSON{
+		CityID:       w.ID,
+		City:         w.Name,
+		Country:      w.Sys.Country,
+		Location:     w.Location(),
+		Units:        units,
+		Temperature:  temp(w.Main.Temp),
+		TempMin:      temp(w.Main.TempMin),
+		TempMax:      temp(w.Main.TempMax),
+		Humidity:     w.Main.Humidity,
+		Pressure:     pressure(w.Main.Pressure),
+		PressureUnit: f.pressureUnit(units),
+		SeaLevel:     pressure(w.Main.SeaLevel),
+		GrndLevel:    pressure(w.Main.GrndLevel),
+		Sunrise:      w.Sys.Sunrise,
+		Sunset:       w.Sys.Sunset,
+		SunriseUnix:  w.Sys.Sunrise,
+		SunsetUnix:   w.Sys.Sunset,
+		SunriseLocal: w.localTime(w.Sys.Sunrise).Format(time.RFC3339),
+		SunsetLocal:  w.localTime(w.Sys.Sunset).Format(time.RFC3339),
+	}
+	// A missing temperature, and feels-like estimated from it, are null.
+	feels, estimated := w.feelsLike()
+	if w.feelsLikeKnown() {
+		out.FeelsLike, out.FeelsLikeEstimated = temp(feels), estimated
+	}
+	out.Warnings = w.tempWarnings("°")
+	if !w.Historical {
+		out.DataAge, out.PossiblyStale = dataAgeNote(w.observedAt(), w.FetchedAt, time.Now())
+	}
+	c := w.condition()
+	out.Condition, out.Description, out.Icon = c.Main, c.Description, c.Icon
+	if w.Dt != 0 {
+		out.ObservedUnix = w.Dt
+		out.ObservedLocal = w.localTime(w.Dt).Format(time.RFC3339)
+	}
+	isDay, known := w.IsDay()
+	if known {
+		out.IsDay = &isDay
+	}
+	if daylight, ok := w.Daylight(); ok {
+		secs := int64(daylight.Seconds())
+		out.DaylightSeconds, out.Daylight = &secs, formatDaylight(daylight)
+	}
+	out.ColorHint = colorHint(out.Condition, isDay || !known)
+	if w.HasUVI {
+		out.UVI = &w.UVI
+		out.UVIRisk = uviRisk(w.UVI)
+	}
+	if f.Advice {
+		out.Advice = recommend(w)
+	}
+	if g, ok := w.GoldenHours(); ok && f.GoldenHour {
+		out.GoldenHour = &goldenHourJSON{
+			MorningStart: g.MorningStart.Format(time.RFC3339),
+			MorningEnd:   g.MorningEnd.Format(time.RFC3339),
+			EveningStart: g.EveningStart.Format(time.RFC3339),
+			EveningEnd:   g.EveningEnd.Format(time.RFC3339),
+		}
+	}
+	// Missing wind or clouds are null rather than a zero reading.
+	if !w.NoWind {
+		out.WindSpeed, out.WindDeg = &wind, &w.Wind.Deg
+		out.WindCompass = compassDirection(w.Wind.Deg)
+		if f.WindSpeeds {
+			out.WindSpeeds = newWindSpeeds(w)
+		}
+	}
+	if !w.NoClouds {
+		out.Clouds = &w.Clouds.All
+	}
+	out.Attribution = f.attribution()
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(b)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestWeatherJSONCompatFields checks that the fields older clients read
+// are still there next to the ones that replaced them.
+func TestWeatherJSONCompatFields(t *testing.T) {
+	var out map[string]any
+	if err := json.Unmarshal([]byte(JSONFormatter{Units: unitsMetric}.Format(sampleWeather())), &out); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"city":          "London",
+		"country":       "GB",
+		"sunrise":       1718768580.0,
+		"sunset":        1718828540.0,
+		"sunrise_unix":  1718768580.0,
+		"sunset_unix":   1718828540.0,
+		"sunrise_local": "2024-06-19T04:43:00+01:00",
+		"sunset_local":  "2024-06-19T21:22:20+01:00",
+	} {
+		if got := out[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	loc, _ := out["location"].(map[string]any)
+	if loc["name"] != "London" || loc["country"] != "GB" {
+		t.Errorf("location = %v, want London, GB", out["location"])
+	}
+}
+
+func TestReportOptionsTemp(t *testing.T) {
+	tests := []struct {
+		v     float64
+		round bool
+		want  string
+	}{
+		{15, false, "15.00"},
+		{14.456, false, "14.46"},
+		{14.5, true, "15"},
+		{15.5, true, "16"},
+		{-2.5, true, "-3"},
+		{14.49, true, "14"},
+	}
+	for _, tt := range tests {
+		if got := (ReportOptions{Round: tt.round}).temp(tt.v); got != tt.want {
+			t.Errorf("temp(%v) with round=%v = %q, want %q", tt.v, tt.round, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRound(t *testing.T) {
+	var out struct {
+		Temperature float64 `json:"temperature"`
+		FeelsLike   float64 `json:"feels_like"`
+	}
+	// London is 15.0°C, feeling like 14.45°C.
+	if err := json.Unmarshal([]byte(JSONFormatter{Units: unitsMetric, ReportOptions: ReportOptions{Round: true}}.Format(sampleWeather())), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Temperature != 15 || out.FeelsLike != 14 {
+		t.Errorf("rounded JSON temperature %v, feels like %v; want 15 and 14", out.Temperature, out.FeelsLike)
+	}
+}
+
+func TestTextReportUnits(t *testing.T) {
+	for units, want := range map[string]string{
+		unitsMetric:   "Temperature: 15.00°C (59.00°F)",
+		unitsImperial: "Temperature: 59.00°F (15.00°C)",
+		unitsStandard: "Temperature: 288.15 K (15.00°C)",
+	} {
+		f, err := selectFormatter(units, "text", ReportOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Format(sampleWeather()); !strings.Contains(got, want) {
+			t.Errorf("%s report has no %q:\n%s", units, want, got)
+		}
+	}
+}
+
+func TestTempOrder(t *testing.T) {
+	tests := []struct {
+		units, order, want string
+	}{
+		{unitsMetric, "", "Temperature: 15.00°C (59.00°F)"},
+		{unitsMetric, "F", "Temperature: 59.00°F (15.00°C)"},
+		{unitsImperial, "", "Temperature: 59.00°F (15.00°C)"},
+		{unitsImperial, "celsius", "Temperature: 15.00°C (59.00°F)"},
+	}
+	for _, tt := range tests {
+		order, err := parseTempOrder(tt.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := selectFormatter(tt.units, "text", ReportOptions{TempOrder: order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Format(sampleWeather()); !strings.Contains(got, tt.want) {
+			t.Errorf("%s with order %q has no %q:\n%s", tt.units, tt.order, tt.want, got)
+		}
+	}
+	if _, err := parseTempOrder("kelvin"); err == nil {
+		t.Error("parseTempOrder(kelvin) succeeded")
+	}
+}
+
+func TestCompassDirection(t *testing.T) {
+	for deg, want := range map[int]string{0: "N", 11: "N", 12: "NNE", 90: "E", 240: "WSW", 348: "NNW", 349: "N", 360: "N", -90: "W", 720: "N"} {
+		if got := compassDirection(deg); got != want {
+			t.Errorf("compassDirection(%d) = %s, want %s", deg, got, want)
+		}
+	}
+}
+
+// TestWindReport checks the compass point in text and JSON, and that
+// ?windspeeds= adds the speed in every unit. The sample wind is 4.6 m/s
+// from 240°.
+func TestWindReport(t *testing.T) {
+	text := textReport(sampleWeather(), unitsMetric, ReportOptions{NoEmoji: true})
+	if !strings.Contains(text, "Direction: 240 deg (WSW)") {
+		t.Errorf("text report lacks the compass point:\n%s", text)
+	}
+
+	var out struct {
+		WindCompass string          `json:"wind_compass"`
+		WindSpeeds  *windSpeedsJSON `json:"wind_speeds"`
+	}
+	if err := json.Unmarshal([]byte(JSONFormatter{Units: unitsMetric}.Format(sampleWeather())), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.WindCompass != "WSW" || out.WindSpeeds != nil {
+		t.Errorf("JSON wind_compass %q, wind_speeds %+v; want WSW and no speeds", out.WindCompass, out.WindSpeeds)
+	}
+	f := JSONFormatter{Units: unitsImperial, ReportOptions: ReportOptions{WindSpeeds: true}}
+	if err := json.Unmarshal([]byte(f.Format(sampleWeather())), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := (windSpeedsJSON{MetersPerSecond: 4.6, KilometersHour: 16.6, MilesHour: 10.3, Knots: 8.9}); out.WindSpeeds == nil || *out.WindSpeeds != want {
+		t.Errorf("wind_speeds = %+v, want %+v", out.WindSpeeds, want)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxGeoIPEntries bounds the per-IP lookup cache; past it, expired entries
+// are dropped and, if that isn't enough, the rest too.
+const maxGeoIPEntries = 1000
+
+// Geolocator resolves a client IP to a place we can ask the weather for.
+type Geolocator interface {
+	Locate(ctx context.Context, ip string) (Location, error)
+}
+
+// StaticGeolocator places every client in the same location. It backs
+// GEOIP_STATIC, which is mostly useful for tests and local development.
+type StaticGeolocator struct {
+	Location Location
+}
+
+func (g StaticGeolocator) Locate(context.Context, string) (Location, error) {
+	return g.Location, nil
+}
+
+// ipAPIGeolocator looks IPs up using the ip-api.com JSON format at
+// baseURL, which GEOIP_URL sets.
+type ipAPIGeolocator struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func (g *ipAPIGeolocator) Locate(ctx context.Context, ip string) (Location, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+url.PathEscape(ip), nil)
+	if err != nil {
+		return Location{}, err
+	}
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return Location{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Location{}, fmt.Errorf("geolocation lookup returned %s", resp.Status)
+	}
+	var body struct {
+		Status      string  `json:"status"`
+		Message     string  `json:"message"`
+		City        string  `json:"city"`
+		CountryCode string  `json:"countryCode"`
+		Lat         float64 `json:"lat"`
+		Lon         float64 `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Location{}, err
+	}
+	if body.Status != "success" || body.City == "" {
+		return Location{}, fmt.Errorf("geolocation failed for %s: %s", ip, body.Message)
+	}
+	return Location{Name: body.City, Country: body.CountryCode, Lat: body.Lat, Lon: body.Lon}, nil
+}
+
+// cachedGeolocator remembers successful lookups per IP for ttl, so repeat
+// visitors don't cost a call to the geolocation service each time.
+type cachedGeolocator struct {
+	next    Geolocator
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]geoIPEntry
+}
+
+type geoIPEntry struct {
+	loc      Location
+	lookedUp time.Time
+}
+
+func newCachedGeolocator(next Geolocator, ttl time.Duration) *cachedGeolocator {
+	return &cachedGeolocator{next: next, ttl: ttl, now: time.Now, entries: make(map[string]geoIPEntry)}
+}
+
+func (g *cachedGeolocator) Locate(ctx context.Context, ip string) (Location, error) {
+	g.mu.Lock()
+	e, ok := g.entries[ip]
+	g.mu.Unlock()
+	if ok && g.now().Sub(e.lookedUp) < g.ttl {
+		return e.loc, nil
+	}
+	loc, err := g.next.Locate(ctx, ip)
+	if err != nil {
+		return Location{}, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if len(g.entries) >= maxGeoIPEntries {
+		for k, e := range g.entries {
+			if now.Sub(e.lookedUp) >= g.ttl {
+				delete(g.entries, k)
+			}
+		}
+		if len(g.entries) >= maxGeoIPEntries {
+			g.entries = make(map[string]geoIPEntry)
+		}
+	}
+	g.entries[ip] = geoIPEntry{loc: loc, lookedUp: now}
+	return loc, nil
+}
+
+// Len returns how many IPs are cached, expired or not.
+func (g *cachedGeolocator) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.entries)
+}
+
+// newGeolocator picks the geolocation source from the environment:
+// GEOIP_STATIC="city,CC" pins every client to one place, and GEOIP_URL
+// names an ip-api.com compatible service to send client IPs to. With
+// neither set, geolocation is off and nil is returned; client IPs are
+// never sent anywhere unless an operator asks for it.
+func newGeolocator() Geolocator {
+	if static := os.Getenv("GEOIP_STATIC"); static != "" {
+		name, country, _ := strings.Cut(static, ",")
+		return StaticGeolocator{Location{Name: strings.TrimSpace(name), Country: strings.TrimSpace(country)}}
+	}
+	base := os.Getenv("GEOIP_URL")
+	if base == "" {
+		return nil
+	}
+	if strings.HasPrefix(base, "http://") {
+		log.Printf("warning: GEOIP_URL %q is not HTTPS, client IPs will be sent in the clear", base)
+	}
+	return newCachedGeolocator(&ipAPIGeolocator{
+		httpClient: &http.Client{Timeout: envDuration("GEOIP_TIMEOUT", 2*time.Second)},
+		baseURL:    base,
+	}, envDuration("GEOIP_CACHE_TTL", time.Hour))
+}
+
+// trustedProxies are the peers whose X-Forwarded-For header is believed,
+// from TRUSTED_PROXIES as a comma-separated list of IPs and CIDR ranges.
+var trustedProxies = parseTrustedProxies(envList("TRUSTED_PROXIES", nil))
+
+func parseTrustedProxies(items []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range items {
+		if p, err := netip.ParsePrefix(item); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		} else if a, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+		} else {
+			log.Printf("warning: ignoring TRUSTED_PROXIES entry %q: not an IP or CIDR range", item)
+		}
+	}
+	return prefixes
+}
+
+func isTrustedProxy(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoClientIP = errors.New("could not determine client IP")
+
+// clientIP returns the address of the caller. X-Forwarded-For is only
+// believed when the direct peer is one of proxies: the header is read from
+// the right, skipping further trusted proxies, so the first untrusted hop
+// is the client and a value the client forged itself is never used.
+func clientIP(r *http.Request, proxies []netip.Prefix) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return "", errNoClientIP
+	}
+	peer = peer.Unmap()
+	if !isTrustedProxy(proxies, peer) {
+		return peer.String(), nil
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		peer = hop.Unmap()
+		if !isTrustedProxy(proxies, peer) {
+			break
+		}
+	}
+	return peer.String(), nil
+}
+
+// handleWeatherHere serves /weather/here by geolocating the caller and
+// then answering exactly as /weather/{city} would, for the coordinates
+// the lookup returned. DEFAULT_CITY is used when the lookup fails.
+func (s *server) handleWeatherHere(w http.ResponseWriter, r *http.Request) {
+	q, err := s.locate(r)
+	if err != nil {
+		def := os.Getenv("DEFAULT_CITY")
+		city, cityErr := parseCityQuery(def)
+		if def == "" || cityErr != nil {
+			http.Error(w, "could not determine your location, try /weather/{city}", http.StatusBadGateway)
+			return
+		}
+		log.Printf("geolocation failed, using DEFAULT_CITY %q: %v", city, err)
+		q = weatherQuery{city: city}
+	}
+	s.serveWeather(w, r, q, "")
+}
+
+var errGeoIPDisabled = errors.New("IP geolocation is not configured")
+
+// locate turns the caller's IP into a query. A lookup with coordinates is
+// answered for that point when the provider can do so, as the name alone
+// may match another place; otherwise, or while ALLOWED_CITIES is set, the
+// city name is looked up.
+func (s *server) locate(r *http.Request) (weatherQuery, error) {
+	if s.geo == nil {
+		return weatherQuery{}, errGeoIPDisabled
+	}
+	ip, err := clientIP(r, trustedProxies)
+	if err != nil {
+		return weatherQuery{}, err
+	}
+	loc, err := s.geo.Locate(r.Context(), ip)
+	if err != nil {
+		return weatherQuery{}, err
+	}
+	if _, ok := s.provider.(CoordProvider); ok && allowedCities == nil && (loc.Lat != 0 || loc.Lon != 0) {
+		return weatherQuery{at: &point{Lat: loc.Lat, Lon: loc.Lon}}, nil
+	}
+	if loc.Name == "" {
+		return weatherQuery{}, fmt.Errorf("no city found for %s", ip)
+	}
+	if !cityAllowed(loc.Name) {
+		return weatherQuery{}, fmt.Errorf("%w: %q", errCityNotAllowed, loc.Name)
+	}
+	if len(loc.Country) == 2 {
+		return weatherQuery{city: loc.Name + "," + loc.Country}, nil
+	}
+	return weatherQuery{city: loc.Name}, nil
+}
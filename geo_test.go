@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	tests := []struct {
+		remote, forwarded, want string
+	}{
+		{"203.0.113.7:4000", "", "203.0.113.7"},
+		{"203.0.113.7:4000", "198.51.100.9", "203.0.113.7"},
+		{"10.1.2.3:4000", "198.51.100.9", "198.51.100.9"},
+		{"10.1.2.3:4000", "6.6.6.6, 198.51.100.9", "198.51.100.9"},
+		{"10.1.2.3:4000", "198.51.100.9, 192.0.2.1", "198.51.100.9"},
+		{"10.1.2.3:4000", "", "10.1.2.3"},
+		{"10.1.2.3:4000", "junk", "10.1.2.3"},
+		{"[::ffff:203.0.113.7]:4000", "", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/weather/here", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		got, err := clientIP(r, proxies)
+		if err != nil || got != tt.want {
+			t.Errorf("clientIP(%s, XFF %q) = %q, %v; want %q", tt.remote, tt.forwarded, got, err, tt.want)
+		}
+	}
+}
+
+type countingGeolocator struct {
+	calls int
+	loc   Location
+	err   error
+}
+
+func (g *countingGeolocator) Locate(context.Context, string) (Location, error) {
+	g.calls++
+	return g.loc, g.err
+}
+
+func TestCachedGeolocator(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	next := &countingGeolocator{loc: Location{Name: "London", Country: "GB"}}
+	g := newCachedGeolocator(next, time.Hour)
+	g.now = func() time.Time { return now }
+
+	for range 3 {
+		if loc, err := g.Locate(context.Background(), "203.0.113.7"); err != nil || loc.Name != "London" {
+			t.Fatalf("Locate = %v, %v", loc, err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("%d lookups for one IP, want 1", next.calls)
+	}
+	g.Locate(context.Background(), "203.0.113.8")
+	now = now.Add(time.Hour)
+	g.Locate(context.Background(), "203.0.113.7")
+	if next.calls != 3 {
+		t.Errorf("%d lookups, want 3 after a second IP and an expired entry", next.calls)
+	}
+
+	next.err = errors.New("lookup failed")
+	g.Locate(context.Background(), "198.51.100.9")
+	g.Locate(context.Background(), "198.51.100.9")
+	if next.calls != 5 {
+		t.Errorf("%d lookups, want failures not to be cached", next.calls)
+	}
+}
+
+// coordProvider adds coordinate lookups to a FakeProvider, remembering
+// the last point asked for.
+type coordProvider struct {
+	*FakeProvider
+	asked *point
+}
+
+func (p *coordProvider) CurrentAt(_ context.Context, lat, lon float64, units string) (WeatherData, error) {
+	p.asked = &point{Lat: lat, Lon: lon}
+	return sampleWeather(), nil
+}
+
+func TestWeatherHereUsesCoordinates(t *testing.T) {
+	p := &coordProvider{FakeProvider: &FakeProvider{}}
+	s := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	s.geo = StaticGeolocator{Location{Name: "Springfield", Country: "US", Lat: 39.8, Lon: -89.64}}
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/here", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "London") {
+		t.Fatalf("GET /weather/here = %d %q", rec.Code, rec.Body)
+	}
+	if p.asked == nil || *p.asked != (point{Lat: 39.8, Lon: -89.64}) {
+		t.Errorf("CurrentAt asked for %v, want the geolocated 39.8,-89.64", p.asked)
+	}
+}
+
+func TestWeatherHereDisabled(t *testing.T) {
+	s := newTestServer()
+	s.geo = nil
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/here", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d without GEOIP_URL or DEFAULT_CITY, want 502", rec.Code)
+	}
+
+	t.Setenv("DEFAULT_CITY", "london")
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/here", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "London") {
+		t.Errorf("GET /weather/here with DEFAULT_CITY = %d %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Geocoder turns place names into locations and coordinates back into the
+// place they're in. Providers that can also resolve postal codes implement
+// ZipResolver alongside it.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) ([]Location, error)
+	Reverse(ctx context.Context, lat, lon float64) (Location, error)
+}
+
+// geocodeLimit is how many places a Geocode call asks for, which is also
+// the most OpenWeather returns.
+const geocodeLimit = 5
+
+var errGeocodingUnavailable = errors.New("geocoding is not available")
+
+// newGeocoder uses the provider when it can geocode and otherwise answers
+// every lookup with errGeocodingUnavailable, so handlers never need a nil
+// check.
+func newGeocoder(provider Provider) Geocoder {
+	if g, ok := provider.(Geocoder); ok {
+		return g
+	}
+	return noGeocoder{}
+}
+
+type noGeocoder struct{}
+
+func (noGeocoder) Geocode(context.Context, string) ([]Location, error) {
+	return nil, errGeocodingUnavailable
+}
+
+func (noGeocoder) Reverse(context.Context, float64, float64) (Location, error) {
+	return Location{}, errGeocodingUnavailable
+}
+
+// place is a location as the geocoding API describes it, and as /geocode
+// and "did you mean" answers show it. Unlike Location it has no timezone,
+// which geocoding doesn't know.
+type place struct {
+	Name    string  `json:"name"`
+	State   string  `json:"state,omitempty"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+func placeOf(l Location) place {
+	return place{Name: l.Name, State: l.State, Country: l.Country, Lat: l.Lat, Lon: l.Lon}
+}
+
+func placesOf(locs []Location) []place {
+	out := make([]place, len(locs))
+	for i, l := range locs {
+		out[i] = placeOf(l)
+	}
+	return out
+}
+
+func (p place) location() Location {
+	return Location{Name: p.Name, State: p.State, Country: p.Country, Lat: p.Lat, Lon: p.Lon}
+}
+
+// String renders the place as "Name, State, CC", leaving out what's
+// unknown.
+func (p place) String() string {
+	parts := []string{p.Name}
+	if p.State != "" {
+		parts = append(parts, p.State)
+	}
+	if p.Country != "" {
+		parts = append(parts, p.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Geocode asks the geocoding API for places matching query, best first.
+func (c *WeatherClient) Geocode(ctx context.Context, query string) ([]Location, error) {
+	var places []place
+	params := url.Values{"q": {query}, "limit": {strconv.Itoa(geocodeLimit)}}
+	if err := c.fetch(ctx, endpointGeocoding, params, &places); err != nil {
+		return nil, err
+	}
+	out := make([]Location, len(places))
+	for i, p := range places {
+		out[i] = p.location()
+	}
+	return out, nil
+}
+
+// Reverse asks the geocoding API for the place at lat, lon. An empty
+// answer, e.g. for the open sea, is a 404 like an unknown city.
+func (c *WeatherClient) Reverse(ctx context.Context, lat, lon float64) (Location, error) {
+	var places []place
+	params := url.Values{"lat": {formatCoord(lat)}, "lon": {formatCoord(lon)}, "limit": {"1"}}
+	if err := c.fetch(ctx, endpointGeocodingReverse, params, &places); err != nil {
+		return Location{}, err
+	}
+	if len(places) == 0 {
+		return Location{}, &UpstreamError{StatusCode: http.StatusNotFound, Message: "no place found at those coordinates"}
+	}
+	return places[0].location(), nil
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// FakeGeocoder answers from fixed places, keyed like cacheKey, for tests
+// and local development. Reverse returns the known place nearest to the
+// coordinates, within about a kilometre.
+type FakeGeocoder struct {
+	Places map[string][]Location
+	Errors map[string]error
+}
+
+func (f *FakeGeocoder) Geocode(_ context.Context, query string) ([]Location, error) {
+	key := cacheKey(query)
+	if err, ok := f.Errors[key]; ok {
+		return nil, err
+	}
+	return f.Places[key], nil
+}
+
+func (f *FakeGeocoder) Reverse(_ context.Context, lat, lon float64) (Location, error) {
+	var best Location
+	bestDist := math.Inf(1)
+	for _, places := range f.Places {
+		for _, p := range places {
+			if d := math.Hypot(p.Lat-lat, p.Lon-lon); d < bestDist {
+				best, bestDist = p, d
+			}
+		}
+	}
+	if bestDist > 0.01 {
+		return Location{}, &UpstreamError{StatusCode: http.StatusNotFound, Message: "no place found at those coordinates"}
+	}
+	return best, nil
+}
+
+// handleGeocode lists the places matching ?q=, best first.
+func (s *server) handleGeocode(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCityQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	places, err := s.geocoder.Geocode(r.Context(), query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"query": query, "results": placesOf(places)})
+}
+
+// handleReverseGeocode names the place at ?lat=&lon=.
+func (s *server) handleReverseGeocode(w http.ResponseWriter, r *http.Request) {
+	// The place found can't be checked against the allowlist before the
+	// lookup, so an allowlist rules coordinates out as it does for points.
+	if allowedCities != nil {
+		http.Error(w, "coordinate lookups are disabled while ALLOWED_CITIES is set", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p, err := parsePoint(q.Get("lat") + "," + q.Get("lon"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	loc, err := s.geocoder.Reverse(r.Context(), p.Lat, p.Lon)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	setLocationHeaders(w, loc)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(placeOf(loc))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testGeocoder() *FakeGeocoder {
+	return &FakeGeocoder{Places: map[string][]Location{
+		cacheKey("Springfield"): {
+			{Name: "Springfield", State: "Illinois", Country: "US", Lat: 39.8, Lon: -89.64},
+			{Name: "Springfield", State: "Missouri", Country: "US", Lat: 37.21, Lon: -93.29},
+		},
+	}}
+}
+
+func TestFakeGeocoderReverse(t *testing.T) {
+	g := testGeocoder()
+	loc, err := g.Reverse(context.Background(), 37.215, -93.295)
+	if err != nil || loc.State != "Missouri" {
+		t.Errorf("Reverse near Springfield, MO = %+v, %v", loc, err)
+	}
+	if _, err := g.Reverse(context.Background(), 0, 0); queryErrorStatus(err) != http.StatusNotFound {
+		t.Errorf("Reverse(0, 0) = %v, want a 404", err)
+	}
+}
+
+func TestGeocodeRoutes(t *testing.T) {
+	s := newTestServer()
+	s.geocoder = testGeocoder()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/geocode?q=springfield")
+	var found struct{ Results []place }
+	if err := json.Unmarshal(rec.Body.Bytes(), &found); err != nil || len(found.Results) != 2 {
+		t.Errorf("GET /geocode = %d %s, want both Springfields", rec.Code, rec.Body)
+	}
+
+	rec = get("/geocode/reverse?lat=39.8&lon=-89.64")
+	var at place
+	if err := json.Unmarshal(rec.Body.Bytes(), &at); err != nil || at.State != "Illinois" {
+		t.Errorf("GET /geocode/reverse = %d %s, want Springfield, Illinois", rec.Code, rec.Body)
+	}
+
+	s.geocoder = noGeocoder{}
+	for _, path := range []string{"/geocode?q=springfield", "/geocode/reverse?lat=39.8&lon=-89.64"} {
+		if rec := get(path); rec.Code != http.StatusNotImplemented {
+			t.Errorf("GET %s without a geocoder = %d, want 501", path, rec.Code)
+		}
+	}
+}
+
+// TestCitySuggestionsUseGeocoder checks an unknown city's 404 offers the
+// geocoder's places.
+func TestCitySuggestionsUseGeocoder(t *testing.T) {
+	defer func(enabled bool) { citySuggestionsEnabled = enabled }(citySuggestionsEnabled)
+	citySuggestionsEnabled = true
+	s := newTestServer()
+	s.geocoder = testGeocoder()
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/Springfield", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Springfield, Missouri, US") {
+		t.Errorf("GET /weather/Springfield = %d %q, want a 404 with suggestions", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "time"
+
+// goldenHourLength approximates the golden hour as the hour after sunrise
+// and the hour before sunset. The real window depends on latitude and
+// season, from well under an hour near the equator to much longer near
+// the poles, but an hour is the usual rule of thumb.
+const goldenHourLength = time.Hour
+
+// goldenHours is the morning and evening golden hour in the city's local
+// time.
+type goldenHours struct {
+	MorningStart, MorningEnd time.Time
+	EveningStart, EveningEnd time.Time
+}
+
+// GoldenHours derives the golden hours from sunrise and sunset. Each
+// window is cut to half the daylight so they never overlap on short
+// days; ok is false when there is no sunrise or sunset, as in polar day
+// or night.
+func (w WeatherData) GoldenHours() (g goldenHours, ok bool) {
+	if w.Sys.Sunrise == 0 || w.Sys.Sunset == 0 || w.Sys.Sunset <= w.Sys.Sunrise {
+		return goldenHours{}, false
+	}
+	sunrise, sunset := w.localTime(w.Sys.Sunrise), w.localTime(w.Sys.Sunset)
+	length := min(goldenHourLength, sunset.Sub(sunrise)/2)
+	return goldenHours{
+		MorningStart: sunrise,
+		MorningEnd:   sunrise.Add(length),
+		EveningStart: sunset.Add(-length),
+		EveningEnd:   sunset,
+	}, true
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// lastUpstreamSuccess holds the Unix time of the most recent successful
+// OpenWeather call.
+var lastUpstreamSuccess atomic.Int64
+
+func recordUpstreamSuccess() {
+	lastUpstreamSuccess.Store(time.Now().Unix())
+}
+
+// handleLivez reports that the process is up. It never checks dependencies,
+// so an upstream outage doesn't get the process restarted.
+func handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok"))
+}
+
+// keyHolder is implemented by providers that need an OpenWeather key.
+type keyHolder interface {
+	APIKey() (string, error)
+}
+
+// breakerHolder is implemented by providers guarded by a circuit breaker.
+type breakerHolder interface {
+	BreakerState() string
+}
+
+type healthReport struct {
+	Status              string  `json:"status"`
+	CacheEntries        int     `json:"cache_entries"`
+	LastUpstreamSuccess *string `json:"last_upstream_success"`
+	CircuitBreaker      string  `json:"circuit_breaker"`
+	APIKeyConfigured    bool    `json:"api_key_configured"`
+
+	DegradedReasons []string `json:"degraded_reasons,omitempty"`
+}
+
+// degradedReasons explains why the server is in degraded mode: the circuit
+// breaker is open or the cache is covering for upstream with stale data.
+// It is empty in normal operation, so recovery needs no extra step.
+func (s *server) degradedReasons() []string {
+	var reasons []string
+	if bh, ok := s.provider.(breakerHolder); ok && bh.BreakerState() == breakerOpen {
+		reasons = append(reasons, "circuit breaker open")
+	}
+	if s.cache.ServingStale() {
+		reasons = append(reasons, "serving stale data after upstream errors")
+	}
+	return reasons
+}
+
+// withServiceMode tags responses with X-Service-Mode: degraded while
+// degradedReasons is non-empty so clients know data may be stale.
+func (s *server) withServiceMode(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.degradedReasons()) > 0 {
+			w.Header().Set("X-Service-Mode", "degraded")
+		}
+		next(w, r)
+	}
+}
+
+// handleHealth summarises the server's state without calling upstream.
+// Every field comes from a mutex- or atomic-guarded source.
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report := healthReport{
+		Status:           "ok",
+		CacheEntries:     s.cache.Len(),
+		CircuitBreaker:   "n/a",
+		APIKeyConfigured: true,
+	}
+	if last := lastUpstreamSuccess.Load(); last != 0 {
+		t := time.Unix(last, 0).UTC().Format(time.RFC3339)
+		report.LastUpstreamSuccess = &t
+	}
+	if bh, ok := s.provider.(breakerHolder); ok {
+		report.CircuitBreaker = bh.BreakerState()
+	}
+	if kh, ok := s.provider.(keyHolder); ok {
+		_, err := kh.APIKey()
+		report.APIKeyConfigured = err == nil
+	}
+	if report.DegradedReasons = s.degradedReasons(); len(report.DegradedReasons) > 0 {
+		report.Status = "degraded"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleReadyz reports whether the server can serve weather: an API key
+// must be configured and, when READY_MAX_UPSTREAM_AGE is set, OpenWeather
+// must have answered successfully within that duration.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkReady(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ready"))
+}
+
+func (s *server) checkReady() error {
+	if kh, ok := s.provider.(keyHolder); ok {
+		if _, err := kh.APIKey(); err != nil {
+			return fmt.Errorf("api key: %w", err)
+		}
+	}
+	if raw := os.Getenv("READY_MAX_UPSTREAM_AGE"); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid READY_MAX_UPSTREAM_AGE: %w", err)
+		}
+		last := lastUpstreamSuccess.Load()
+		if last == 0 || time.Since(time.Unix(last, 0)) > maxAge {
+			return fmt.Errorf("no successful upstream call in the last %s", maxAge)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for the per-city observation history.
+var (
+	historySize      = max(1, envInt("HISTORY_SIZE", 24))
+	historyMaxCities = max(1, envInt("HISTORY_MAX_CITIES", 100))
+)
+
+// observation is one history entry. Temperatures are °C whatever units
+// the fetch used.
+type observation struct {
+	ObservedUnix int64     `json:"observed_unix"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Temperature  float64   `json:"temperature"`
+	Humidity     int       `json:"humidity"`
+	Condition    string    `json:"condition,omitempty"`
+}
+
+// cityHistory is a ring buffer: obs grows to its capacity, after which
+// next is the slot of the oldest observation and the next to overwrite.
+type cityHistory struct {
+	city string
+	obs  []observation
+	next int
+}
+
+func (h *cityHistory) add(o observation) {
+	if len(h.obs) > 0 && h.latest().ObservedUnix == o.ObservedUnix {
+		return
+	}
+	if len(h.obs) < cap(h.obs) {
+		h.obs = append(h.obs, o)
+		return
+	}
+	h.obs[h.next] = o
+	h.next = (h.next + 1) % len(h.obs)
+}
+
+func (h *cityHistory) latest() observation {
+	return h.obs[(h.next+len(h.obs)-1)%len(h.obs)]
+}
+
+// list returns the observations oldest first.
+func (h *cityHistory) list() []observation {
+	out := make([]observation, 0, len(h.obs))
+	out = append(out, h.obs[h.next:]...)
+	return append(out, h.obs[:h.next]...)
+}
+
+// historyStore keeps the last historySize observations for up to
+// historyMaxCities cities, forgetting the least recently updated city
+// first.
+type historyStore struct {
+	mu     sync.Mutex
+	cities map[string]*list.Element
+	lru    *list.List // of *cityHistory, front is most recently updated
+}
+
+func newHistoryStore() *historyStore {
+	return &historyStore{cities: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Record appends data to the city's history. A repeat of the latest
+// observation (same dt) is ignored.
+func (s *historyStore) Record(city string, data WeatherData) {
+	o := observation{
+		ObservedUnix: data.Dt,
+		FetchedAt:    time.Now().UTC(),
+		Temperature:  data.celsius(data.Main.Temp),
+		Humidity:     data.Main.Humidity,
+	}
+	o.Condition = data.condition().Main
+	key := cacheKey(city)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.cities[key]
+	if !ok {
+		el = s.lru.PushFront(&cityHistory{city: key, obs: make([]observation, 0, historySize)})
+		s.cities[key] = el
+		for s.lru.Len() > historyMaxCities {
+			oldest := s.lru.Back()
+			s.lru.Remove(oldest)
+			delete(s.cities, oldest.Value.(*cityHistory).city)
+		}
+	}
+	s.lru.MoveToFront(el)
+	el.Value.(*cityHistory).add(o)
+}
+
+// Len returns how many cities have a history.
+func (s *historyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Get returns the city's observations oldest first, or nil if none.
+func (s *historyStore) Get(city string) []observation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.cities[cacheKey(city)]
+	if !ok {
+		return nil
+	}
+	return el.Value.(*cityHistory).list()
+}
+
+// fetchCurrent is the provider call behind the weather cache. Every
+// successful fetch is also appended to the city's history.
+func (s *server) fetchCurrent(ctx context.Context, city, units string) (WeatherData, error) {
+	data, err := s.provider.Current(ctx, city, units)
+	if err != nil {
+		return WeatherData{}, err
+	}
+	s.history.Record(city, data)
+	return data, nil
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	if r.URL.Query().Has("dt") {
+		s.handleWeatherAt(w, r, city)
+		return
+	}
+	obs := s.history.Get(city)
+	if obs == nil {
+		obs = []observation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"city": city, "observations": obs})
+}
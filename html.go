@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// HTMLFormatter renders a small standalone page for browsers.
+type HTMLFormatter struct {
+	Units string
+	ReportOptions
+}
+
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Weather for {{.Location}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 2rem auto; }
+.temp { font-size: 3rem; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Location}}</h1>
+{{with .Temp}}<p class="temp" style="color: {{$.Color}}">{{.}}</p>
+{{end}}{{with .FeelsLike}}<p>Feels like {{.}}{{if $.Estimated}} (estimated){{end}}</p>
+{{end}}{{range .Warnings}}<p><strong>Warning:</strong> {{.}}</p>
+{{end}}{{with .Condition}}<p>{{.}}</p>
+{{end}}<p>Humidity {{.Humidity}}% · Wind {{.Wind}}</p>
+{{with .Advice}}<p>{{.}}</p>
+{{end}}{{if .Attribution}}<footer><small>Data provided by <a href="https://openweathermap.org/">OpenWeather</a></small></footer>
+{{end}}</body>
+</html>
+`))
+
+func (HTMLFormatter) ContentType() string { return "text/html; charset=utf-8" }
+
+func (f HTMLFormatter) Format(w WeatherData) string {
+	units := f.Units
+	if units == "" {
+		units = unitsMetric
+	}
+	labels, decimals := unitSetFor(units), 1
+	if f.Round {
+		decimals = 0
+	}
+	nf := f.numbers()
+	temp := func(v float64) string {
+		if !w.tempKnown(v) {
+			return notAvailable
+		}
+		return nf.format(formatTemp(convertTemp(v, w.Units, units), decimals)) + labels.Temp
+	}
+	feels, estimated := w.feelsLike()
+	feelsText := temp(feels)
+	if !w.feelsLikeKnown() {
+		feelsText = notAvailable
+	}
+
+	page := struct {
+		Location                Location
+		Temp, FeelsLike, Color  string
+		Estimated               bool
+		Warnings                []string
+		Condition, Wind, Advice string
+		Humidity                int
+		Attribution             bool
+	}{
+		Location:  w.Location(),
+		Temp:      temp(w.Main.Temp),
+		FeelsLike: feelsText,
+		Color:     tempColor(w.celsius(w.Main.Temp)),
+		Estimated: estimated && w.feelsLikeKnown(),
+		Warnings:  w.tempWarnings("°"),
+		Wind:      w.windTextIn(units, labels.Speed, nf),
+		Humidity:  w.Main.Humidity,
+
+		Attribution: f.attribution() != "",
+	}
+	if !f.showsActual() {
+		page.Temp = ""
+	}
+	if !f.showsFeelsLike() {
+		page.FeelsLike = ""
+	}
+	c := w.condition()
+	page.Condition = c.Main + " (" + c.Description + ")"
+	if f.Advice {
+		page.Advice = recommend(w)
+	}
+	var b strings.Builder
+	if err := reportPage.Execute(&b, page); err != nil {
+		return "<!DOCTYPE html><p>could not render report</p>"
+	}
+	return b.String()
+}
+
+// tempStops are the tempColor gradient: blue at or below 0°C, green at
+// 15°C, orange in the low 20s and red at or above 30°C.
+var tempStops = []struct {
+	c       float64
+	r, g, b float64
+}{
+	{0, 0x21, 0x66, 0xac},
+	{15, 0x1a, 0x98, 0x50},
+	{23, 0xf4, 0x6d, 0x43},
+	{30, 0xd7, 0x30, 0x1f},
+}
+
+// tempColor returns the CSS hex color for a temperature in °C,
+// interpolating linearly between tempStops.
+func tempColor(c float64) string {
+	lo, hi := tempStops[0], tempStops[len(tempStops)-1]
+	switch {
+	case c <= lo.c:
+		hi = lo
+	case c >= hi.c:
+		lo = hi
+	default:
+		for i := 1; i < len(tempStops); i++ {
+			if c <= tempStops[i].c {
+				lo, hi = tempStops[i-1], tempStops[i]
+				break
+			}
+		}
+	}
+	t := 0.0
+	if hi.c > lo.c {
+		t = (c - lo.c) / (hi.c - lo.c)
+	}
+	mix := func(a, b float64) int { return int(math.Round(a + (b-a)*t)) }
+	return fmt.Sprintf("#%02x%02x%02x", mix(lo.r, hi.r), mix(lo.g, hi.g), mix(lo.b, hi.b))
+}
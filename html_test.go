@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTempColor(t *testing.T) {
+	tests := []struct {
+		c    float64
+		want string
+	}{
+		{-10, "#2166ac"},
+		{0, "#2166ac"},
+		{7.5, "#1e7f7e"},
+		{15, "#1a9850"},
+		{23, "#f46d43"},
+		{30, "#d7301f"},
+		{45, "#d7301f"},
+	}
+	for _, tt := range tests {
+		if got := tempColor(tt.c); got != tt.want {
+			t.Errorf("tempColor(%v) = %s, want %s", tt.c, got, tt.want)
+		}
+	}
+}
+
+// TestHTMLFormatterEscapes checks the city name goes through html/template
+// escaping rather than straight into the page.
+func TestHTMLFormatterEscapes(t *testing.T) {
+	w := sampleWeather()
+	w.Name = "<script>alert(1)</script>"
+	out := HTMLFormatter{Units: unitsMetric}.Format(w)
+	if strings.Contains(out, "<script>") {
+		t.Errorf("city name was not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "&lt;script&gt;") {
+		t.Errorf("escaped city name missing from the page:\n%s", out)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// keyBenchDuration is how long a key that got a 401 or 429 is skipped.
+var keyBenchDuration = envDuration("KEY_BENCH_DURATION", time.Minute)
+
+// keyPool hands out OpenWeather keys round-robin, skipping keys that were
+// recently rejected so the others can carry the load.
+type keyPool struct {
+	keys    []string
+	next    int
+	mu      sync.Mutex
+	benched map[string]time.Time
+	now     func() time.Time
+}
+
+func newKeyPool(keys []string) *keyPool {
+	return &keyPool{keys: keys, benched: make(map[string]time.Time), now: time.Now}
+}
+
+// Next returns the next healthy key. If every key is benched it returns
+// the next one anyway rather than failing outright.
+func (p *keyPool) Next() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	start := p.next
+	p.next = (p.next + 1) % len(p.keys)
+	for i := range len(p.keys) {
+		idx := (start + i) % len(p.keys)
+		key := p.keys[idx]
+		if until, ok := p.benched[key]; !ok || now.After(until) {
+			delete(p.benched, key)
+			p.next = (idx + 1) % len(p.keys)
+			return key
+		}
+	}
+	return p.keys[start]
+}
+
+// Bench takes key out of rotation for d.
+func (p *keyPool) Bench(key string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.benched[key] = p.now().Add(d)
+}
+
+// apiKeyLength is the length of the hex keys OpenWeather issues.
+const apiKeyLength = 32
+
+// looksLikeAPIKey checks the shape of an OpenWeather key. A mismatch is
+// only warned about, since proxies and test doubles may use other keys.
+func looksLikeAPIKey(key string) bool {
+	if len(key) != apiKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseKeys splits a comma-separated key list and validates each key.
+// Whitespace around a key is trimmed with a warning, since it usually
+// means the key was pasted with a stray newline.
+func parseKeys(raw string) ([]string, error) {
+	var keys []string
+	for _, item := range strings.Split(raw, ",") {
+		key := strings.TrimSpace(item)
+		if key == "" {
+			continue
+		}
+		if key != item {
+			log.Printf("OpenWeather API key #%d has surrounding whitespace; it was trimmed", len(keys)+1)
+		}
+		if strings.ContainsFunc(key, unicode.IsSpace) {
+			return nil, errors.New("OpenWeather API key contains whitespace")
+		}
+		if !looksLikeAPIKey(key) {
+			log.Printf("OpenWeather API key #%d doesn't look like a key (expected %d hex characters); requests may fail with 401", len(keys)+1, apiKeyLength)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no OpenWeather API key configured")
+	}
+	return keys, nil
+}
+
+// loadKeys reads the key list from OPENWEATHER_API_KEY or, when that's
+// unset, from the config file. Either may hold several comma-separated
+// keys. A missing config file is only an error if the env var is unset.
+func (c *WeatherClient) loadKeys() ([]string, error) {
+	if raw := os.Getenv("OPENWEATHER_API_KEY"); raw != "" {
+		return parseKeys(raw)
+	}
+	apiConfig, err := loadApiConfig(c.configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no OpenWeather API key: set OPENWEATHER_API_KEY or create %s", c.configFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseKeys(apiConfig.OpenWeatherApiKey)
+}
+
+// loadKeysAtStartup retries loadKeys while the key source is unavailable,
+// for containers where the secret file is mounted a moment after the
+// process starts. STARTUP_KEY_RETRIES bounds the attempts (0, the default,
+// tries once) and STARTUP_KEY_RETRY_INTERVAL spaces them out.
+func (c *WeatherClient) loadKeysAtStartup() ([]string, error) {
+	retries := envInt("STARTUP_KEY_RETRIES", 0)
+	interval := envDuration("STARTUP_KEY_RETRY_INTERVAL", time.Second)
+	for attempt := 0; ; attempt++ {
+		keys, err := c.loadKeys()
+		if err == nil || attempt >= retries {
+			return keys, err
+		}
+		log.Printf("OpenWeather API key not available yet (attempt %d of %d): %v; retrying in %s", attempt+1, retries+1, err, interval)
+		time.Sleep(interval)
+	}
+}
+
+// APIKey returns the first configured key, or why none is available.
+func (c *WeatherClient) APIKey() (string, error) {
+	state := c.keys.Load()
+	if state.err != nil {
+		return "", state.err
+	}
+	return state.pool.keys[0], nil
+}
+
+// Reload re-reads the key sources and swaps in the keys. An invalid key
+// set is rejected and the current one stays in use.
+func (c *WeatherClient) Reload() error {
+	keys, err := c.loadKeys()
+	if err != nil {
+		return err
+	}
+	c.keys.Store(&apiKeyState{pool: newKeyPool(keys)})
+	return nil
+}
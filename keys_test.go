@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ".apiConfig")
+	if err := os.WriteFile(config, []byte(`{"OpenWeatherApiKey": "filekey1,filekey2"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name, env, file string
+		want            []string
+		wantErr         string
+	}{
+		{"env wins", "envkey", config, []string{"envkey"}, ""},
+		{"env without a file", "envkey1, envkey2", missing, []string{"envkey1", "envkey2"}, ""},
+		{"file", "", config, []string{"filekey1", "filekey2"}, ""},
+		{"neither", "", missing, nil, "set OPENWEATHER_API_KEY or create " + missing},
+	}
+	for _, tt := range tests {
+		t.Setenv("OPENWEATHER_API_KEY", tt.env)
+		c := &WeatherClient{configFile: tt.file}
+		keys, err := c.loadKeys()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("%s: loadKeys = %q, %v; want %q", tt.name, keys, err, tt.want)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	const key1, key2 = "0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210"
+	tests := []struct {
+		name, raw string
+		want      []string
+		wantErr   bool
+		wantLog   string
+	}{
+		{"single", key1, []string{key1}, false, ""},
+		{"list", key1 + "," + key2, []string{key1, key2}, false, ""},
+		{"trailing newline", key1 + "\n", []string{key1}, false, "key #1 has surrounding whitespace"},
+		{"space after comma", key1 + ", " + key2, []string{key1, key2}, false, "key #2 has surrounding whitespace"},
+		{"empty items", key1 + ",,", []string{key1}, false, ""},
+		{"wrong shape", "not-a-key", []string{"not-a-key"}, false, "key #1 doesn't look like a key"},
+		{"embedded space", "0123456789abcdef 0123456789abcdef", nil, true, ""},
+		{"none", " , ", nil, true, ""},
+	}
+	for _, tt := range tests {
+		var logged strings.Builder
+		log.SetOutput(&logged)
+		got, err := parseKeys(tt.raw)
+		log.SetOutput(os.Stderr)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseKeys = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+		if tt.wantLog == "" && logged.Len() > 0 {
+			t.Errorf("%s: unexpected warning %q", tt.name, logged.String())
+		}
+		if !strings.Contains(logged.String(), tt.wantLog) {
+			t.Errorf("%s: logged %q, want %q", tt.name, logged.String(), tt.wantLog)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Limits for client-defined labels. LABELS_FILE, when set, keeps them
+// across restarts; otherwise they live in memory only.
+var (
+	maxLabels  = max(0, envInt("LABELS_MAX", 100))
+	labelsFile = os.Getenv("LABELS_FILE")
+)
+
+var validLabel = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// labelTarget is what a label stands for: a city, or a lat/lon pair.
+type labelTarget struct {
+	City string   `json:"city,omitempty"`
+	Lat  *float64 `json:"lat,omitempty"`
+	Lon  *float64 `json:"lon,omitempty"`
+}
+
+// query validates t the way /weather/{city} and ?point= validate their
+// input, and returns it in canonical form with the query it resolves to.
+func (t labelTarget) query() (labelTarget, weatherQuery, error) {
+	switch {
+	case t.City != "" && (t.Lat != nil || t.Lon != nil):
+		return t, weatherQuery{}, errors.New("give either city or lat and lon, not both")
+	case t.City != "":
+		city, err := parseCityQuery(t.City)
+		if err != nil {
+			return t, weatherQuery{}, err
+		}
+		return labelTarget{City: city}, weatherQuery{city: city}, nil
+	case t.Lat == nil || t.Lon == nil:
+		return t, weatherQuery{}, errors.New("city, or both lat and lon, are required")
+	}
+	if allowedCities != nil {
+		return t, weatherQuery{}, fmt.Errorf("%w: coordinates while ALLOWED_CITIES is set", errCityNotAllowed)
+	}
+	p, err := parsePoint(fmt.Sprintf("%g,%g", *t.Lat, *t.Lon))
+	if err != nil {
+		return t, weatherQuery{}, err
+	}
+	return t, weatherQuery{at: &p}, nil
+}
+
+// labelStore maps labels to places, shared by every client.
+type labelStore struct {
+	mu     sync.RWMutex
+	labels map[string]labelTarget
+}
+
+// newLabelStore starts from LABELS_FILE. A file that can't be read is
+// logged and ignored, and is overwritten by the next change.
+func newLabelStore() *labelStore {
+	ls := &labelStore{labels: map[string]labelTarget{}}
+	if labelsFile == "" {
+		return ls
+	}
+	data, err := os.ReadFile(labelsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return ls
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &ls.labels)
+	}
+	if err != nil {
+		log.Printf("ignoring LABELS_FILE %s: %v", labelsFile, err)
+		ls.labels = map[string]labelTarget{}
+	}
+	return ls
+}
+
+func (ls *labelStore) Get(label string) (labelTarget, bool) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	t, ok := ls.labels[label]
+	return t, ok
+}
+
+func (ls *labelStore) All() map[string]labelTarget {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return maps.Clone(ls.labels)
+}
+
+var errTooManyLabels = errors.New("too many labels")
+
+// Update applies change to a copy of the labels and keeps the result only
+// if it fits in maxLabels and, with LABELS_FILE set, was saved.
+func (ls *labelStore) Update(change func(map[string]labelTarget)) (map[string]labelTarget, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	next := maps.Clone(ls.labels)
+	change(next)
+	if len(next) > maxLabels {
+		return nil, fmt.Errorf("%w: at most %d", errTooManyLabels, maxLabels)
+	}
+	if labelsFile != "" {
+		if err := saveLabels(next); err != nil {
+			return nil, err
+		}
+	}
+	ls.labels = next
+	return maps.Clone(next), nil
+}
+
+// saveLabels writes through a temporary file so a crash mid-write can't
+// leave LABELS_FILE truncated.
+func saveLabels(labels map[string]labelTarget) error {
+	data, err := json.MarshalIndent(labels, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := labelsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, labelsFile)
+}
+
+// reservedLabel reports whether /weather/label/{label} would be taken by
+// one of the /weather/{city}/... routes, with "label" as the city; see
+// weatherGroups.
+func (s *server) reservedLabel(label string) bool {
+	for _, rt := range s.routeTable() {
+		if sub, ok := strings.CutPrefix(rt.pattern, "GET /weather/{city}/"); ok && sub == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) handleListLabels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"labels": s.labels.All()})
+}
+
+// handleSetLabels adds or replaces every label in a JSON object of label
+// to {"city": ...} or {"lat": ..., "lon": ...}. Nothing is changed unless
+// all of them are valid.
+func (s *server) handleSetLabels(w http.ResponseWriter, r *http.Request) {
+	var req map[string]labelTarget
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req) == 0 {
+		http.Error(w, `expected an object of labels, e.g. {"home":{"city":"London,GB"}}`, http.StatusBadRequest)
+		return
+	}
+	add := make(map[string]labelTarget, len(req))
+	for label, t := range req {
+		if !validLabel.MatchString(label) {
+			http.Error(w, fmt.Sprintf("invalid label %q: expected up to 32 lowercase letters, digits, - or _", label), http.StatusBadRequest)
+			return
+		}
+		if s.reservedLabel(label) {
+			http.Error(w, fmt.Sprintf("label %q is reserved: /weather/label/%s is another route", label, label), http.StatusBadRequest)
+			return
+		}
+		t, _, err := t.query()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("label %q: %v", label, err), cityErrorStatus(err))
+			return
+		}
+		add[label] = t
+	}
+	labels, err := s.labels.Update(func(m map[string]labelTarget) {
+		maps.Copy(m, add)
+	})
+	if errors.Is(err, errTooManyLabels) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("saving labels: %v", err)
+		http.Error(w, "could not save labels", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"labels": labels})
+}
+
+func (s *server) handleDeleteLabel(w http.ResponseWriter, r *http.Request) {
+	label := r.PathValue("label")
+	if _, ok := s.labels.Get(label); !ok {
+		http.Error(w, fmt.Sprintf("no label %q", label), http.StatusNotFound)
+		return
+	}
+	if _, err := s.labels.Update(func(m map[string]labelTarget) { delete(m, label) }); err != nil {
+		log.Printf("saving labels: %v", err)
+		http.Error(w, "could not save labels", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWeatherByLabel answers /weather/label/{label} exactly as
+// /weather/{city} would for the place the label stands for.
+func (s *server) handleWeatherByLabel(w http.ResponseWriter, r *http.Request) {
+	label, format := splitFormatSuffix(r.PathValue("name"))
+	t, ok := s.labels.Get(label)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no label %q, add it with POST /labels", stripControl(label)), http.StatusNotFound)
+		return
+	}
+	// Re-validated because ALLOWED_CITIES may have changed since the label
+	// was saved, or the file edited by hand.
+	_, q, err := t.query()
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	if _, ok := s.provider.(CoordProvider); q.at != nil && !ok {
+		http.Error(w, errCoordsUnavailable.Error(), http.StatusNotImplemented)
+		return
+	}
+	s.serveWeather(w, r, q, format)
+}
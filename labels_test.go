@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestLabels saves a label for a city and one for coordinates, reads the
+// weather through them and removes one again.
+func TestLabels(t *testing.T) {
+	t.Setenv("SERVER_API_KEY", "secret")
+	p := &coordProvider{FakeProvider: newTestServer().provider.(*FakeProvider)}
+	h := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries)).routes()
+	do := func(method, path, body string, key bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key {
+			req.Header.Set("X-API-Key", "secret")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/labels", `{"home":{"city":"london"}}`, false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /labels without the key = %d, want 401", rec.Code)
+	}
+	for body, want := range map[string]int{
+		`{"home":{"city":"london"},"work":{"lat":51.5,"lon":-0.12}}`: http.StatusOK,
+		`{"Home":{"city":"london"}}`:                                 http.StatusBadRequest,
+		`{"stream":{"city":"london"}}`:                               http.StatusBadRequest,
+		`{"x":{"city":"london","lat":1,"lon":2}}`:                    http.StatusBadRequest,
+		`{"x":{"lat":91,"lon":0}}`:                                   http.StatusBadRequest,
+	} {
+		if rec := do(http.MethodPost, "/labels", body, true); rec.Code != want {
+			t.Errorf("POST /labels %s = %d, want %d: %s", body, rec.Code, want, rec.Body)
+		}
+	}
+
+	if rec := do(http.MethodGet, "/weather/label/home", "", false); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "London") {
+		t.Errorf("GET /weather/label/home = %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/weather/label/work", "", false); rec.Code != http.StatusOK || p.asked == nil || *p.asked != (point{Lat: 51.5, Lon: -0.12}) {
+		t.Errorf("GET /weather/label/work = %d, CurrentAt asked for %v", rec.Code, p.asked)
+	}
+	if rec := do(http.MethodGet, "/weather/other/home", "", false); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /weather/other/home = %d, want 404", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/labels/home", "", true); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE /labels/home = %d, want 204", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/weather/label/home", "", false); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /weather/label/home after delete = %d, want 404", rec.Code)
+	}
+}
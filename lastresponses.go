@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstreamRecord is one OpenWeather exchange as kept for debugging, with
+// the API key already redacted from the URL, body and error.
+type upstreamRecord struct {
+	At        time.Time `json:"at"`
+	URL       string    `json:"url"`
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// responseLog keeps the last few upstream exchanges in a ring.
+type responseLog struct {
+	mu      sync.Mutex
+	records []upstreamRecord
+	next    int
+	full    bool
+	// lastDump rate-limits Snapshot, see debugDumpInterval.
+	lastDump time.Time
+}
+
+// lastResponses keeps DEBUG_RESPONSES exchanges (0 turns it off), each
+// body cut to DEBUG_RESPONSE_MAX_BYTES.
+var (
+	lastResponses        = &responseLog{records: make([]upstreamRecord, max(0, envInt("DEBUG_RESPONSES", 20)))}
+	debugResponseMaxSize = max(0, envInt("DEBUG_RESPONSE_MAX_BYTES", 4<<10))
+	debugDumpInterval    = envDuration("DEBUG_DUMP_INTERVAL", time.Second)
+)
+
+const redacted = "REDACTED"
+
+// redactKey replaces every occurrence of key in s.
+func redactKey(s, key string) string {
+	if key == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, key, redacted)
+}
+
+// redactURL masks the APPID query parameter of an upstream URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	q := u.Query()
+	if q.Has("APPID") {
+		q.Set("APPID", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// Record adds an exchange. body may be nil, e.g. on a transport error.
+func (l *responseLog) Record(rawURL, key string, status int, body []byte, err error) {
+	if len(l.records) == 0 {
+		return
+	}
+	rec := upstreamRecord{At: time.Now(), URL: redactURL(rawURL), Status: status}
+	if err != nil {
+		rec.Error = redactKey(err.Error(), key)
+	}
+	if len(body) > debugResponseMaxSize {
+		body, rec.Truncated = body[:debugResponseMaxSize], true
+	}
+	rec.Body = redactKey(strings.ToValidUTF8(string(body), "�"), key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[l.next] = rec
+	l.next++
+	if l.next == len(l.records) {
+		l.next, l.full = 0, true
+	}
+}
+
+// Snapshot returns the kept exchanges, newest first. ok is false when the
+// last snapshot was under debugDumpInterval ago.
+func (l *responseLog) Snapshot(now time.Time) (out []upstreamRecord, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastDump) < debugDumpInterval {
+		return nil, false
+	}
+	l.lastDump = now
+	n := l.next
+	if l.full {
+		n = len(l.records)
+	}
+	out = make([]upstreamRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.records[(l.next-i+len(l.records))%len(l.records)])
+	}
+	return out, true
+}
+
+// handleLastResponses dumps the recent upstream exchanges. It sits behind
+// requireServerKey and answers at most once per debugDumpInterval.
+func (s *server) handleLastResponses(w http.ResponseWriter, r *http.Request) {
+	records, ok := lastResponses.Snapshot(time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many requests, try again shortly", http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"capacity": len(lastResponses.records), "responses": records})
+}
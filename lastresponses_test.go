@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLastResponsesRecordsWeatherNotTiles checks a weather call is kept
+// with the API key redacted, while a PNG tile is left out of the log.
+func TestLastResponsesRecordsWeatherNotTiles(t *testing.T) {
+	defer func(l *responseLog) { lastResponses = l }(lastResponses)
+	lastResponses = &responseLog{records: make([]upstreamRecord, 4)}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "clouds_new") {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+			return
+		}
+		w.Write([]byte(londonJSON))
+	}))
+	defer upstream.Close()
+	c := newTestClient(t, upstream)
+
+	if _, err := c.Current(context.Background(), "London", unitsMetric); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Tile(context.Background(), "clouds_new", 1, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := lastResponses.Snapshot(time.Now())
+	if len(records) != 1 {
+		t.Fatalf("%d records, want only the weather call: %+v", len(records), records)
+	}
+	rec := records[0]
+	if strings.Contains(rec.URL, "0123456789abcdef") || !strings.Contains(rec.URL, "APPID="+redacted) {
+		t.Errorf("URL %q isn't redacted", rec.URL)
+	}
+	if rec.Status != http.StatusOK || !strings.Contains(rec.Body, `"London"`) {
+		t.Errorf("record = %+v, want the 200 London body", rec)
+	}
+}
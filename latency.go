@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow keeps the most recent upstream call durations in a ring
+// so percentiles reflect current conditions rather than all-time ones.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// upstreamLatency times every OpenWeather call, retries included, from
+// sending the request to reading the last byte of the body.
+var upstreamLatency = newLatencyWindow(max(1, envInt("LATENCY_WINDOW", 1000)))
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, size)}
+}
+
+func (l *latencyWindow) Record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.next] = d
+	l.next++
+	if l.next == len(l.samples) {
+		l.next, l.full = 0, true
+	}
+}
+
+type latencySummary struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+// Summary returns nearest-rank percentiles in milliseconds. Sorting a copy
+// happens outside the lock so Record never waits on a /stats request.
+func (l *latencyWindow) Summary() latencySummary {
+	l.mu.Lock()
+	n := l.next
+	if l.full {
+		n = len(l.samples)
+	}
+	sorted := slices.Clone(l.samples[:n])
+	l.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return latencySummary{}
+	}
+	slices.Sort(sorted)
+	rank := func(p float64) float64 {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		i = min(max(i, 0), len(sorted)-1)
+		return float64(sorted[i].Microseconds()) / 1000
+	}
+	return latencySummary{Samples: len(sorted), P50: rank(0.50), P95: rank(0.95), P99: rank(0.99)}
+}
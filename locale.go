@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// numberFormat is how a locale writes numbers. The zero value is the
+// plain "1012.5" the reports have always used.
+type numberFormat struct {
+	decimal string
+	group   string
+}
+
+// numberFormats are the locales ?locale= and NUMBER_LOCALE accept, by
+// lower-case language tag. A regional tag not listed falls back on its
+// language, so "de-AT" formats like "de".
+var numberFormats = map[string]numberFormat{
+	"en":    {decimal: ".", group: ","},
+	"de":    {decimal: ",", group: "."},
+	"de-ch": {decimal: ".", group: "’"},
+	"es":    {decimal: ",", group: "."},
+	"it":    {decimal: ",", group: "."},
+	"nl":    {decimal: ",", group: "."},
+	"pt":    {decimal: ",", group: "."},
+	"fr":    {decimal: ",", group: " "}, // narrow no-break space
+	"pl":    {decimal: ",", group: " "},
+	"ru":    {decimal: ",", group: " "},
+	"sv":    {decimal: ",", group: " "},
+}
+
+// defaultLocale is NUMBER_LOCALE, used when a request has no ?locale=.
+// Unset, numbers keep the plain format.
+var defaultLocale = loadDefaultLocale()
+
+func loadDefaultLocale() string {
+	locale, err := parseLocale(os.Getenv("NUMBER_LOCALE"))
+	if err != nil {
+		log.Printf("ignoring NUMBER_LOCALE: %v", err)
+	}
+	return locale
+}
+
+// parseLocale validates ?locale=, accepting "de-AT" and "de_AT" for any
+// language listed in numberFormats. "" leaves the choice to defaultLocale.
+func parseLocale(raw string) (string, error) {
+	tag := strings.ReplaceAll(strings.ToLower(raw), "_", "-")
+	if tag == "" {
+		return "", nil
+	}
+	if _, ok := numberFormats[tag]; ok {
+		return tag, nil
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	if _, ok := numberFormats[lang]; ok {
+		return lang, nil
+	}
+	return "", fmt.Errorf("unsupported locale %q: expected one of %s", raw, strings.Join(sortedKeys(numberFormats), ", "))
+}
+
+// numbers returns the number format for the report's locale.
+func (o ReportOptions) numbers() numberFormat {
+	if o.Locale != "" {
+		return numberFormats[o.Locale]
+	}
+	return numberFormats[defaultLocale]
+}
+
+// format rewrites a number as strconv formats it, e.g. "-1234.5", in f's
+// style.
+func (f numberFormat) format(s string) string {
+	if f == (numberFormat{}) {
+		return s
+	}
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	if f.group != "" && len(whole) > 3 {
+		var b strings.Builder
+		for i, d := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteString(f.group)
+			}
+			b.WriteRune(d)
+		}
+		whole = b.String()
+	}
+	if hasFrac {
+		return sign + whole + f.decimal + frac
+	}
+	return sign + whole
+}
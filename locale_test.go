@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		raw, want string
+		ok        bool
+	}{
+		{"", "", true},
+		{"de", "de", true},
+		{"DE", "de", true},
+		{"de-AT", "de", true},
+		{"de_AT", "de", true},
+		{"de-CH", "de-ch", true},
+		{"xx", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseLocale(tt.raw)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseLocale(%q) = %q, %v; want %q, ok %v", tt.raw, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	tests := []struct {
+		locale, in, want string
+	}{
+		{"", "1012.5", "1012.5"},
+		{"en", "1012.5", "1,012.5"},
+		{"de", "1012", "1.012"},
+		{"de", "16.85", "16,85"},
+		{"de", "-1234567.8", "-1.234.567,8"},
+		{"fr", "1012.5", "1\u202f012,5"},
+		{"de", "999", "999"},
+	}
+	for _, tt := range tests {
+		if got := numberFormats[tt.locale].format(tt.in); got != tt.want {
+			t.Errorf("%q format(%q) = %q, want %q", tt.locale, tt.in, got, tt.want)
+		}
+	}
+}
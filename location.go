@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Location identifies the place a response is about. Every endpoint
+// builds it the same way so clients see one shape everywhere.
+type Location struct {
+	Name    string  `json:"name"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	// State is only known for places found by geocoding.
+	State string `json:"state,omitempty"`
+	// Timezone is the offset from UTC in seconds.
+	Timezone int `json:"timezone_offset"`
+}
+
+func (w WeatherData) Location() Location {
+	return Location{
+		Name:     w.Name,
+		Country:  w.Sys.Country,
+		Lat:      w.Coord.Lat,
+		Lon:      w.Coord.Lon,
+		Timezone: w.Timezone,
+	}
+}
+
+func (f ForecastData) Location() Location {
+	return Location{
+		Name:     f.City.Name,
+		Country:  f.City.Country,
+		Lat:      f.City.Coord.Lat,
+		Lon:      f.City.Coord.Lon,
+		Timezone: f.City.Timezone,
+	}
+}
+
+// String renders the location as "Name, CC".
+func (l Location) String() string {
+	if l.Country == "" {
+		return l.Name
+	}
+	return l.Name + ", " + l.Country
+}
+
+// setLocationHeaders describes the resolved location in response headers.
+func setLocationHeaders(w http.ResponseWriter, l Location) {
+	w.Header().Set("X-Location", stripControl(l.String()))
+	w.Header().Set("X-Coordinates", strconv.FormatFloat(l.Lat, 'f', -1, 64)+","+strconv.FormatFloat(l.Lon, 'f', -1, 64))
+}
@@ -1,18 +1,30 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
+	"unicode"
 )
 
 type WeatherData struct {
-	Name string `json:"name"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Base  string `json:"base"`
+	Coord struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"coord"`
+	// Dt is when OpenWeather took the observation, as a Unix time.
+	Dt int64 `json:"dt"`
+	// Timezone is the city's offset from UTC in seconds.
+	Timezone int `json:"timezone"`
+
 	Main struct {
 		Temp      float64 `json:"temp"`
 		FeelsLike float64 `json:"feels_like"`
@@ -20,14 +32,11 @@ type WeatherData struct {
 		TempMax   float64 `json:"temp_max"`
 		Pressure  int     `json:"pressure"`
 		Humidity  int     `json:"humidity"`
+		SeaLevel  int     `json:"sea_level,omitempty"`
+		GrndLevel int     `json:"grnd_level,omitempty"`
 	} `json:"main"`
-	Weather []struct {
-		ID          int    `json:"id"`
-		Main        string `json:"main"`
-		Description string `json:"description"`
-		Icon        string `json:"icon"`
-	} `json:"weather"`
-	Wind struct {
+	Weather []Condition `json:"weather"`
+	Wind    struct {
 		Speed float64 `json:"speed"`
 		Deg   int     `json:"deg"`
 	} `json:"wind"`
@@ -39,8 +48,45 @@ type WeatherData struct {
 		Sunrise int64  `json:"sunrise"`
 		Sunset  int64  `json:"sunset"`
 	} `json:"sys"`
+
+	// UVI is filled from the One Call API when it's enabled; HasUVI tells
+	// a genuine 0 (night time) apart from "not fetched".
+	UVI    float64 `json:"uvi,omitempty"`
+	HasUVI bool    `json:"-"`
+
+	// Units records which unit system the numbers above were fetched in.
+	Units string `json:"-"`
+	// FetchedAt is when the cache got this data from the provider; it is
+	// set on the copy Cache.Get returns, not on the stored entry.
+	FetchedAt time.Time `json:"-"`
+	// ExpiresAt is when that entry stops being fresh, set alongside
+	// FetchedAt.
+	ExpiresAt time.Time `json:"-"`
+	// UpstreamStatus is the HTTP status OpenWeather answered with, or 0
+	// for data from elsewhere, such as a fixture.
+	UpstreamStatus int `json:"-"`
+	// Historical marks a past observation from the timemachine, which
+	// reports leave undated by age.
+	Historical bool `json:"-"`
+	// DisplayZone, set from ?tz=, replaces the city's offset wherever a
+	// report shows a time.
+	DisplayZone *time.Location `json:"-"`
+	// NoWind and NoClouds are set when the response left that object out,
+	// so reports can say N/A rather than print zeros that read as calm
+	// and clear; see UnmarshalJSON.
+	NoWind   bool `json:"-"`
+	NoClouds bool `json:"-"`
 }
 
+const (
+	listenAddr     = ":8070"
+	maxCityLength  = 100
+	maxHeaderBytes = 16 << 10
+)
+
+// maxRequestBytes caps request bodies, see limitRequestSize.
+var maxRequestBytes = int64(envInt("MAX_BODY_BYTES", 1<<20))
+
 type ApiConfigData struct {
 	OpenWeatherApiKey string `json:"OpenWeatherApiKey"`
 }
@@ -51,105 +97,164 @@ func loadApiConfig(filename string) (ApiConfigData, error) {
 		return ApiConfigData{}, err
 	}
 	var api ApiConfigData
-	err = json.Unmarshal(bytes, &api)
+	err = decodeConfig(filename, bytes, &api)
 	if err != nil {
 		return ApiConfigData{}, err
 	}
 	return api, nil
 }
 
+var selfTest = flag.Bool("selftest", false, "check every endpoint against SELFTEST_CITY and exit, non-zero if any check fails")
+
 func main() {
-	router := http.NewServeMux()
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request){
-		w.Write([]byte("Welcome to the homepage, navigate to /weather/%your-query%"))
-	})
-	router.HandleFunc("/weather/{city}", func(w http.ResponseWriter, r *http.Request) {
-		city := r.PathValue("city")
-		data, err := query(city)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	flag.Parse()
+	provider, err := newProvider()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cache := NewCache(defaultCacheTTL, defaultCacheStaleTTL, envInt("CACHE_MAX_ENTRIES", defaultCacheMaxEntries))
+	cache.maxStaleAge = envDuration("MAX_STALE_AGE", defaultCacheMaxStale)
+	cache.ttlJitter = loadTTLJitter()
+	srv := newServer(provider, cache)
+	if *selfTest {
+		city := os.Getenv("SELFTEST_CITY")
+		if city == "" {
+			city = "London,GB"
 		}
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Write( []byte(data.FormatOutput()))
-	})
+		if srv.runSelfTest(srv.handler(), city, os.Stdout) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if client, ok := provider.(*WeatherClient); ok {
+		go reloadOnSIGHUP(client)
+	}
+	if cities := envList("WARM_CITIES", nil); len(cities) > 0 {
+		go warmCache(provider, cache, cities, envDuration("WARM_INTERVAL", defaultCacheTTL))
+	}
 	s := &http.Server{
-		Addr:    ":8070",
-		Handler: router,
+		Addr:           listenAddr,
+		Handler:        srv.handler(),
+		MaxHeaderBytes: maxHeaderBytes,
 	}
-	fmt.Println("Server Running on http://localhost:8070")
+	fmt.Println("Server Running on http://localhost" + listenAddr)
 	log.Fatal(s.ListenAndServe())
 }
 
-func query(city string) (WeatherData, error) {
-	apiConfig, err := loadApiConfig(".apiConfig")
-	if err != nil {
-		return WeatherData{}, err
+// newProvider serves OFFLINE_FIXTURE when it's set and OpenWeather
+// otherwise.
+func newProvider() (Provider, error) {
+	fixture := os.Getenv("OFFLINE_FIXTURE")
+	if fixture == "" {
+		client, err := NewWeatherClient()
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
 	}
-	url := fmt.Sprintf("http://api.openweathermap.org/data/2.5/weather?APPID=%s&q=%s", apiConfig.OpenWeatherApiKey, city)
-	resp, err := http.Get(url)
+	fp, err := NewFixtureProvider(fixture)
 	if err != nil {
-		return WeatherData{}, err
+		return nil, fmt.Errorf("loading OFFLINE_FIXTURE: %w", err)
 	}
-	defer resp.Body.Close()
+	log.Printf("OFFLINE MODE: serving %s for every city, OpenWeather will not be called", fixture)
+	return fp, nil
+}
 
-	// Read the entire response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return WeatherData{}, err
+// handler is the server's routes behind the middleware every request
+// passes through.
+func (s *server) handler() http.Handler {
+	mux := s.routes()
+	return withRequestID(withResponseTime(withCompression(withJSONEnvelope(limitRequestSize(withTrailingSlash(mux, withOptions(mux)))))))
+}
+
+// parseCityQuery accepts "city" or "city,CC" where CC is an ISO 3166
+// two-letter country code, and returns it in the form OpenWeather expects.
+func parseCityQuery(raw string) (string, error) {
+	raw = stripControl(raw)
+	if len(raw) > maxCityLength {
+		return "", fmt.Errorf("city must be at most %d characters", maxCityLength)
+	}
+	city, country, found := strings.Cut(raw, ",")
+	city = strings.TrimSpace(city)
+	if city == "" {
+		return "", errors.New("city is required")
+	}
+	if !cityAllowed(city) {
+		return "", fmt.Errorf("%w: %q", errCityNotAllowed, city)
 	}
+	if !found {
+		return city, nil
+	}
+	country = strings.TrimSpace(country)
+	if len(country) != 2 || !isASCIILetters(country) {
+		return "", fmt.Errorf("invalid country code %q: expected two letters", country)
+	}
+	return city + "," + strings.ToUpper(country), nil
+}
+
+// errCityNotAllowed is returned by parseCityQuery for cities outside
+// ALLOWED_CITIES; handlers answer it with 403 via cityErrorStatus.
+var errCityNotAllowed = errors.New("city is not allowed on this server")
+
+// errCoordsNotAllowed refuses coordinate queries, from /weather/points and
+// /zip/{zip}, while ALLOWED_CITIES is set: coordinates can't be checked
+// against city names, so an allowlist rules them out.
+var errCoordsNotAllowed = errors.New("coordinate lookups are disabled while ALLOWED_CITIES is set")
 
-	var weather WeatherData
-	if err := json.Unmarshal(body, &weather); err != nil {
-		return WeatherData{}, err
+// allowedCities holds the lowercased names from ALLOWED_CITIES, or is nil
+// when every city may be queried. Entries are names only, since the list
+// itself is comma-separated, so "London" allows London in any country.
+var allowedCities = loadAllowedCities()
+
+func loadAllowedCities() map[string]bool {
+	names := envList("ALLOWED_CITIES", nil)
+	if len(names) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[strings.ToLower(strings.Join(strings.Fields(name), " "))] = true
 	}
+	return allowed
+}
 
-	return weather, nil
+func cityAllowed(city string) bool {
+	return allowedCities == nil || allowedCities[strings.ToLower(strings.Join(strings.Fields(city), " "))]
 }
 
-func getWeatherEmoji(condition string) string {
-	switch strings.ToLower(condition) {
-	case "clear":
-		return "☀️"
-	case "clouds":
-		return "☁️"
-	case "rain":
-		return "🌧️"
-	case "drizzle":
-		return "🌦️"
-	case "thunderstorm":
-		return "⛈️"
-	case "snow":
-		return "❄️"
-	case "mist", "fog":
-		return "🌫️"
-	default:
-		return "🌈"
+// cityErrorStatus is the status for a parseCityQuery error.
+func cityErrorStatus(err error) int {
+	if errors.Is(err, errCityNotAllowed) {
+		return http.StatusForbidden
 	}
+	return http.StatusBadRequest
 }
 
-func (w WeatherData) FormatOutput() string {
-	var output strings.Builder
-
-	fmt.Fprintf(&output, "Weather Report for %s, %s 🌍\n", w.Name, w.Sys.Country)
-	fmt.Fprintf(&output, "==================================\n")
-	fmt.Fprintf(&output, "Temperature: %.2f°C (%.2f°F) 🌡️\n", w.Main.Temp-273.15, (w.Main.Temp-273.15)*9/5+32)
-	fmt.Fprintf(&output, "Feels like: %.2f°C (%.2f°F) 🤔\n", w.Main.FeelsLike-273.15, (w.Main.FeelsLike-273.15)*9/5+32)
-	fmt.Fprintf(&output, "Min/Max: %.2f°C / %.2f°C 📊\n", w.Main.TempMin-273.15, w.Main.TempMax-273.15)
-	fmt.Fprintf(&output, "Humidity: %d%% 💧\n", w.Main.Humidity)
-	fmt.Fprintf(&output, "Pressure: %d hPa 🔬\n", w.Main.Pressure)
+// stripControl drops control characters such as CR and LF so a crafted city
+// can't inject lines into logs or split response headers.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
 
-	if len(w.Weather) > 0 {
-		emoji := getWeatherEmoji(w.Weather[0].Main)
-		fmt.Fprintf(&output, "Condition: %s %s (%s)\n", emoji, w.Weather[0].Main, w.Weather[0].Description)
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
 	}
+	return true
+}
 
-	fmt.Fprintf(&output, "Wind: %.1f m/s, Direction: %d° 🌬️\n", w.Wind.Speed, w.Wind.Deg)
-	fmt.Fprintf(&output, "Cloudiness: %d%% ☁️\n", w.Clouds.All)
-
-	sunrise := time.Unix(w.Sys.Sunrise, 0).Format("15:04")
-	sunset := time.Unix(w.Sys.Sunset, 0).Format("15:04")
-	fmt.Fprintf(&output, "Sunrise: %s 🌅, Sunset: %s 🌇\n", sunrise, sunset)
+const unknownConditionEmoji = "🌈"
 
-	return output.String()
+// conditionEmoji is the emoji theme's symbol for a condition, whatever
+// SYMBOL_THEME says; the SVG badge can't rely on a Nerd Font.
+func conditionEmoji(main string, id int) string {
+	return conditionSymbol("emoji", main, id)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCityQuery(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"London", "London", false},
+		{" London , gb ", "London,GB", false},
+		{"Lon\r\ndon", "London", false},
+		{"London,G\x00B", "London,GB", false},
+		{"\r\n", "", true},
+		{"London,GBR", "", true},
+		{"London,G1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseCityQuery(tt.raw)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseCityQuery(%q) = %q, %v; want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLocationHeadersStripControl(t *testing.T) {
+	rec := httptest.NewRecorder()
+	setLocationHeaders(rec, Location{Name: "Lon\r\nX-Injected: 1", Country: "GB"})
+	if got := rec.Header().Get("X-Location"); got != "LonX-Injected: 1, GB" {
+		t.Errorf("X-Location = %q", got)
+	}
+}
+
+func TestAllowedCities(t *testing.T) {
+	defer func(prev map[string]bool) { allowedCities = prev }(allowedCities)
+	t.Setenv("ALLOWED_CITIES", "London, new  york")
+	allowedCities = loadAllowedCities()
+
+	for raw, wantErr := range map[string]bool{"London": false, "london,GB": false, "New York": false, "Paris": true} {
+		_, err := parseCityQuery(raw)
+		if (err != nil) != wantErr || (err != nil && cityErrorStatus(err) != http.StatusForbidden) {
+			t.Errorf("parseCityQuery(%q) = %v, want error %v with 403", raw, err, wantErr)
+		}
+	}
+
+	s := newTestServer()
+	for _, path := range []string{"/weather/Paris", "/weather/points?point=51.5,-0.12"} {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("GET %s = %d, want 403", path, rec.Code)
+		}
+	}
+
+	// /weather/here looks the located city up by name so it can be checked.
+	p := &coordProvider{FakeProvider: &FakeProvider{Weather: map[string]WeatherData{cacheKey("London,GB"): sampleWeather()}}}
+	s = newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	s.geo = StaticGeolocator{Location{Name: "London", Country: "GB", Lat: 51.5, Lon: -0.12}}
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/here", nil))
+	if rec.Code != http.StatusOK || p.asked != nil {
+		t.Errorf("GET /weather/here = %d, CurrentAt asked for %v; want 200 by name", rec.Code, p.asked)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import "unsafe"
+
+// Cache memory budgeting. The weather cache, the history store, the
+// /stats city counter and the GeoIP and UV caches are bounded by entry
+// counts, so
+// their worst case is estimated up front from a fixed size per entry. Tiles vary in size, so the tile cache gets a
+// byte limit instead: whatever CACHE_MEMORY_BUDGET leaves after the
+// others' worst cases, but never less than minTileCacheBytes. The figures
+// are estimates that bound growth, not exact accounting.
+const (
+	// approxWeatherEntryBytes is a decoded WeatherData plus its map, list
+	// and key overhead.
+	approxWeatherEntryBytes = 2 << 10
+	// approxCityTallyBytes is one cityCounter key, tally and map slot.
+	approxCityTallyBytes = 128
+	// approxGeoIPEntryBytes is one cached IP with its Location.
+	approxGeoIPEntryBytes = 192
+	// approxUVIEntryBytes is one rounded-coordinate key and its reading.
+	approxUVIEntryBytes = 96
+	minTileCacheBytes   = 4 << 20
+)
+
+// approxObservationBytes is one history slot, with room for a short
+// condition string.
+const approxObservationBytes = int64(unsafe.Sizeof(observation{})) + 16
+
+var cacheMemoryBudget = int64(envInt("CACHE_MEMORY_BUDGET", 64<<20))
+
+func weatherCacheBytes(entries int) int64 { return int64(entries) * approxWeatherEntryBytes }
+
+func historyBytes(cities int) int64 {
+	return int64(cities) * int64(historySize) * approxObservationBytes
+}
+
+func statsBytes(cities int) int64 { return int64(cities) * approxCityTallyBytes }
+
+func geoIPBytes(entries int) int64 { return int64(entries) * approxGeoIPEntryBytes }
+
+func uviBytes(entries int) int64 { return int64(entries) * approxUVIEntryBytes }
+
+// tileCacheBudget is the tile cache's share of cacheMemoryBudget given
+// the weather cache's entry limit; an unbounded weather cache is counted
+// at the default limit.
+func tileCacheBudget(weatherMaxEntries int) int64 {
+	if weatherMaxEntries <= 0 {
+		weatherMaxEntries = defaultCacheMaxEntries
+	}
+	rest := cacheMemoryBudget - weatherCacheBytes(weatherMaxEntries) - historyBytes(historyMaxCities) -
+		statsBytes(maxTrackedCities) - geoIPBytes(maxGeoIPEntries) - uviBytes(maxUVIEntries)
+	return max(rest, minTileCacheBytes)
+}
+
+type memoryUsage struct {
+	WeatherEntries int   `json:"weather_entries"`
+	WeatherBytes   int64 `json:"weather_bytes_est"`
+	TileEntries    int   `json:"tile_entries"`
+	TileBytes      int64 `json:"tile_bytes"`
+	TileMaxBytes   int64 `json:"tile_max_bytes"`
+	HistoryCities  int   `json:"history_cities"`
+	HistoryBytes   int64 `json:"history_bytes_est"`
+	StatsCities    int   `json:"stats_cities"`
+	StatsBytes     int64 `json:"stats_bytes_est"`
+	GeoIPEntries   int   `json:"geoip_entries"`
+	GeoIPBytes     int64 `json:"geoip_bytes_est"`
+	UVIEntries     int   `json:"uvi_entries"`
+	UVIBytes       int64 `json:"uvi_bytes_est"`
+	TotalBytes     int64 `json:"total_bytes_est"`
+	BudgetBytes    int64 `json:"budget_bytes"`
+}
+
+func (s *server) memoryUsage() memoryUsage {
+	u := memoryUsage{
+		WeatherEntries: s.cache.Len(),
+		HistoryCities:  s.history.Len(),
+		StatsCities:    requestStats.Len(),
+		UVIEntries:     uviReadings.Len(),
+		BudgetBytes:    cacheMemoryBudget,
+	}
+	if g, ok := s.geo.(*cachedGeolocator); ok {
+		u.GeoIPEntries = g.Len()
+	}
+	u.TileEntries, u.TileBytes = s.tiles.Usage()
+	u.TileMaxBytes = s.tiles.maxBytes
+	u.WeatherBytes = weatherCacheBytes(u.WeatherEntries)
+	u.HistoryBytes = historyBytes(u.HistoryCities)
+	u.StatsBytes = statsBytes(u.StatsCities)
+	u.GeoIPBytes = geoIPBytes(u.GeoIPEntries)
+	u.UVIBytes = uviBytes(u.UVIEntries)
+	u.TotalBytes = u.WeatherBytes + u.TileBytes + u.HistoryBytes + u.StatsBytes + u.GeoIPBytes + u.UVIBytes
+	return u
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics receives counters and timings from the cache and upstream
+// client. The default discards them; METRICS=prometheus keeps them for
+// /metrics in the Prometheus text format without pulling in its client.
+type Metrics interface {
+	Inc(name string)
+	Observe(name string, d time.Duration)
+}
+
+var metrics = loadMetrics()
+
+func loadMetrics() Metrics {
+	switch v := os.Getenv("METRICS"); v {
+	case "", "none":
+		return noopMetrics{}
+	case "prometheus":
+		return newPromMetrics("weather")
+	default:
+		log.Printf("ignoring METRICS=%q: expected prometheus or none", v)
+		return noopMetrics{}
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Inc(string)                    {}
+func (noopMetrics) Observe(string, time.Duration) {}
+
+type promSummary struct {
+	count uint64
+	sum   time.Duration
+}
+
+// promMetrics keeps counters and count/sum summaries and serves them as
+// Prometheus text exposition. Names are prefixed with namespace.
+type promMetrics struct {
+	namespace string
+	mu        sync.Mutex
+	counters  map[string]uint64
+	summaries map[string]promSummary
+}
+
+func newPromMetrics(namespace string) *promMetrics {
+	return &promMetrics{namespace: namespace, counters: make(map[string]uint64), summaries: make(map[string]promSummary)}
+}
+
+func (m *promMetrics) Inc(name string) {
+	m.mu.Lock()
+	m.counters[name]++
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	s := m.summaries[name]
+	s.count++
+	s.sum += d
+	m.summaries[name] = s
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	counters := make(map[string]uint64, len(m.counters))
+	for k, v := range m.counters {
+		counters[k] = v
+	}
+	summaries := make(map[string]promSummary, len(m.summaries))
+	for k, v := range m.summaries {
+		summaries[k] = v
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, name := range sortedKeys(counters) {
+		full := m.namespace + "_" + name + "_total"
+		fmt.Fprintf(w, "# TYPE %s counter\n%s %d\n", full, full, counters[name])
+	}
+	for _, name := range sortedKeys(summaries) {
+		full := m.namespace + "_" + name + "_seconds"
+		s := summaries[name]
+		fmt.Fprintf(w, "# TYPE %s summary\n%s_sum %g\n%s_count %d\n", full, full, s.sum.Seconds(), full, s.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleMetrics serves the metrics when the configured backend can.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	h, ok := metrics.(http.Handler)
+	if !ok {
+		http.Error(w, "metrics are disabled, set METRICS=prometheus", http.StatusNotFound)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPromMetricsExposition(t *testing.T) {
+	m := newPromMetrics("weather")
+	m.Inc("cache_misses")
+	m.Inc("cache_hits")
+	m.Inc("cache_hits")
+	m.Observe("upstream_request", 250*time.Millisecond)
+	m.Observe("upstream_request", 750*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	const want = `# TYPE weather_cache_hits_total counter
+weather_cache_hits_total 2
+# TYPE weather_cache_misses_total counter
+weather_cache_misses_total 1
+# TYPE weather_upstream_request_seconds summary
+weather_upstream_request_seconds_sum 1
+weather_upstream_request_seconds_count 2
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+// TestCacheCountsStatuses checks each Get outcome lands on its counter.
+func TestCacheCountsStatuses(t *testing.T) {
+	m := newPromMetrics("weather")
+	defer func(prev Metrics) { metrics = prev }(metrics)
+	metrics = m
+
+	c := NewCache(time.Minute, time.Minute, 0)
+	fetch := func(context.Context) (WeatherData, error) { return WeatherData{Name: "London"}, nil }
+	c.Get(context.Background(), "london", fetch)
+	c.Get(context.Background(), "london", fetch)
+	c.Get(context.Background(), "london", fetch)
+	if m.counters["cache_misses"] != 1 || m.counters["cache_hits"] != 2 {
+		t.Errorf("counters = %v, want 1 miss and 2 hits", m.counters)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	defer func(prev Metrics) { metrics = prev }(metrics)
+	metrics = noopMetrics{}
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d with metrics off, want 404", rec.Code)
+	}
+}
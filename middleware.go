@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requireServerKey rejects requests that don't present the key from
+// SERVER_API_KEY, either as "Authorization: Bearer <key>" or "X-API-Key".
+// When no key is configured the wrapped handler is unreachable.
+func requireServerKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasServerKey(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// hasServerKey reports whether r presents SERVER_API_KEY, for handlers
+// that gate only some options behind it.
+func hasServerKey(r *http.Request) bool {
+	want := os.Getenv("SERVER_API_KEY")
+	got := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// timedWriter stamps X-Response-Time just before the headers are sent,
+// since they can't be changed once the handler starts writing the body.
+type timedWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *timedWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		ms := float64(time.Since(tw.start).Microseconds()) / 1000
+		tw.Header().Set("X-Response-Time", strconv.FormatFloat(ms, 'f', 2, 64)+"ms")
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timedWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush a stream.
+func (tw *timedWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// withResponseTime reports how long the handler took to produce its
+// response in the X-Response-Time header.
+func withResponseTime(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&timedWriter{ResponseWriter: w, start: time.Now()}, r)
+	})
+}
+
+// limitRequestSize caps request bodies at maxRequestBytes, which
+// MAX_BODY_BYTES can change.
+func limitRequestSize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trailingSlashMode is TRAILING_SLASH: "redirect" (the default) answers
+// /weather/london/ with a redirect to /weather/london, "strip" serves it
+// directly as if the slash weren't there, and "off" leaves it to 404.
+var trailingSlashMode = loadTrailingSlashMode()
+
+func loadTrailingSlashMode() string {
+	switch v := os.Getenv("TRAILING_SLASH"); v {
+	case "":
+		return "redirect"
+	case "redirect", "strip", "off":
+		return v
+	default:
+		log.Printf("ignoring TRAILING_SLASH=%q: expected redirect, strip or off", v)
+		return "redirect"
+	}
+}
+
+// withTrailingSlash handles paths that only match a route once their
+// trailing slashes are dropped, per trailingSlashMode. Redirects are 301
+// for GET and HEAD and 308 otherwise, so the method and body survive.
+func withTrailingSlash(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if trailingSlashMode == "off" || path == "/" || !strings.HasSuffix(path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		trimmed := r.Clone(r.Context())
+		trimmed.URL.Path = strings.TrimRight(path, "/")
+		trimmed.URL.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+		if _, pattern := mux.Handler(trimmed); trimmed.URL.Path == "" || pattern == "" || pattern == "/" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if trailingSlashMode == "strip" {
+			next.ServeHTTP(w, trimmed)
+			return
+		}
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, trimmed.URL.RequestURI(), code)
+	})
+}
+
+// withOptions answers OPTIONS for every route registered on mux with 204
+// and an Allow header. Patterns without a method allow GET and HEAD.
+// Other methods a route doesn't accept get 405 with the same header; the
+// mux alone would hand them to the "/" not-found catch-all instead.
+func withOptions(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			if _, pattern := mux.Handler(r); pattern == "/" {
+				if allowed := allowedMethods(mux, r); len(allowed) > 0 {
+					w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+					http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+					return
+				}
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+		allowed := allowedMethods(mux, r)
+		if len(allowed) == 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowedMethods lists the methods mux would route for r's path. When
+// several patterns match, only the most specific path counts, and the "/"
+// not-found catch-all never counts.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	type match struct{ method, path string }
+	var matches []match
+	best := ""
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		_, pattern := mux.Handler(probe)
+		if pattern == "" || pattern == "/" {
+			continue
+		}
+		method, path, hasMethod := strings.Cut(pattern, " ")
+		if !hasMethod {
+			method, path = "", pattern
+		}
+		matches = append(matches, match{method, path})
+		if len(path) > len(best) {
+			best = path
+		}
+	}
+
+	var allowed []string
+	seen := make(map[string]bool)
+	add := func(methods ...string) {
+		for _, m := range methods {
+			if !seen[m] {
+				seen[m] = true
+				allowed = append(allowed, m)
+			}
+		}
+	}
+	for _, m := range matches {
+		switch {
+		case m.path != best:
+		case m.method == "":
+			add(http.MethodGet, http.MethodHead)
+		case m.method == http.MethodGet:
+			add(http.MethodGet, http.MethodHead)
+		default:
+			add(m.method)
+		}
+	}
+	return allowed
+}
+
+// withDeadline bounds the whole handler, upstream calls included, to the
+// duration in TIMEOUT_<name> (default def). The response is buffered so a
+// handler that misses the deadline can't write over the 504.
+func withDeadline(name string, def time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	timeout := envDuration("TIMEOUT_"+name, def)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		bw := &bufferedWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next(bw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			bw.mu.Lock()
+			defer bw.mu.Unlock()
+			for k, v := range bw.header {
+				w.Header()[k] = v
+			}
+			if bw.code == 0 {
+				bw.code = http.StatusOK
+			}
+			w.WriteHeader(bw.code)
+			w.Write(bw.body.Bytes())
+		case <-ctx.Done():
+			bw.mu.Lock()
+			defer bw.mu.Unlock()
+			bw.timedOut = true
+			if wantsJSON(r) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusGatewayTimeout)
+				json.NewEncoder(w).Encode(map[string]string{"error": "request timed out"})
+				return
+			}
+			http.Error(w, "request timed out after "+timeout.String(), http.StatusGatewayTimeout)
+		}
+	}
+}
+
+// bufferedWriter holds a response until withDeadline decides whether to
+// send it. Writes after the deadline are dropped.
+type bufferedWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (bw *bufferedWriter) Header() http.Header { return bw.header }
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// observedProvider reports the error of every Current and Forecast call,
+// so a test can see whether the provider gave up when the request did.
+type observedProvider struct {
+	*FakeProvider
+	errs chan error
+}
+
+func (p observedProvider) Current(ctx context.Context, city, units string) (WeatherData, error) {
+	data, err := p.FakeProvider.Current(ctx, city, units)
+	p.errs <- err
+	return data, err
+}
+
+func (p observedProvider) Forecast(ctx context.Context, city string, cnt int, units string) (ForecastData, error) {
+	data, err := p.FakeProvider.Forecast(ctx, city, cnt, units)
+	p.errs <- err
+	return data, err
+}
+
+// TestDeadlineCancelsProvider checks that a slow upstream gets a 504 at
+// TIMEOUT_WEATHER, and that the provider call is cancelled with it rather
+// than left running.
+func TestDeadlineCancelsProvider(t *testing.T) {
+	t.Setenv("TIMEOUT_WEATHER", "50ms")
+	p := observedProvider{
+		FakeProvider: &FakeProvider{
+			Weather: map[string]WeatherData{cacheKey("London"): sampleWeather()},
+			Delays:  map[string]time.Duration{cacheKey("London"): time.Minute},
+		},
+		errs: make(chan error, 1),
+	}
+	h := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries)).handler()
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("504 took %s, want about 50ms", elapsed)
+	}
+
+	select {
+	case err := <-p.errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("provider returned %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider call still running after the 504")
+	}
+}
+
+func TestWithTrailingSlash(t *testing.T) {
+	defer func(mode string) { trailingSlashMode = mode }(trailingSlashMode)
+	tests := []struct {
+		mode, method, path string
+		wantCode           int
+		wantLocation       string
+	}{
+		{"redirect", http.MethodGet, "/weather/London/", http.StatusMovedPermanently, "/weather/London"},
+		{"redirect", http.MethodGet, "/weather/London//?units=imperial", http.StatusMovedPermanently, "/weather/London?units=imperial"},
+		{"redirect", http.MethodPost, "/cache/clear/", http.StatusPermanentRedirect, "/cache/clear"},
+		{"redirect", http.MethodGet, "/no/such/route/", http.StatusNotFound, ""},
+		{"redirect", http.MethodGet, "/weather/London", http.StatusOK, ""},
+		{"strip", http.MethodGet, "/weather/London/", http.StatusOK, ""},
+		{"off", http.MethodGet, "/weather/London/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		trailingSlashMode = tt.mode
+		rec := httptest.NewRecorder()
+		newTestServer().handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantCode || rec.Header().Get("Location") != tt.wantLocation {
+			t.Errorf("%s: %s %s = %d, Location %q; want %d, %q", tt.mode, tt.method, tt.path, rec.Code, rec.Header().Get("Location"), tt.wantCode, tt.wantLocation)
+		}
+	}
+}
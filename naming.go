@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// jsonNaming is JSON_NAMING: "snake" (the default) keeps field names as
+// the structs declare them, "camel" rewrites them to camelCase on the way
+// out for clients that prefer it.
+var jsonNaming = loadJSONNaming()
+
+func loadJSONNaming() string {
+	switch v := os.Getenv("JSON_NAMING"); v {
+	case "":
+		return "snake"
+	case "snake", "camel":
+		return v
+	default:
+		log.Printf("ignoring JSON_NAMING=%q: expected snake or camel", v)
+		return "snake"
+	}
+}
+
+// snakeToCamel turns "feels_like" into "feelsLike".
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// camelCaseKeys rewrites every object key in a stream of JSON values,
+// keeping key order and each value's trailing newline.
+func camelCaseKeys(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out bytes.Buffer
+	for {
+		err := rewriteJSONValue(dec, &out)
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if end := dec.InputOffset(); end < int64(len(body)) && body[end] == '\n' {
+			out.WriteByte('\n')
+		}
+	}
+}
+
+func rewriteJSONValue(dec *json.Decoder, out *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		object := t == '{'
+		out.WriteRune(rune(t))
+		for first := true; dec.More(); first = false {
+			if !first {
+				out.WriteByte(',')
+			}
+			if object {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				out.WriteString(strconv.Quote(snakeToCamel(key.(string))))
+				out.WriteByte(':')
+			}
+			if err := rewriteJSONValue(dec, out); err != nil {
+				return err
+			}
+		}
+		end, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		out.WriteRune(rune(end.(json.Delim)))
+	case string:
+		b, _ := json.Marshal(t)
+		out.Write(b)
+	case json.Number:
+		out.WriteString(t.String())
+	case bool:
+		out.WriteString(strconv.FormatBool(t))
+	case nil:
+		out.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected JSON token %v", tok)
+	}
+	return nil
+}
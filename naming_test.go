@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestSnakeToCamel(t *testing.T) {
+	for in, want := range map[string]string{
+		"temp":           "temp",
+		"feels_like":     "feelsLike",
+		"data_age_human": "dataAgeHuman",
+		"trailing_":      "trailing",
+	} {
+		if got := snakeToCamel(in); got != want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCamelCaseKeys(t *testing.T) {
+	in := `{"feels_like":1.5,"sys":{"sun_rise":10},"list":[{"temp_min":-2}],"name":"new_york","ok":true,"x":null}` + "\n"
+	want := `{"feelsLike":1.5,"sys":{"sunRise":10},"list":[{"tempMin":-2}],"name":"new_york","ok":true,"x":null}` + "\n"
+	got, err := camelCaseKeys([]byte(in))
+	if err != nil || string(got) != want {
+		t.Errorf("camelCaseKeys = %s, %v; want %s", got, err, want)
+	}
+}
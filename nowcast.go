@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MinutelyProvider is implemented by providers that can report One Call
+// minute-by-minute precipitation.
+type MinutelyProvider interface {
+	Minutely(ctx context.Context, lat, lon float64) ([]MinutelyPrecip, error)
+}
+
+var errOneCallDisabled = errors.New("minutely precipitation needs the One Call API, set ONECALL_ENABLED=true")
+
+func (c *WeatherClient) Minutely(ctx context.Context, lat, lon float64) ([]MinutelyPrecip, error) {
+	if !oneCallEnabled {
+		return nil, errOneCallDisabled
+	}
+	data, err := c.OneCall(ctx, lat, lon, "current,hourly,daily,alerts")
+	if err != nil {
+		return nil, err
+	}
+	return data.Minutely, nil
+}
+
+// nowcast summarises the next hour of precipitation relative to now.
+type nowcast struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+	// Available is false when OpenWeather has no minutely data here.
+	Available  bool `json:"available"`
+	RainingNow bool `json:"raining_now"`
+	// ChangeInMinutes is when precipitation starts, or stops if it is
+	// raining now; nil if that doesn't happen within the hour.
+	ChangeInMinutes *int `json:"change_in_minutes,omitempty"`
+}
+
+func summariseMinutely(minutely []MinutelyPrecip, now time.Time) nowcast {
+	if len(minutely) == 0 {
+		return nowcast{Message: "minute-by-minute precipitation isn't available for this location"}
+	}
+	n := nowcast{Available: true, RainingNow: minutely[0].Precipitation > 0}
+	for _, m := range minutely[1:] {
+		if (m.Precipitation > 0) != n.RainingNow {
+			mins := max(0, int(time.Unix(m.Dt, 0).Sub(now).Round(time.Minute).Minutes()))
+			n.ChangeInMinutes = &mins
+			break
+		}
+	}
+	switch {
+	case n.RainingNow && n.ChangeInMinutes != nil:
+		n.Message = fmt.Sprintf("rain stopping in ~%d min", *n.ChangeInMinutes)
+	case n.RainingNow:
+		n.Message = "rain continuing for the next hour"
+	case n.ChangeInMinutes != nil:
+		n.Message = fmt.Sprintf("rain starting in ~%d min", *n.ChangeInMinutes)
+	default:
+		n.Message = "no rain expected in the next hour"
+	}
+	return n
+}
+
+// handleNowcast looks the city up through the weather cache for its
+// coordinates, then asks One Call for the next hour's precipitation.
+func (s *server) handleNowcast(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	mp, ok := s.provider.(MinutelyProvider)
+	if !ok || !oneCallEnabled {
+		http.Error(w, errOneCallDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+	units := fetchUnits("")
+	data, _, err := s.cache.Get(r.Context(), cacheKey(city, units), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, units)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	minutely, err := mp.Minutely(r.Context(), data.Coord.Lat, data.Coord.Lon)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	n := summariseMinutely(minutely, time.Now())
+	n.Location = data.Location()
+	setLocationHeaders(w, n.Location)
+	if r.URL.Query().Get("format") == "json" || wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s: %s\n", n.Location, n.Message)
+}
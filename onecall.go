@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// oneCallEnabled gates features that need a One Call 3.0 subscription.
+// Without one, OpenWeather answers those requests with 401.
+var oneCallEnabled = os.Getenv("ONECALL_ENABLED") == "true"
+
+type OneCallData struct {
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	Timezone       string  `json:"timezone"`
+	TimezoneOffset int     `json:"timezone_offset"`
+	Current        struct {
+		Dt  int64   `json:"dt"`
+		UVI float64 `json:"uvi"`
+	} `json:"current"`
+	// Minutely is the next hour's precipitation, in mm/h, one entry per
+	// minute. OpenWeather leaves it out for locations it can't cover.
+	Minutely []MinutelyPrecip `json:"minutely"`
+}
+
+type MinutelyPrecip struct {
+	Dt            int64   `json:"dt"`
+	Precipitation float64 `json:"precipitation"`
+}
+
+// OneCall fetches One Call data for a point. exclude is the
+// comma-separated list of blocks OpenWeather should leave out.
+func (c *WeatherClient) OneCall(ctx context.Context, lat, lon float64, exclude string) (OneCallData, error) {
+	params := url.Values{
+		"lat": {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon": {strconv.FormatFloat(lon, 'f', -1, 64)},
+	}
+	if exclude != "" {
+		params.Set("exclude", exclude)
+	}
+	var data OneCallData
+	if err := c.fetch(ctx, endpointOneCall, params, &data); err != nil {
+		return OneCallData{}, err
+	}
+	return data, nil
+}
+
+// maxUVIEntries bounds uviReadings; past it, expired readings are dropped
+// and, if that isn't enough, the rest too.
+const maxUVIEntries = 1000
+
+// uviCache holds One Call UV readings by location. The UV index moves
+// slowly, so a reading is kept for ONECALL_UVI_TTL rather than fetched
+// again with every weather refresh, which would double upstream calls.
+type uviCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	now      func() time.Time
+	readings map[string]uviReading
+}
+
+type uviReading struct {
+	uvi       float64
+	fetchedAt time.Time
+}
+
+var uviReadings = &uviCache{
+	ttl:      envDuration("ONECALL_UVI_TTL", time.Hour),
+	now:      time.Now,
+	readings: make(map[string]uviReading),
+}
+
+func (c *uviCache) get(key string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.readings[key]
+	if !ok || c.now().Sub(r.fetchedAt) >= c.ttl {
+		return 0, false
+	}
+	return r.uvi, true
+}
+
+func (c *uviCache) put(key string, uvi float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.readings) >= maxUVIEntries {
+		for k, r := range c.readings {
+			if now.Sub(r.fetchedAt) >= c.ttl {
+				delete(c.readings, k)
+			}
+		}
+		if len(c.readings) >= maxUVIEntries {
+			c.readings = make(map[string]uviReading)
+		}
+	}
+	c.readings[key] = uviReading{uvi: uvi, fetchedAt: now}
+}
+
+// Len returns how many readings are held, expired or not.
+func (c *uviCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.readings)
+}
+
+// currentUVI returns the UV index at a point, calling One Call only when
+// uviReadings has nothing recent within about a kilometre of it.
+func (c *WeatherClient) currentUVI(ctx context.Context, lat, lon float64) (float64, error) {
+	key := fmt.Sprintf("%.2f,%.2f", lat, lon)
+	if uvi, ok := uviReadings.get(key); ok {
+		return uvi, nil
+	}
+	data, err := c.OneCall(ctx, lat, lon, "minutely,hourly,daily,alerts")
+	if err != nil {
+		return 0, err
+	}
+	uviReadings.put(key, data.Current.UVI)
+	return data.Current.UVI, nil
+}
+
+// uviRisk returns the WHO exposure category for a UV index.
+func uviRisk(uvi float64) string {
+	switch {
+	case uvi < 3:
+		return "Low"
+	case uvi < 6:
+		return "Moderate"
+	case uvi < 8:
+		return "High"
+	case uvi < 11:
+		return "Very High"
+	default:
+		return "Extreme"
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUVIRisk(t *testing.T) {
+	for uvi, want := range map[float64]string{
+		0: "Low", 2.9: "Low", 3: "Moderate", 5.9: "Moderate", 6: "High",
+		7.9: "High", 8: "Very High", 10.9: "Very High", 11: "Extreme", 14: "Extreme",
+	} {
+		if got := uviRisk(uvi); got != want {
+			t.Errorf("uviRisk(%v) = %q, want %q", uvi, got, want)
+		}
+	}
+}
+
+// TestUVICache checks a reading is reused within the TTL, so weather
+// refreshes don't each cost a One Call request, and fetched again after.
+func TestUVICache(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	c := &uviCache{ttl: time.Hour, now: func() time.Time { return now }, readings: make(map[string]uviReading)}
+
+	if _, ok := c.get("51.51,-0.13"); ok {
+		t.Fatal("empty cache returned a reading")
+	}
+	c.put("51.51,-0.13", 6.2)
+	now = now.Add(59 * time.Minute)
+	if uvi, ok := c.get("51.51,-0.13"); !ok || uvi != 6.2 {
+		t.Errorf("get within the TTL = %v, %v; want 6.2", uvi, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.get("51.51,-0.13"); ok {
+		t.Error("get at the TTL returned a reading, want a refetch")
+	}
+
+	for i := range maxUVIEntries + 10 {
+		c.put(time.Duration(i).String(), 1)
+	}
+	if n := len(c.readings); n > maxUVIEntries {
+		t.Errorf("%d readings held, want at most %d", n, maxUVIEntries)
+	}
+}
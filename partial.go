@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// notAvailable stands in for a reading the response didn't include.
+const notAvailable = "N/A"
+
+// UnmarshalJSON decodes an OpenWeather response as usual, then notes which
+// optional objects were absent or null. A present object with zero values
+// is a real reading and stays as it is.
+func (w *WeatherData) UnmarshalJSON(b []byte) error {
+	type plain WeatherData
+	if err := json.Unmarshal(b, (*plain)(w)); err != nil {
+		return err
+	}
+	var present struct {
+		Wind   json.RawMessage `json:"wind"`
+		Clouds json.RawMessage `json:"clouds"`
+	}
+	if err := json.Unmarshal(b, &present); err != nil {
+		return err
+	}
+	w.NoWind = absentJSON(present.Wind)
+	w.NoClouds = absentJSON(present.Clouds)
+	return nil
+}
+
+func absentJSON(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// windText renders the wind speed with its label, or N/A.
+func (w WeatherData) windText(units, label string) string {
+	return w.windTextIn(units, label, numberFormat{})
+}
+
+// windTextIn is windText with the speed written in nf's style.
+func (w WeatherData) windTextIn(units, label string, nf numberFormat) string {
+	if w.NoWind {
+		return notAvailable
+	}
+	return nf.format(strconv.FormatFloat(w.windSpeed(units), 'f', 1, 64)) + " " + label
+}
+
+// cloudsText renders cloudiness as a percentage, or N/A.
+func (w WeatherData) cloudsText() string {
+	if w.NoClouds {
+		return notAvailable
+	}
+	return fmt.Sprintf("%d%%", w.Clouds.All)
+}
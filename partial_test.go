@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalMissingObjects(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		noWind, noClouds bool
+	}{
+		{"both present", `{"wind": {"speed": 0, "deg": 0}, "clouds": {"all": 0}}`, false, false},
+		{"absent", `{}`, true, true},
+		{"null", `{"wind": null, "clouds": null}`, true, true},
+		{"wind only", `{"wind": {"speed": 3}}`, false, true},
+	}
+	for _, tt := range tests {
+		var w WeatherData
+		if err := json.Unmarshal([]byte(tt.body), &w); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if w.NoWind != tt.noWind || w.NoClouds != tt.noClouds {
+			t.Errorf("%s: NoWind %v NoClouds %v, want %v %v", tt.name, w.NoWind, w.NoClouds, tt.noWind, tt.noClouds)
+		}
+	}
+}
+
+// TestMissingObjectsInReports checks missing readings show as N/A or null,
+// and a calm, clear reading of zeros is still reported as zeros.
+func TestMissingObjectsInReports(t *testing.T) {
+	w := sampleWeather()
+	w.NoWind, w.NoClouds = true, true
+
+	text := textReport(w, unitsMetric, ReportOptions{NoEmoji: true})
+	for _, want := range []string{"Wind: N/A\n", "Cloudiness: N/A\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(JSONFormatter{Units: unitsMetric}.Format(w)), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"wind_speed", "wind_deg", "clouds"} {
+		if v, ok := out[key]; !ok || v != nil {
+			t.Errorf("JSON %s = %v, want null", key, v)
+		}
+	}
+	if env := (EnvFormatter{Units: unitsMetric}).Format(w); strings.Contains(env, "WIND_") || strings.Contains(env, "CLOUDS=") {
+		t.Errorf("env output exports missing readings:\n%s", env)
+	}
+
+	calm := sampleWeather()
+	calm.Wind.Speed, calm.Wind.Deg, calm.Clouds.All = 0, 0, 0
+	text = textReport(calm, unitsMetric, ReportOptions{NoEmoji: true})
+	if !strings.Contains(text, "Wind: 0.0 m/s") || !strings.Contains(text, "Cloudiness: 0%") {
+		t.Errorf("zero readings not reported as zeros:\n%s", text)
+	}
+}
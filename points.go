@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxPoints caps how many ?point= pairs one request may ask for.
+var maxPoints = envInt("POINTS_MAX", 20)
+
+// parsePoint validates a "lat,lon" pair.
+func parsePoint(raw string) (point, error) {
+	rawLat, rawLon, ok := strings.Cut(raw, ",")
+	if !ok {
+		return point{}, fmt.Errorf("invalid point %q: expected lat,lon", raw)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(rawLat), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return point{}, fmt.Errorf("invalid latitude in point %q: expected -90 to 90", raw)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(rawLon), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return point{}, fmt.Errorf("invalid longitude in point %q: expected -180 to 180", raw)
+	}
+	return point{Lat: lat, Lon: lon}, nil
+}
+
+// pointResult is one point's outcome; a failed point carries Error and
+// Status instead of Weather so the others are still returned.
+type pointResult struct {
+	Point   point           `json:"point"`
+	Weather json.RawMessage `json:"weather,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Status  int             `json:"status,omitempty"`
+}
+
+// handleWeatherPoints returns the current weather at every ?point=, in
+// request order, fetched by batchWorkers workers through the cache.
+func (s *server) handleWeatherPoints(w http.ResponseWriter, r *http.Request) {
+	if allowedCities != nil {
+		http.Error(w, errCoordsNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+	if _, ok := s.provider.(CoordProvider); !ok {
+		http.Error(w, "coordinate lookups are not available", http.StatusNotImplemented)
+		return
+	}
+	raw := r.URL.Query()["point"]
+	switch {
+	case len(raw) == 0:
+		http.Error(w, "point is required, as ?point=lat,lon", http.StatusBadRequest)
+		return
+	case len(raw) > maxPoints:
+		http.Error(w, fmt.Sprintf("at most %d points per request", maxPoints), http.StatusBadRequest)
+		return
+	}
+	points := make([]point, len(raw))
+	for i, p := range raw {
+		var err error
+		if points[i], err = parsePoint(p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	units := requestUnits(r)
+	formatter, err := selectFormatter(units, "json", ReportOptions{
+		Advice: r.URL.Query().Get("advice") == "true",
+		Round:  queryBool(r, "round", false),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upstreamUnits := fetchUnits(units)
+	ctx, cancel := batchContext(r)
+	defer cancel()
+	results, done := collectUntil(ctx, len(points), batchWorkers, func(ctx context.Context, i int) (res pointResult) {
+		q := weatherQuery{at: &points[i]}
+		res.Point = points[i]
+		data, _, err := s.cache.Get(ctx, cacheKey(q.name(), upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+			return s.fetchQuery(ctx, q, upstreamUnits)
+		})
+		if err != nil {
+			res.Error, res.Status = err.Error(), queryErrorStatus(err)
+			return res
+		}
+		res.Weather = json.RawMessage(formatter.Format(data))
+		return res
+	})
+	timedOut := 0
+	for i := range results {
+		if !done[i] {
+			results[i] = pointResult{Point: points[i], Error: errBatchTimedOut.Error(), Status: http.StatusGatewayTimeout}
+			timedOut++
+		}
+	}
+
+	// The body is a bare array, so the partial result is flagged in a
+	// header rather than a note field.
+	if timedOut > 0 {
+		w.Header().Set("Warning", fmt.Sprintf(`199 - "%d of %d points timed out"`, timedOut, len(results)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Provider supplies weather data to the handlers. WeatherClient talks to
+// OpenWeather; FakeProvider returns canned data for tests. Calls give up
+// once ctx is done, so a request's deadline bounds its upstream calls.
+type Provider interface {
+	Current(ctx context.Context, city, units string) (WeatherData, error)
+	Forecast(ctx context.Context, city string, cnt int, units string) (ForecastData, error)
+}
+
+// CoordProvider is implemented by providers that can look weather up by
+// coordinates rather than city name.
+type CoordProvider interface {
+	CurrentAt(ctx context.Context, lat, lon float64, units string) (WeatherData, error)
+}
+
+var errCoordsUnavailable = errors.New("coordinate lookups are not available")
+
+// FakeProvider answers from in-memory maps keyed by cacheKey(city). A city
+// with an entry in Errors fails with that error; an unknown city fails
+// with a 404 UpstreamError, as OpenWeather would. Weather and forecasts
+// are returned in whatever Units they were stored with, regardless of the
+// units requested.
+// A city with an entry in Delays answers only after that long, or fails
+// with ctx.Err() if ctx is done first, standing in for a slow upstream.
+type FakeProvider struct {
+	Weather   map[string]WeatherData
+	Forecasts map[string]ForecastData
+	Errors    map[string]error
+	Delays    map[string]time.Duration
+}
+
+// wait holds a call for the city's delay.
+func (f *FakeProvider) wait(ctx context.Context, key string) error {
+	d, ok := f.Delays[key]
+	if !ok {
+		return ctx.Err()
+	}
+	return sleepCtx(ctx, d)
+}
+
+func (f *FakeProvider) Current(ctx context.Context, city, units string) (WeatherData, error) {
+	key := cacheKey(city)
+	if err := f.wait(ctx, key); err != nil {
+		return WeatherData{}, err
+	}
+	if err, ok := f.Errors[key]; ok {
+		return WeatherData{}, err
+	}
+	data, ok := f.Weather[key]
+	if !ok {
+		return WeatherData{}, &UpstreamError{StatusCode: http.StatusNotFound, Message: "city not found"}
+	}
+	return data, nil
+}
+
+func (f *FakeProvider) Forecast(ctx context.Context, city string, cnt int, units string) (ForecastData, error) {
+	key := cacheKey(city)
+	if err := f.wait(ctx, key); err != nil {
+		return ForecastData{}, err
+	}
+	if err, ok := f.Errors[key]; ok {
+		return ForecastData{}, err
+	}
+	data, ok := f.Forecasts[key]
+	if !ok {
+		return ForecastData{}, &UpstreamError{StatusCode: http.StatusNotFound, Message: "city not found"}
+	}
+	if cnt > 0 && cnt < len(data.List) {
+		data.List = data.List[:cnt]
+		data.Cnt = cnt
+	}
+	return data, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// londonJSON is a current-weather response for London in standard units,
+// as OpenWeather sends it.
+const londonJSON = `{
+	"coord": {"lon": -0.1257, "lat": 51.5085},
+	"weather": [{"id": 803, "main": "Clouds", "description": "broken clouds", "icon": "04d"}],
+	"base": "stations",
+	"main": {"temp": 288.15, "feels_like": 287.6, "temp_min": 286.9, "temp_max": 289.3, "pressure": 1012, "humidity": 72},
+	"wind": {"speed": 4.6, "deg": 240},
+	"clouds": {"all": 75},
+	"dt": 1718791200,
+	"sys": {"country": "GB", "sunrise": 1718768580, "sunset": 1718828540},
+	"timezone": 3600,
+	"id": 2643743,
+	"name": "London"
+}`
+
+func sampleWeather() WeatherData {
+	var w WeatherData
+	if err := json.Unmarshal([]byte(londonJSON), &w); err != nil {
+		panic(err)
+	}
+	w.Units = unitsStandard
+	return w
+}
+
+// newTestServer is a server answering from a FakeProvider that knows London.
+func newTestServer() *server {
+	return newServer(&FakeProvider{
+		Weather:   map[string]WeatherData{cacheKey("London"): sampleWeather()},
+		Forecasts: map[string]ForecastData{cacheKey("London"): sampleForecast()},
+	}, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+}
+
+func ExampleFakeProvider() {
+	p := &FakeProvider{Weather: map[string]WeatherData{cacheKey("London"): sampleWeather()}}
+
+	w, err := p.Current(context.Background(), "london", unitsMetric)
+	fmt.Printf("%s %.1f %v\n", w.Name, convertTemp(w.Main.Temp, w.Units, unitsMetric), err)
+
+	_, err = p.Current(context.Background(), "Atlantis", unitsMetric)
+	fmt.Println(err)
+	// Output:
+	// London 15.0 <nil>
+	// openweather returned status 404: city not found
+}
+
+func ExampleFakeProvider_errors() {
+	p := &FakeProvider{Errors: map[string]error{
+		cacheKey("London"): &UpstreamError{StatusCode: http.StatusServiceUnavailable},
+		cacheKey("Paris"):  errors.New("connection reset"),
+	}}
+
+	_, err := p.Current(context.Background(), "London", unitsMetric)
+	fmt.Println(err)
+	_, err = p.Forecast(context.Background(), "Paris", 0, unitsMetric)
+	fmt.Println(err)
+	// Output:
+	// openweather returned status 503
+	// connection reset
+}
+
+// Handlers take their Provider from newServer, so a FakeProvider drives
+// them without any network calls.
+func Example_newServer() {
+	s := newServer(&FakeProvider{
+		Weather: map[string]WeatherData{cacheKey("London"): sampleWeather()},
+	}, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	h := s.routes()
+
+	for _, path := range []string{"/weather/London", "/weather/London", "/weather/Atlantis"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		fmt.Println(path, rec.Code, rec.Header().Get("X-Cache"))
+	}
+	// Output:
+	// /weather/London 200 MISS
+	// /weather/London 200 HIT
+	// /weather/Atlantis 404
+}
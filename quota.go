@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+var errQuotaExhausted = errors.New("upstream call budget exhausted (UPSTREAM_QUOTA)")
+
+// quotaBuckets is how many slices the window is split into; calls expire
+// a slice at a time, so the count can run up to 1/quotaBuckets of the
+// window behind.
+const quotaBuckets = 60
+
+// quotaWindow counts upstream calls over a rolling window, to compare
+// against a provider's call budget before it starts answering 429.
+type quotaWindow struct {
+	mu        sync.Mutex
+	budget    int
+	enforce   bool
+	window    time.Duration
+	bucketLen time.Duration
+	counts    [quotaBuckets]int
+	// epochs records which bucketLen interval each slot counts, so slots
+	// left over from an earlier pass around the ring read as empty.
+	epochs [quotaBuckets]int64
+}
+
+// upstreamQuota tracks every OpenWeather attempt, retries included. With
+// UPSTREAM_QUOTA_ENFORCE=true, calls past UPSTREAM_QUOTA within
+// UPSTREAM_QUOTA_WINDOW fail with errQuotaExhausted, so the cache falls
+// back to stale data instead of spending calls the plan doesn't cover.
+var upstreamQuota = newQuotaWindow(
+	max(0, envInt("UPSTREAM_QUOTA", 0)),
+	envDuration("UPSTREAM_QUOTA_WINDOW", 24*time.Hour),
+	os.Getenv("UPSTREAM_QUOTA_ENFORCE") == "true",
+)
+
+func newQuotaWindow(budget int, window time.Duration, enforce bool) *quotaWindow {
+	window = max(window, quotaBuckets*time.Millisecond)
+	return &quotaWindow{budget: budget, enforce: enforce, window: window, bucketLen: window / quotaBuckets}
+}
+
+// usedLocked sums the buckets still inside the window ending at epoch.
+func (q *quotaWindow) usedLocked(epoch int64) int {
+	used := 0
+	for i := range q.counts {
+		if epoch-q.epochs[i] < quotaBuckets {
+			used += q.counts[i]
+		}
+	}
+	return used
+}
+
+// Take records a call at now. It refuses, recording nothing, when the
+// budget is enforced and already spent.
+func (q *quotaWindow) Take(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	epoch := now.UnixNano() / int64(q.bucketLen)
+	if q.enforce && q.budget > 0 && q.usedLocked(epoch) >= q.budget {
+		return false
+	}
+	slot := epoch % quotaBuckets
+	if q.epochs[slot] != epoch {
+		q.epochs[slot], q.counts[slot] = epoch, 0
+	}
+	q.counts[slot]++
+	return true
+}
+
+type quotaSummary struct {
+	Window    string `json:"window"`
+	Used      int    `json:"used"`
+	Budget    int    `json:"budget,omitempty"`
+	Remaining *int   `json:"remaining,omitempty"`
+	Enforced  bool   `json:"enforced"`
+}
+
+func (q *quotaWindow) Summary(now time.Time) quotaSummary {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := quotaSummary{
+		Window:   q.window.String(),
+		Used:     q.usedLocked(now.UnixNano() / int64(q.bucketLen)),
+		Budget:   q.budget,
+		Enforced: q.enforce && q.budget > 0,
+	}
+	if q.budget > 0 {
+		remaining := max(0, q.budget-s.Used)
+		s.Remaining = &remaining
+	}
+	return s
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reloadOnSIGHUP re-reads the API key whenever the process receives
+// SIGHUP, so the key can be rotated without a restart.
+func reloadOnSIGHUP(client *WeatherClient) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := client.Reload(); err != nil {
+			log.Printf("config reload failed, keeping current key: %v", err)
+			continue
+		}
+		log.Printf("config reloaded from %s", client.configFile)
+	}
+}
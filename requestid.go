@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+)
+
+// debugLogging is set by LOG_LEVEL=debug and enables debugf.
+var debugLogging = os.Getenv("LOG_LEVEL") == "debug"
+
+func debugf(format string, args ...any) {
+	if debugLogging {
+		log.Printf("debug: "+format, args...)
+	}
+}
+
+type requestIDKey struct{}
+
+// maxRequestIDLength caps an X-Request-ID taken from the client.
+const maxRequestIDLength = 64
+
+// withRequestID tags each request with an ID, the client's X-Request-ID
+// when it sent a usable one, and echoes it in the response. Writes that
+// fail, typically because the client went away, are logged at debug level
+// with that ID.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := stripControl(r.Header.Get("X-Request-ID"))
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		next.ServeHTTP(&writeErrorLogger{ResponseWriter: w, r: r}, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID withRequestID gave r, or "-" outside it.
+func requestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+// writeErrorLogger logs the first failed write of a response, with how
+// much of the body had been sent by then; later writes fail the same way
+// and add nothing.
+type writeErrorLogger struct {
+	http.ResponseWriter
+	r       *http.Request
+	written int64
+	failed  bool
+}
+
+func (wl *writeErrorLogger) Write(b []byte) (int, error) {
+	n, err := wl.ResponseWriter.Write(b)
+	wl.written += int64(n)
+	if err != nil && !wl.failed {
+		wl.failed = true
+		debugf("request %s: writing response to %s %s failed after %d bytes: %v",
+			requestID(wl.r), wl.r.Method, stripControl(wl.r.URL.Path), wl.written, err)
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (wl *writeErrorLogger) Unwrap() http.ResponseWriter {
+	return wl.ResponseWriter
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// brokenWriter accepts limit bytes and then fails, like a connection the
+// client closed mid-response.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (bw *brokenWriter) Write(b []byte) (int, error) {
+	if n := bw.Body.Len(); n+len(b) > bw.limit {
+		bw.ResponseRecorder.Write(b[:bw.limit-n])
+		return bw.limit - n, errors.New("broken pipe")
+	}
+	return bw.ResponseRecorder.Write(b)
+}
+
+// TestWriteErrorLoggerCountsResponse checks the failed write is logged once,
+// with the bytes sent over the whole response and the request ID, not just
+// the failing write's.
+func TestWriteErrorLoggerCountsResponse(t *testing.T) {
+	defer func(on bool) { debugLogging = on }(debugLogging)
+	debugLogging = true
+	defer log.SetOutput(log.Writer())
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 4 {
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/weather/London", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	h.ServeHTTP(&brokenWriter{ResponseRecorder: httptest.NewRecorder(), limit: 250}, req)
+
+	got := logs.String()
+	if want := "request req-42: writing response to GET /weather/London failed after 250 bytes: broken pipe"; !strings.Contains(got, want) {
+		t.Errorf("log lacks %q:\n%s", want, got)
+	}
+	if n := strings.Count(got, "failed after"); n != 1 {
+		t.Errorf("logged %d failures, want 1:\n%s", n, got)
+	}
+}
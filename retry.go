@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// retryPolicy decides which failed upstream calls are worth repeating. Only
+// transport errors and the listed statuses are retried; client errors such
+// as 400, 401 and 404 would just fail again and burn quota.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	statuses   map[int]bool
+	// decodeRetries is how many times fetch asks again for a body that
+	// wasn't valid JSON. Off by default, since each retry uses quota.
+	decodeRetries int
+}
+
+func loadRetryPolicy() retryPolicy {
+	p := retryPolicy{
+		maxRetries:    envInt("UPSTREAM_RETRIES", 2),
+		backoff:       envDuration("UPSTREAM_RETRY_BACKOFF", 200*time.Millisecond),
+		statuses:      make(map[int]bool),
+		decodeRetries: max(0, envInt("UPSTREAM_DECODE_RETRIES", 0)),
+	}
+	for _, raw := range envList("UPSTREAM_RETRY_STATUSES", []string{"502", "503", "504"}) {
+		code, err := strconv.Atoi(raw)
+		if err != nil || code < 500 || code > 599 {
+			log.Printf("ignoring non-5xx retry status %q", raw)
+			continue
+		}
+		p.statuses[code] = true
+	}
+	return p
+}
+
+// do sends the request built by newReq, retrying per the policy. It
+// returns as soon as a response with a non-retryable status arrives, so
+// the caller is the only one to ever read a body. Once ctx is done it
+// stops retrying and returns ctx.Err().
+func (p retryPolicy) do(ctx context.Context, client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		// Transport errors quote the URL, API key and all; keep it out of
+		// logs and responses.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		if err == nil && !p.statuses[resp.StatusCode] {
+			return resp, nil
+		}
+		if attempt >= p.maxRetries {
+			return resp, err
+		}
+		if err != nil {
+			log.Printf("upstream %s (attempt %d): %v", transportFailure(err), attempt+1, err)
+		} else {
+			log.Printf("upstream returned %d (attempt %d), retrying", resp.StatusCode, attempt+1)
+			resp.Body.Close()
+		}
+		if err := sleepCtx(ctx, p.backoff<<attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepCtx waits for d, or returns ctx.Err() if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// transportFailure names the kind of network error err is, so resolver
+// trouble stands out in the logs from refused connections and timeouts.
+// All of them are retried: a flaky resolver often answers on the next try.
+func transportFailure(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return "DNS lookup found no such host " + dnsErr.Name
+	case errors.As(err, &dnsErr):
+		return "DNS lookup of " + dnsErr.Name + " failed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "connection failed"
+	}
+	return "request failed"
+}
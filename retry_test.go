@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRetryStatuses checks which upstream statuses are asked for again: the
+// configured 5xx ones are, up to maxRetries, while client errors, which
+// would only fail again and burn quota, go back after one call.
+func TestRetryStatuses(t *testing.T) {
+	p := retryPolicy{maxRetries: 2, backoff: time.Millisecond, statuses: map[int]bool{502: true, 503: true, 504: true}}
+	for status, wantCalls := range map[int]int64{
+		http.StatusBadRequest:          1,
+		http.StatusUnauthorized:        1,
+		http.StatusNotFound:            1,
+		http.StatusTooManyRequests:     1,
+		http.StatusInternalServerError: 1,
+		http.StatusBadGateway:          3,
+		http.StatusServiceUnavailable:  3,
+		http.StatusGatewayTimeout:      3,
+	} {
+		var calls atomic.Int64
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(status)
+		}))
+		resp, err := p.do(context.Background(), upstream.Client(), func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, upstream.URL, nil)
+		})
+		if err != nil {
+			t.Errorf("%d: %v", status, err)
+		} else {
+			if resp.StatusCode != status {
+				t.Errorf("%d: got status %d", status, resp.StatusCode)
+			}
+			resp.Body.Close()
+		}
+		if got := calls.Load(); got != wantCalls {
+			t.Errorf("%d: %d upstream calls, want %d", status, got, wantCalls)
+		}
+		upstream.Close()
+	}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	var calls atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"name": "London"}`))
+	}))
+	defer upstream.Close()
+	p := retryPolicy{maxRetries: 2, backoff: time.Millisecond, statuses: map[int]bool{502: true}}
+
+	resp, err := p.do(context.Background(), upstream.Client(), func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, upstream.URL, nil)
+	})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("do = %v, %v; want 200 after a retried 502", resp, err)
+	}
+	resp.Body.Close()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d upstream calls, want 2", got)
+	}
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	t.Setenv("UPSTREAM_RETRY_STATUSES", "500,503,404,abc")
+	t.Setenv("UPSTREAM_RETRIES", "4")
+	p := loadRetryPolicy()
+	if p.maxRetries != 4 {
+		t.Errorf("maxRetries = %d, want 4", p.maxRetries)
+	}
+	if len(p.statuses) != 2 || !p.statuses[500] || !p.statuses[503] {
+		t.Errorf("statuses = %v, want only 500 and 503", p.statuses)
+	}
+}
+
+func TestTransportFailure(t *testing.T) {
+	wrap := func(err error) error { return &url.Error{Op: "Get", URL: "https://api.openweathermap.org", Err: err} }
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{wrap(&net.DNSError{Name: "api.openweathermap.org", IsNotFound: true}), "DNS lookup found no such host api.openweathermap.org"},
+		{wrap(&net.DNSError{Name: "api.openweathermap.org", Err: "server misbehaving"}), "DNS lookup of api.openweathermap.org failed"},
+		{wrap(&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}), "request timed out"},
+		{wrap(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), "connection failed"},
+		{wrap(errors.New("unexpected EOF")), "request failed"},
+	}
+	for _, tt := range tests {
+		if got := transportFailure(tt.err); got != tt.want {
+			t.Errorf("transportFailure(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
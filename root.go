@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// wantsJSON reports whether the client prefers JSON over plain text.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// handleRoot serves the homepage. WELCOME_MESSAGE replaces the text
+// greeting; JSON clients get the endpoint list instead.
+func (s *server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	endpoints := s.endpoints()
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"base_url": publicBaseURL(r), "endpoints": endpoints})
+		return
+	}
+	welcome := os.Getenv("WELCOME_MESSAGE")
+	if welcome == "" {
+		var b strings.Builder
+		b.WriteString("Welcome to the homepage, navigate to " + publicBaseURL(r) + "/weather/{city}\n\nEndpoints:\n")
+		for _, e := range endpoints {
+			line := "  " + strings.TrimSpace(e.Method+" "+e.Path) + " - " + e.Description
+			if len(e.Params) > 0 {
+				line += " (" + strings.Join(e.Params, ", ") + ")"
+			}
+			if e.Auth {
+				line += " [key required]"
+			}
+			b.WriteString(line + "\n")
+		}
+		welcome = b.String()
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(welcome))
+}
+
+// handleNotFound answers every path no other route matched, so only "/"
+// itself gets the homepage. NOT_FOUND_MESSAGE replaces the text body.
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not found", "path": stripControl(r.URL.Path)})
+		return
+	}
+	msg := os.Getenv("NOT_FOUND_MESSAGE")
+	if msg == "" {
+		msg = "404 page not found, see / for the list of endpoints"
+	}
+	http.Error(w, msg, http.StatusNotFound)
+}
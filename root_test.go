@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnknownRoutes(t *testing.T) {
+	h := newTestServer().handler()
+	tests := []struct {
+		method, path, accept string
+		wantStatus           int
+		wantBody             string
+	}{
+		{http.MethodGet, "/", "", http.StatusOK, "Welcome"},
+		{http.MethodGet, "/nope", "", http.StatusNotFound, "404 page not found"},
+		{http.MethodGet, "/nope", "application/json", http.StatusNotFound, `"path":"/nope"`},
+		{http.MethodGet, "/weather", "", http.StatusNotFound, "404 page not found"},
+		{http.MethodOptions, "/nope", "", http.StatusNotFound, "404 page not found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s = %d %q; want %d containing %q", tt.method, tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// route is one entry in the route registry. routes() registers every
+// entry and the homepage lists the documented ones, so both stay in sync.
+type route struct {
+	pattern     string
+	handler     http.HandlerFunc
+	description string
+	params      []string
+	// auth marks routes behind requireServerKey.
+	auth bool
+	// docPath, when set, is the path the homepage lists instead of the
+	// pattern's own.
+	docPath string
+	// docOnly routes are listed but not registered, because another
+	// entry's wildcard pattern serves them.
+	docOnly bool
+}
+
+// weatherGroups serves GET /weather/{group}/{name} from the handler for
+// its group. Patterns with a literal group such as /weather/label/{label}
+// can't be registered: ServeMux rejects them as clashing with
+// /weather/{city}/stream and the other per-city routes.
+func weatherGroups(groups map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h, ok := groups[r.PathValue("group")]
+		if !ok {
+			handleNotFound(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// endpointInfo is how a route is described to API clients.
+type endpointInfo struct {
+	Method      string   `json:"method,omitempty"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Params      []string `json:"params,omitempty"`
+	Auth        bool     `json:"auth,omitempty"`
+}
+
+func (s *server) routeTable() []route {
+	weather := []string{"units=metric|imperial|standard", "format=text|json|html|slack|env|voice|xml", "advice", "emoji", "round", "order=celsius|fahrenheit", "pressureUnit=hPa|inHg|mmHg", "theme=emoji|ascii|nerdfont", "goldenhour", "windspeeds", "show=actual|feelslike|both", "locale=en|de|fr|...", "tz=America/New_York", "attribution", "debug (key required)"}
+	return []route{
+		{pattern: "GET /{$}", handler: s.handleRoot},
+		{pattern: "/", handler: handleNotFound},
+		{pattern: "GET /api", handler: s.handleRoot, description: "This list of endpoints"},
+		{pattern: "GET /weather/{city}", handler: withDeadline("WEATHER", 15*time.Second, s.handleWeather),
+			description: "Current weather for a city, optionally as city,CC", params: weather},
+		{pattern: "GET /weather/{city}/stream", handler: s.handleWeatherStream,
+			description: "Server-Sent Events pushed when the cached weather changes", params: []string{"units", "round"}},
+		{pattern: "GET /weather/{city}/history", handler: withDeadline("WEATHER", 15*time.Second, s.handleHistory),
+			description: "Recent observations fetched for a city, oldest first, or with dt the weather at that time (needs ONECALL_ENABLED)", params: []string{"dt=<unix>", "units", "format=text|json|html|slack|env|voice", "round", "pressureUnit=hPa|inHg|mmHg", "theme=emoji|ascii|nerdfont", "goldenhour", "windspeeds", "show=actual|feelslike|both", "locale=en|de|fr|...", "tz=America/New_York", "attribution"}},
+		{pattern: "GET /weather/{city}/nowcast", handler: withDeadline("WEATHER", 15*time.Second, s.handleNowcast),
+			description: "Precipitation over the next hour (needs ONECALL_ENABLED)", params: []string{"format=text|json"}},
+		{pattern: "GET /weather/{city}/normal", handler: withDeadline("WEATHER", 15*time.Second, s.handleSeasonalNormal),
+			description: "Current temperature compared with the month's average (built in, or CLIMATE_NORMALS_FILE)", params: []string{"units", "format=text|json"}},
+		{pattern: "GET /weather/{city}/feelslike", handler: withDeadline("WEATHER", 15*time.Second, s.handleFeelsLike),
+			description: "How much warmer or colder it feels than the actual temperature, and why", params: []string{"units", "format=text|json"}},
+		{pattern: "GET /weather/points", handler: withDeadline("BATCH", 60*time.Second, s.handleWeatherPoints),
+			description: "Current weather at several coordinates, as a JSON array", params: []string{"point=lat,lon (repeatable)", "units", "advice", "round"}},
+		{pattern: "GET /weather/box", handler: withDeadline("BATCH", 60*time.Second, s.handleWeatherBox),
+			description: "Current weather in the cities inside a bounding box, as a JSON array or a summary", params: []string{"bbox=lon1,lat1,lon2,lat2", "zoom=1..18", "units", "format=json|summary"}},
+		{pattern: "GET /weather/{city}/detail", handler: withDeadline("WEATHER", 15*time.Second, s.handleWeatherDetail),
+			description: "Every derived value (dew point, heat index, daylight, comfort, ...) as one JSON object"},
+		{pattern: "GET /weather/{city}/badge.svg", handler: withDeadline("WEATHER", 15*time.Second, s.handleBadge),
+			description: "SVG badge with the current temperature, for embedding", params: []string{"units"}},
+		{pattern: "GET /weather/{city}/emoji", handler: withDeadline("WEATHER", 15*time.Second, s.handleEmoji),
+			description: "Just the condition emoji, as plain text", params: []string{"theme=emoji|ascii|nerdfont"}},
+		{pattern: "GET /weather/here", handler: withDeadline("WEATHER", 15*time.Second, s.handleWeatherHere),
+			description: "Current weather for the caller's IP location", params: weather},
+		{pattern: "GET /weather/{group}/{name}", handler: weatherGroups(map[string]http.HandlerFunc{
+			"label":   withDeadline("WEATHER", 15*time.Second, s.handleWeatherByLabel),
+			"country": withDeadline("BATCH", 60*time.Second, s.handleCountryWeather),
+		}), description: "Current weather for a label saved with POST /labels", params: weather, docPath: "/weather/label/{label}"},
+		{pattern: "GET /weather/country/{code}", docOnly: true,
+			description: "Current weather in a country's major cities, as a JSON array or a table", params: []string{"limit=N", "format=json|text", "units", "advice", "round"}},
+		{pattern: "GET /labels", handler: requireServerKey(s.handleListLabels),
+			description: "Saved labels and the places they stand for", auth: true},
+		{pattern: "POST /labels", handler: requireServerKey(s.handleSetLabels),
+			description: "Add or replace labels", params: []string{`body {"home":{"city":"London,GB"},"work":{"lat":N,"lon":N}}`}, auth: true},
+		{pattern: "DELETE /labels/{label}", handler: requireServerKey(s.handleDeleteLabel),
+			description: "Remove a label", auth: true},
+		{pattern: "GET /geocode", handler: withDeadline("WEATHER", 15*time.Second, s.handleGeocode),
+			description: "Places matching a name, best first", params: []string{"q=city[,CC]"}},
+		{pattern: "GET /geocode/reverse", handler: withDeadline("WEATHER", 15*time.Second, s.handleReverseGeocode),
+			description: "The place at a pair of coordinates", params: []string{"lat", "lon"}},
+		{pattern: "GET /zip/{zip}", handler: withDeadline("WEATHER", 15*time.Second, s.handleWeatherByZip),
+			description: "Current weather for a postal code; US, CA and GB codes don't need a country", params: append([]string{"country=CC"}, weather...)},
+		{pattern: "GET /forecast/{city}", handler: withDeadline("FORECAST", 30*time.Second, s.handleForecast),
+			description: "5 day / 3 hour forecast", params: []string{"cnt=1..40", "format=text|json", "units=metric|imperial|standard", "tz=America/New_York"}},
+		{pattern: "GET /forecast/{city}/daily", handler: withDeadline("FORECAST", 30*time.Second, s.handleDailyForecast),
+			description: "Daily min/max/average rollup of the forecast", params: []string{"units=metric|imperial|standard", "days=1..5"}},
+		{pattern: "GET /forecast/{city}/calendar", handler: withDeadline("FORECAST", 30*time.Second, s.handleForecastCalendar),
+			description: "Week view of the daily rollup, one row per day, as plain text", params: []string{"days=1..5", "units", "theme=emoji|ascii|nerdfont", "tz=America/New_York"}},
+		{pattern: "POST /forecast/batch", handler: withDeadline("BATCH", 60*time.Second, s.handleBatchForecast),
+			description: "Daily rollups for several cities", params: []string{`body {"cities":[...],"days":N}`, "units", "mode=partial|strict"}},
+		{pattern: "GET /forecast/{city}/delta", handler: withDeadline("FORECAST", 30*time.Second, s.handleForecastDelta),
+			description: "Expected temperature change over the next 24h", params: []string{"format=text|json"}},
+		{pattern: "GET /forecast/{city}/sparkline", handler: withDeadline("FORECAST", 30*time.Second, s.handleForecastSparkline),
+			description: "Sparkline of temperatures over the next 24h, as plain text", params: []string{"units"}},
+		{pattern: "GET /tiles/{layer}/{z}/{x}/{y}", handler: withDeadline("TILES", 15*time.Second, s.handleTile),
+			description: "Weather map tile proxy, {y} ends in .png"},
+		{pattern: "GET /stats", handler: s.handleStats,
+			description: "Most requested cities, upstream latency percentiles and call quota", params: []string{"limit=1..100", "sort=count|alpha"}},
+		{pattern: "GET /metrics", handler: handleMetrics, description: "Prometheus metrics (needs METRICS=prometheus)"},
+		{pattern: "GET /health", handler: s.handleHealth, description: "Cache, upstream and circuit breaker status"},
+		{pattern: "GET /livez", handler: handleLivez, description: "Liveness check"},
+		{pattern: "GET /readyz", handler: s.handleReadyz, description: "Readiness check"},
+		{pattern: "POST /cache/clear", handler: requireServerKey(s.handleCacheClear),
+			description: "Evict cached weather", params: []string{"city"}, auth: true},
+		{pattern: "GET /debug/config", handler: requireServerKey(s.handleDebugConfig),
+			description: "Effective configuration with secrets redacted", auth: true},
+		{pattern: "GET /debug/last-responses", handler: requireServerKey(s.handleLastResponses),
+			description: "The last few raw OpenWeather responses, key redacted", auth: true},
+	}
+}
+
+func (s *server) routes() *http.ServeMux {
+	router := http.NewServeMux()
+	for _, rt := range s.routeTable() {
+		if rt.docOnly {
+			continue
+		}
+		router.HandleFunc(rt.pattern, s.withServiceMode(rt.handler))
+	}
+	return router
+}
+
+// endpoints describes the documented routes; a route without a
+// description is internal and left out.
+func (s *server) endpoints() []endpointInfo {
+	var out []endpointInfo
+	for _, rt := range s.routeTable() {
+		if rt.description == "" {
+			continue
+		}
+		method, path, found := strings.Cut(rt.pattern, " ")
+		if !found {
+			method, path = "", rt.pattern
+		}
+		if rt.docPath != "" {
+			path = rt.docPath
+		}
+		out = append(out, endpointInfo{Method: method, Path: path, Description: rt.description, Params: rt.params, Auth: rt.auth})
+	}
+	return out
+}
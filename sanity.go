@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Plausible surface air temperatures. Anything outside this range almost
+// always means mismatched units or a corrupt/placeholder response.
+const (
+	minPlausibleCelsius = -100.0
+	maxPlausibleCelsius = 60.0
+)
+
+// strictSanity turns implausible readings into errors instead of warnings.
+var strictSanity = os.Getenv("STRICT_SANITY") == "true"
+
+// checkTemperature reports an error when the reading is outside the
+// plausible range.
+func checkTemperature(w WeatherData) error {
+	c := w.celsius(w.Main.Temp)
+	if c < minPlausibleCelsius || c > maxPlausibleCelsius {
+		return fmt.Errorf("implausible temperature for %s: %.2f°C", w.Name, c)
+	}
+	return nil
+}
+
+// ABSOLUTE_ZERO_GUARD settings for a temperature of exactly 0 K, which is
+// what an incomplete response in standard units decodes to.
+const (
+	zeroGuardNA     = "na"     // show it as N/A (the default)
+	zeroGuardStrict = "strict" // reject the response
+	zeroGuardOff    = "off"    // report it as fetched
+)
+
+var absoluteZeroGuard = loadAbsoluteZeroGuard()
+
+func loadAbsoluteZeroGuard() string {
+	switch v := os.Getenv("ABSOLUTE_ZERO_GUARD"); v {
+	case "":
+		return zeroGuardNA
+	case zeroGuardNA, zeroGuardStrict, zeroGuardOff:
+		return v
+	default:
+		log.Printf("ignoring invalid ABSOLUTE_ZERO_GUARD=%q: expected na, strict or off", v)
+		return zeroGuardNA
+	}
+}
+
+var errAbsoluteZero = errors.New("temperature of exactly 0 K, the reading is likely missing")
+
+// tempKnown is false for a reading of exactly 0 K, unless the guard is
+// off. A 0 fetched in metric or imperial units is a real reading; "" is
+// standard, as convertTemp takes it.
+func (w WeatherData) tempKnown(v float64) bool {
+	inKelvin := w.Units == unitsStandard || w.Units == ""
+	return absoluteZeroGuard == zeroGuardOff || !inKelvin || v != 0
+}
+
+// feelsLikeKnown is false when there is no feels-like reading to show:
+// upstream sent none and the temperature to estimate it from is missing.
+func (w WeatherData) feelsLikeKnown() bool {
+	_, estimated := w.feelsLike()
+	return !estimated || w.tempKnown(w.Main.Temp)
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestCheckTemperature(t *testing.T) {
+	tests := []struct {
+		temp  float64
+		units string
+		ok    bool
+	}{
+		{288.15, unitsStandard, true},
+		{15, unitsMetric, true},
+		{59, unitsImperial, true},
+		{-100, unitsMetric, true},
+		{60, unitsMetric, true},
+		{-100.1, unitsMetric, false},
+		{60.1, unitsMetric, false},
+		{0, unitsStandard, false},
+		// A Kelvin reading taken for Celsius is the usual mistake.
+		{288.15, unitsMetric, false},
+		{150, unitsImperial, false},
+	}
+	for _, tt := range tests {
+		w := WeatherData{Name: "London", Units: tt.units}
+		w.Main.Temp = tt.temp
+		if err := checkTemperature(w); (err == nil) != tt.ok {
+			t.Errorf("checkTemperature(%v %s) = %v, want ok=%v", tt.temp, tt.units, err, tt.ok)
+		}
+	}
+}
+
+func TestTempKnown(t *testing.T) {
+	defer func(guard string) { absoluteZeroGuard = guard }(absoluteZeroGuard)
+	tests := []struct {
+		guard string
+		units string
+		temp  float64
+		want  bool
+	}{
+		{zeroGuardNA, unitsStandard, 0, false},
+		{zeroGuardNA, unitsStandard, 288.15, true},
+		{zeroGuardNA, unitsMetric, 0, true},
+		{zeroGuardNA, unitsImperial, 0, true},
+		{zeroGuardStrict, unitsStandard, 0, false},
+		{zeroGuardOff, unitsStandard, 0, true},
+	}
+	for _, tt := range tests {
+		absoluteZeroGuard = tt.guard
+		w := WeatherData{Units: tt.units}
+		if got := w.tempKnown(tt.temp); got != tt.want {
+			t.Errorf("guard %s: tempKnown(%v %s) = %v, want %v", tt.guard, tt.temp, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAbsoluteZeroGuard(t *testing.T) {
+	for env, want := range map[string]string{"": zeroGuardNA, "na": zeroGuardNA, "strict": zeroGuardStrict, "off": zeroGuardOff, "loud": zeroGuardNA} {
+		t.Setenv("ABSOLUTE_ZERO_GUARD", env)
+		if got := loadAbsoluteZeroGuard(); got != want {
+			t.Errorf("ABSOLUTE_ZERO_GUARD=%q: %q, want %q", env, got, want)
+		}
+	}
+}
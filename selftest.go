@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// selfTestCheck is one request -selftest makes against a route, and the
+// statuses that count as a pass. A 501 always counts as a skip, since it
+// means the feature isn't enabled rather than broken.
+type selfTestCheck struct {
+	method string
+	path   string
+	body   string
+	want   []int
+	// skip, when set, says why the check can't run on this server.
+	skip string
+}
+
+// selfTestChecks returns the checks for each route pattern in routeTable.
+// Checks that would change saved labels or never finish are skipped.
+func (s *server) selfTestChecks(city string) map[string][]selfTestCheck {
+	c := (&url.URL{Path: city}).EscapedPath()
+	ok := []int{http.StatusOK}
+	get := func(path string, want ...int) selfTestCheck {
+		if len(want) == 0 {
+			want = ok
+		}
+		return selfTestCheck{method: http.MethodGet, path: path, want: want}
+	}
+	skipped := func(c selfTestCheck, reason string) selfTestCheck {
+		c.skip = reason
+		return c
+	}
+
+	point := os.Getenv("SELFTEST_POINT")
+	if point == "" {
+		point = "51.5074,-0.1278"
+	}
+	lat, lon, _ := strings.Cut(point, ",")
+	coords := get("/weather/points?point=" + url.QueryEscape(point))
+	reverse := get("/geocode/reverse?lat=" + url.QueryEscape(lat) + "&lon=" + url.QueryEscape(lon))
+	if _, isCoords := s.provider.(CoordProvider); !isCoords {
+		coords = skipped(coords, errCoordsUnavailable.Error())
+	}
+	if allowedCities != nil {
+		coords = skipped(coords, "coordinates are disabled while ALLOWED_CITIES is set")
+		reverse = skipped(reverse, "coordinates are disabled while ALLOWED_CITIES is set")
+	}
+
+	box := get("/weather/box?bbox=-0.5,51.3,0.3,51.7&zoom=8")
+	if _, isBox := s.provider.(BoxProvider); !isBox {
+		box = skipped(box, errBoxUnavailable.Error())
+	}
+	if allowedCities != nil {
+		box = skipped(box, "bounding boxes are disabled while ALLOWED_CITIES is set")
+	}
+
+	here := get("/weather/here")
+	if os.Getenv("GEOIP_STATIC") == "" && os.Getenv("DEFAULT_CITY") == "" {
+		here = skipped(here, "needs GEOIP_STATIC or DEFAULT_CITY, the test request has no real address")
+	}
+
+	country := get("/weather/country/" + countryCode(city) + "?limit=2")
+	if countryCode(city) == "" {
+		country = skipped(country, "SELFTEST_CITY has no country code")
+	}
+
+	zip := os.Getenv("SELFTEST_ZIP")
+	if zip == "" {
+		zip = "10001"
+	}
+	zipCheck := get("/zip/" + url.PathEscape(zip))
+	if _, isZip := s.geocoder.(ZipResolver); !isZip {
+		zipCheck = skipped(zipCheck, "postal codes are not available")
+	}
+
+	tile := get("/tiles/temp_new/0/0/0")
+	if _, isTiles := s.provider.(TileProvider); !isTiles {
+		tile = skipped(tile, "tiles are not available")
+	}
+
+	forecast := func(c selfTestCheck) selfTestCheck {
+		if _, offline := s.provider.(*FixtureProvider); offline {
+			return skipped(c, "forecasts are not available in offline mode")
+		}
+		return c
+	}
+	batchBody, _ := json.Marshal(map[string]any{"cities": []string{city}, "days": 1})
+
+	return map[string][]selfTestCheck{
+		"GET /{$}": {get("/")},
+		"/":        {get("/selftest/no-such-endpoint", http.StatusNotFound)},
+		"GET /api": {get("/api")},
+		"GET /weather/{city}": {
+			get("/weather/" + c),
+			get("/weather/" + c + "?format=json"),
+			get("/weather/" + c + "?format=html"),
+			get("/weather/" + c + "?format=slack"),
+			get("/weather/" + c + "?format=env"),
+			get("/weather/" + c + "?format=voice"),
+		},
+		"GET /weather/{city}/stream":    {skipped(get("/weather/"+c+"/stream"), "streams don't finish")},
+		"GET /weather/{city}/history":   {get("/weather/" + c + "/history")},
+		"GET /weather/{city}/nowcast":   {get("/weather/" + c + "/nowcast")},
+		"GET /weather/{city}/normal":    {get("/weather/"+c+"/normal", http.StatusOK, http.StatusNotFound)},
+		"GET /weather/{city}/feelslike": {get("/weather/" + c + "/feelslike"), get("/weather/" + c + "/feelslike?format=json")},
+		"GET /weather/points":           {coords},
+		"GET /weather/box":              {box},
+		"GET /weather/{city}/detail":    {get("/weather/" + c + "/detail")},
+		"GET /weather/{city}/badge.svg": {get("/weather/" + c + "/badge.svg")},
+		"GET /weather/{city}/emoji":     {get("/weather/" + c + "/emoji")},
+		"GET /weather/here":             {here},
+		"GET /weather/{group}/{name}":   {get("/weather/label/selftest-missing", http.StatusNotFound)},
+		"GET /weather/country/{code}":   {country},
+		"GET /labels":                   {get("/labels")},
+		"POST /labels":                  {{method: http.MethodPost, path: "/labels", skip: "would change saved labels"}},
+		"DELETE /labels/{label}":        {{method: http.MethodDelete, path: "/labels/{label}", skip: "would change saved labels"}},
+		"GET /geocode":                  {get("/geocode?q=" + url.QueryEscape(city))},
+		"GET /geocode/reverse":          {reverse},
+		"GET /zip/{zip}":                {zipCheck},
+		"GET /forecast/{city}":          {forecast(get("/forecast/" + c + "?cnt=2"))},
+		"GET /forecast/{city}/daily":    {forecast(get("/forecast/" + c + "/daily?days=1"))},
+		"GET /forecast/{city}/calendar": {forecast(get("/forecast/" + c + "/calendar"))},
+		"POST /forecast/batch": {forecast(selfTestCheck{method: http.MethodPost, path: "/forecast/batch?mode=strict",
+			body: string(batchBody), want: ok})},
+		"GET /forecast/{city}/delta":     {forecast(get("/forecast/" + c + "/delta"))},
+		"GET /forecast/{city}/sparkline": {forecast(get("/forecast/" + c + "/sparkline"))},
+		"GET /tiles/{layer}/{z}/{x}/{y}": {tile},
+		"GET /stats":                     {get("/stats")},
+		"GET /metrics":                   {get("/metrics", http.StatusOK, http.StatusNotFound)},
+		"GET /health":                    {get("/health")},
+		"GET /livez":                     {get("/livez")},
+		"GET /readyz":                    {get("/readyz")},
+		"POST /cache/clear":              {{method: http.MethodPost, path: "/cache/clear?city=" + url.QueryEscape(city), want: ok}},
+		"GET /debug/config":              {get("/debug/config")},
+		"GET /debug/last-responses":      {get("/debug/last-responses")},
+	}
+}
+
+func countryCode(city string) string {
+	_, cc, _ := strings.Cut(city, ",")
+	return cc
+}
+
+// runSelfTest sends every check through h, the handler main would serve,
+// and writes a line per check and a summary to out. It returns how many
+// checks failed.
+func (s *server) runSelfTest(h http.Handler, city string, out io.Writer) int {
+	key := os.Getenv("SERVER_API_KEY")
+	checks := s.selfTestChecks(city)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	var passed, failed, skipped int
+	for _, rt := range s.routeTable() {
+		cs, ok := checks[rt.pattern]
+		if !ok {
+			fmt.Fprintf(tw, "SKIP\t%s\t\tno check\n", rt.pattern)
+			skipped++
+			continue
+		}
+		for _, c := range cs {
+			switch {
+			case c.skip != "":
+				fmt.Fprintf(tw, "SKIP\t%s %s\t\t%s\n", c.method, c.path, c.skip)
+				skipped++
+				continue
+			case rt.auth && key == "":
+				fmt.Fprintf(tw, "SKIP\t%s %s\t\tneeds SERVER_API_KEY\n", c.method, c.path)
+				skipped++
+				continue
+			}
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			if c.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if rt.auth {
+				req.Header.Set("X-API-Key", key)
+			}
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			h.ServeHTTP(rec, req)
+			elapsed := time.Since(start).Round(time.Microsecond)
+
+			problem := selfTestProblem(c, rec)
+			switch {
+			case rec.Code == http.StatusNotImplemented:
+				fmt.Fprintf(tw, "SKIP\t%s %s\t%d\t%s\n", c.method, c.path, rec.Code, firstLine(rec.Body.String()))
+				skipped++
+			case problem != "":
+				fmt.Fprintf(tw, "FAIL\t%s %s\t%d\t%s (%s)\n", c.method, c.path, rec.Code, problem, elapsed)
+				failed++
+			default:
+				fmt.Fprintf(tw, "PASS\t%s %s\t%d\t%s\n", c.method, c.path, rec.Code, elapsed)
+				passed++
+			}
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	return failed
+}
+
+// selfTestProblem says what's wrong with a response, or "" when it has an
+// expected status and, if it claims to be JSON, parses.
+func selfTestProblem(c selfTestCheck, rec *httptest.ResponseRecorder) string {
+	if !slices.Contains(c.want, rec.Code) {
+		msg := firstLine(rec.Body.String())
+		if msg == "" {
+			msg = http.StatusText(rec.Code)
+		}
+		return fmt.Sprintf("want %v: %s", c.want, msg)
+	}
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if mediaType == "application/json" && !json.Valid(rec.Body.Bytes()) {
+		return "response is not valid JSON"
+	}
+	return ""
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return stripControl(line)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestSelfTest checks every route has a check and a healthy server passes
+// them all, while a failing upstream makes the run fail.
+func TestSelfTest(t *testing.T) {
+	newSelfTestServer := func(p *FakeProvider) *server {
+		return newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	}
+
+	s := newSelfTestServer(&FakeProvider{
+		Weather:   map[string]WeatherData{cacheKey("London,GB"): sampleWeather()},
+		Forecasts: map[string]ForecastData{cacheKey("London,GB"): sampleForecast()},
+	})
+	var out bytes.Buffer
+	if failed := s.runSelfTest(s.handler(), "London,GB", &out); failed != 0 {
+		t.Errorf("runSelfTest against the fake failed %d checks:\n%s", failed, out.String())
+	}
+	if strings.Contains(out.String(), "no check") {
+		t.Errorf("a route has no self-test check:\n%s", out.String())
+	}
+
+	s = newSelfTestServer(&FakeProvider{Errors: map[string]error{
+		cacheKey("London,GB"): &UpstreamError{StatusCode: http.StatusInternalServerError, Message: "down"},
+	}})
+	out.Reset()
+	if failed := s.runSelfTest(s.handler(), "London,GB", &out); failed == 0 {
+		t.Errorf("runSelfTest passed with the upstream down:\n%s", out.String())
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// server holds the dependencies shared by the HTTP handlers.
+type server struct {
+	provider Provider
+	cache    *Cache
+	tiles    *blobCache
+	geo      Geolocator
+	geocoder Geocoder
+	history  *historyStore
+	labels   *labelStore
+	// streams counts open /weather/{city}/stream connections.
+	streams atomic.Int64
+}
+
+func newServer(provider Provider, cache *Cache) *server {
+	return &server{
+		provider: provider,
+		cache:    cache,
+		tiles:    newBlobCache(tileCacheTTL, tileCacheMaxLen, tileCacheBudget(cache.maxEntries)),
+		geo:      newGeolocator(),
+		geocoder: newGeocoder(provider),
+		history:  newHistoryStore(),
+		labels:   newLabelStore(),
+	}
+}
+
+func (s *server) handleWeather(w http.ResponseWriter, r *http.Request) {
+	rawCity, format := splitFormatSuffix(r.PathValue("city"))
+	city, err := parseCityQuery(rawCity)
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	s.serveWeather(w, r, weatherQuery{city: city}, format)
+}
+
+// weatherQuery is the place a current-weather report is for: a city, or
+// a coordinate pair when at is set.
+type weatherQuery struct {
+	city string
+	at   *point
+}
+
+type point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// cacheName keys a point's weather in the cache, rounded to about 10m so
+// nearby requests share an entry.
+func (p point) cacheName() string {
+	return fmt.Sprintf("@%.4f,%.4f", p.Lat, p.Lon)
+}
+
+// name identifies the query in cache keys.
+func (q weatherQuery) name() string {
+	if q.at != nil {
+		return q.at.cacheName()
+	}
+	return q.city
+}
+
+func (s *server) fetchQuery(ctx context.Context, q weatherQuery, units string) (WeatherData, error) {
+	if q.at == nil {
+		return s.fetchCurrent(ctx, q.city, units)
+	}
+	cp, ok := s.provider.(CoordProvider)
+	if !ok {
+		return WeatherData{}, errCoordsUnavailable
+	}
+	return cp.CurrentAt(ctx, q.at.Lat, q.at.Lon, units)
+}
+
+// serveWeather writes the current-weather report for q, honouring every
+// option /weather/{city} takes. format is from a ".json"-style suffix, if
+// any, and falls back to ?format=.
+func (s *server) serveWeather(w http.ResponseWriter, r *http.Request, q weatherQuery, format string) {
+	if format == "" {
+		format = r.URL.Query().Get("format")
+	}
+	if q.at != nil && allowedCities != nil {
+		http.Error(w, errCoordsNotAllowed.Error(), http.StatusForbidden)
+		return
+	}
+	units := requestUnits(r)
+	if format == "xml" {
+		if q.at != nil {
+			http.Error(w, "xml format is not available for coordinates", http.StatusNotImplemented)
+			return
+		}
+		s.writeWeatherXML(w, r, q.city, units)
+		return
+	}
+	formatter, asciiOnly, err := reportFormatter(r, units, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tz, err := parseTZ(r.URL.Query().Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	debug := queryBool(r, "debug", false)
+	if debug && !hasServerKey(r) {
+		http.Error(w, "debug output needs the server API key", http.StatusUnauthorized)
+		return
+	}
+	upstreamUnits := fetchUnits(units)
+	data, status, err := s.cache.Get(r.Context(), cacheKey(q.name(), upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchQuery(ctx, q, upstreamUnits)
+	})
+	if err != nil && q.at == nil && queryErrorStatus(err) == http.StatusNotFound && citySuggestionsEnabled {
+		writeCityNotFound(w, err, format == "json" || wantsJSON(r), s.suggestCities(r.Context(), q.city))
+		return
+	}
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if q.at == nil {
+		requestStats.Record(q.city)
+	}
+	s.cache.setCacheHeaders(w, status, data)
+	data.DisplayZone = tz
+	if status == cacheStale || status == cacheStaleOnError {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+	setLocationHeaders(w, data.Location())
+	w.Header().Set("X-Temperature-Celsius", formatTemp(data.celsius(data.Main.Temp), 2))
+	w.Header().Set("X-Condition", data.condition().Main)
+	w.Header().Add("Vary", "Accept-Charset")
+	if debug {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conversionDebug(data, units, formatter))
+		return
+	}
+	writeReport(w, formatter, asciiOnly, data)
+}
+
+// reportFormatter picks the formatter for a weather report from the
+// ReportOptions query parameters serveWeather and handleWeatherAt share,
+// such as ?order=, ?emoji= and ?theme=. asciiOnly is set for a text report to a client that can't take
+// UTF-8, which gets the ASCII rendering whatever ?emoji= says.
+func reportFormatter(r *http.Request, units, format string) (formatter Formatter, asciiOnly bool, err error) {
+	tempOrder, err := parseTempOrder(r.URL.Query().Get("order"))
+	if err != nil {
+		return nil, false, err
+	}
+	if tempOrder == "" {
+		tempOrder = defaultTempOrder
+	}
+	pressureUnit, err := parsePressureUnit(r.URL.Query().Get("pressureUnit"))
+	if err != nil {
+		return nil, false, err
+	}
+	theme, err := parseTheme(r.URL.Query().Get("theme"))
+	if err != nil {
+		return nil, false, err
+	}
+	show, err := parseShow(r.URL.Query().Get("show"))
+	if err != nil {
+		return nil, false, err
+	}
+	locale, err := parseLocale(r.URL.Query().Get("locale"))
+	if err != nil {
+		return nil, false, err
+	}
+	opts := ReportOptions{
+		Advice:    r.URL.Query().Get("advice") == "true",
+		NoEmoji:   !queryBool(r, "emoji", emojiByDefault),
+		Round:     queryBool(r, "round", false),
+		TempOrder: tempOrder,
+
+		NoAttribution: !queryBool(r, "attribution", true),
+		PressureUnit:  pressureUnit,
+		Theme:         theme,
+		GoldenHour:    queryBool(r, "goldenhour", false),
+		WindSpeeds:    queryBool(r, "windspeeds", false),
+		Show:          show,
+		Locale:        locale,
+	}
+	asciiOnly = !acceptsUTF8(r) && (format == "" || format == "text")
+	if asciiOnly {
+		opts.NoEmoji = true
+	}
+	formatter, err = selectFormatter(units, format, opts)
+	return formatter, asciiOnly, err
+}
+
+// writeReport writes data as formatted by formatter, transliterated to
+// ASCII when asciiOnly.
+func writeReport(w http.ResponseWriter, formatter Formatter, asciiOnly bool, data WeatherData) {
+	if asciiOnly {
+		w.Header().Set("Content-Type", "text/plain; charset=us-ascii")
+		w.Write([]byte(toASCII(formatter.Format(data))))
+		return
+	}
+	setFormatterHeaders(w, formatter)
+	w.Write([]byte(formatter.Format(data)))
+}
+
+func (s *server) handleForecast(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	cnt, err := parseForecastCount(r.URL.Query().Get("cnt"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "text" && format != "json" {
+		http.Error(w, fmt.Sprintf("unsupported format %q: expected text or json", format), http.StatusBadRequest)
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := tempLabels[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	tz, err := parseTZ(r.URL.Query().Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := s.provider.Forecast(r.Context(), city, cnt, fetchUnits(units))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	data.DisplayZone = tz
+	setLocationHeaders(w, data.Location())
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(data.FormatJSON(units)))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(data.FormatOutput(units)))
+}
+
+func (s *server) handleDailyForecast(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := tempLabels[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseForecastDays(r.URL.Query().Get("days"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := s.provider.Forecast(r.Context(), city, 0, fetchUnits(units))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	days := dailyRollup(data)
+	if limit > 0 && len(days) > limit {
+		days = days[:limit]
+	}
+	setLocationHeaders(w, data.Location())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(formatDailyForecast(data, days, units)))
+}
+
+func (s *server) handleCacheClear(w http.ResponseWriter, r *http.Request) {
+	var n int
+	if city := r.URL.Query().Get("city"); city != "" {
+		n = s.cache.ClearCity(city)
+	} else {
+		n = s.cache.Clear()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"cleared": n})
+}
+
+// queryBool reads a true/false query parameter, falling back to def when
+// it's absent or not a boolean.
+func queryBool(r *http.Request, name string, def bool) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestServerSmoke boots the real handler chain on an ephemeral port, with
+// a FakeProvider behind it, and checks the main routes answer.
+func TestServerSmoke(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().handler())
+	defer ts.Close()
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("GET %s: reading body: %v", path, err)
+		}
+		return resp, string(body)
+	}
+	header := func(path string, resp *http.Response, name, want string) {
+		t.Helper()
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("GET %s: %s = %q, want %q", path, name, got, want)
+		}
+	}
+
+	t.Run("health", func(t *testing.T) {
+		resp, body := get("/health")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", resp.StatusCode, body)
+		}
+		header("/health", resp, "Content-Type", "application/json")
+		if resp.Header.Get("X-Response-Time") == "" {
+			t.Error("no X-Response-Time")
+		}
+		var health struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(body), &health); err != nil || health.Status != "ok" {
+			t.Errorf("body %s, want status ok", body)
+		}
+	})
+
+	t.Run("weather", func(t *testing.T) {
+		const path = "/weather/London"
+		resp, body := get(path)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", resp.StatusCode, body)
+		}
+		header(path, resp, "Content-Type", "text/plain; charset=utf-8")
+		header(path, resp, "X-Cache", cacheMiss)
+		header(path, resp, "X-Location", "London, GB")
+		header(path, resp, "X-Temperature-Celsius", "15.00")
+		for _, want := range []string{"Weather Report for London, GB", "Temperature: 15.00°C (59.00°F)", "Condition: ☁️ Clouds (broken clouds)"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body lacks %q:\n%s", want, body)
+			}
+		}
+
+		resp, _ = get(path)
+		header(path, resp, "X-Cache", cacheHit)
+	})
+
+	t.Run("forecast", func(t *testing.T) {
+		const path = "/forecast/London"
+		resp, body := get(path)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", resp.StatusCode, body)
+		}
+		header(path, resp, "Content-Type", "text/plain; charset=utf-8")
+		header(path, resp, "X-Location", "London, GB")
+		if !strings.HasPrefix(body, "Forecast for London, GB") {
+			t.Errorf("body doesn't start with the heading:\n%s", body)
+		}
+		if n := strings.Count(body, "°C"); n != 3 {
+			t.Errorf("%d forecast steps, want 3:\n%s", n, body)
+		}
+	})
+
+	t.Run("unknown city", func(t *testing.T) {
+		for _, path := range []string{"/weather/Atlantis", "/forecast/Atlantis"} {
+			if resp, body := get(path); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GET %s: status %d, want 404: %s", path, resp.StatusCode, body)
+			}
+		}
+	})
+}
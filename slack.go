@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SlackFormatter renders a Slack message payload, suitable as the response
+// to a slash command or the body of an incoming webhook.
+type SlackFormatter struct {
+	Units string
+	ReportOptions
+}
+
+// slackMessage is the subset of Slack's message payload we emit. Text is
+// the fallback shown in notifications; Blocks is the rendered message.
+type slackMessage struct {
+	ResponseType string       `json:"response_type"`
+	Text         string       `json:"text"`
+	Blocks       []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Slack limits a section to 10 fields and a context block to 10 elements.
+const slackMaxFields = 10
+
+func mrkdwn(s string) slackText { return slackText{Type: "mrkdwn", Text: s} }
+
+// slackEscape escapes the characters Slack reserves for its link and
+// mention syntax.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+func (SlackFormatter) ContentType() string { return "application/json" }
+
+// rawJSON keeps withJSONEnvelope off the payload: Slack rejects fields it
+// doesn't know, and wants its own snake_case names.
+func (SlackFormatter) rawJSON() {}
+
+func (f SlackFormatter) Format(w WeatherData) string {
+	units := f.Units
+	if units == "" {
+		units = unitsMetric
+	}
+	labels := unitSetFor(units)
+	temp := func(v float64) string {
+		if !w.tempKnown(v) {
+			return notAvailable
+		}
+		return f.temp(convertTemp(v, w.Units, units)) + labels.Temp
+	}
+
+	location := slackEscape(w.Location().String())
+	c := w.condition()
+	condition := slackEscape(c.Main + " (" + c.Description + ")")
+	emoji := f.icon(conditionSymbol(f.Theme, c.Main, c.ID))
+	feels, estimated := w.feelsLike()
+	feelsText := temp(feels)
+	switch {
+	case !w.feelsLikeKnown():
+		feelsText = notAvailable
+	case estimated:
+		feelsText += " (estimated)"
+	}
+
+	// With only the feels-like temperature shown it takes the headline's
+	// place, since that's the one figure the client wants.
+	shown := temp(w.Main.Temp)
+	if !f.showsActual() {
+		shown = "feels like " + feelsText
+	}
+	headline := fmt.Sprintf("*Weather for %s*%s\n%s", location, emoji, shown)
+	if condition != "" {
+		headline += ", " + condition
+	}
+	var fields []slackText
+	if f.showsActual() && f.showsFeelsLike() {
+		fields = append(fields, mrkdwn("*Feels like*\n"+feelsText))
+	}
+	fields = append(fields, []slackText{
+		mrkdwn("*Min/Max*\n" + temp(w.Main.TempMin) + " / " + temp(w.Main.TempMax)),
+		mrkdwn(fmt.Sprintf("*Humidity*\n%d%%", w.Main.Humidity)),
+		mrkdwn("*Wind*\n" + w.windText(units, labels.Speed)),
+		mrkdwn(fmt.Sprintf("*Pressure*\n%s %s", formatPressure(w.Main.Pressure, f.pressureUnit(units)), f.pressureUnit(units))),
+		mrkdwn("*Cloudiness*\n" + w.cloudsText()),
+	}...)
+	if w.HasUVI {
+		fields = append(fields, mrkdwn(fmt.Sprintf("*UV Index*\n%.1f (%s)", w.UVI, uviRisk(w.UVI))))
+	}
+
+	msg := slackMessage{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf("Weather for %s: %s", location, shown),
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: headline}},
+			{Type: "section", Fields: fields[:min(len(fields), slackMaxFields)]},
+		},
+	}
+	var notes []slackText
+	for _, warning := range w.tempWarnings("°") {
+		notes = append(notes, mrkdwn(":warning: "+slackEscape(warning)))
+	}
+	if f.Advice {
+		if tip := recommend(w); tip != "" {
+			notes = append(notes, mrkdwn(slackEscape(tip)))
+		}
+	}
+	if credit := f.attribution(); credit != "" {
+		notes = append(notes, mrkdwn("<https://openweathermap.org/|"+credit+">"))
+	}
+	if len(notes) > 0 {
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "context", Elements: notes[:min(len(notes), slackMaxFields)]})
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Sprintf(`{"text":%q}`, err.Error())
+	}
+	return string(b)
+}
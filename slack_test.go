@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSlackEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"London":             "London",
+		"<!channel>":         "&lt;!channel&gt;",
+		"<@U123|bob>":        "&lt;@U123|bob&gt;",
+		"Rain & wind":        "Rain &amp; wind",
+		"&lt; already there": "&amp;lt; already there",
+	} {
+		if got := slackEscape(in); got != want {
+			t.Errorf("slackEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestSlackFormatterEscapes checks a city or condition can't ping a
+// channel or smuggle in a link through the message payload.
+func TestSlackFormatterEscapes(t *testing.T) {
+	w := sampleWeather()
+	w.Name = "<!channel>"
+	w.Weather[0].Description = "<https://example.com|click>"
+	var msg slackMessage
+	if err := json.Unmarshal([]byte(SlackFormatter{Units: unitsMetric}.Format(w)), &msg); err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{msg.Text}
+	for _, b := range msg.Blocks {
+		if b.Text != nil {
+			texts = append(texts, b.Text.Text)
+		}
+		for _, f := range b.Fields {
+			texts = append(texts, f.Text)
+		}
+	}
+	for _, s := range texts {
+		if strings.ContainsAny(s, "<>") {
+			t.Errorf("unescaped Slack markup in %q", s)
+		}
+	}
+	if !strings.Contains(msg.Text, "&lt;!channel&gt;") {
+		t.Errorf("Text = %q, want the escaped city name", msg.Text)
+	}
+}
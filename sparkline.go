@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sparkBlocks are the eight block heights a sparkline is drawn with.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline scales values between their minimum and maximum onto the
+// eight blocks, splitting the range into equal buckets; the maximum lands
+// in the top one. A flat series is drawn at mid height.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := len(sparkBlocks)/2 - 1
+		if hi > lo {
+			level = min(int((v-lo)/(hi-lo)*float64(len(sparkBlocks))), len(sparkBlocks)-1)
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// handleForecastSparkline draws the next 24 hours of forecast temperatures
+// as a sparkline followed by their range.
+func (s *server) handleForecastSparkline(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	if _, ok := unitSets[units]; !ok {
+		http.Error(w, fmt.Sprintf("unsupported units %q: expected metric, imperial or standard", units), http.StatusBadRequest)
+		return
+	}
+	data, err := s.provider.Forecast(r.Context(), city, 0, fetchUnits(units))
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	if len(data.List) < 2 {
+		http.Error(w, errSparseForecast.Error(), http.StatusBadGateway)
+		return
+	}
+	end := data.List[0].Dt + int64((24 * time.Hour).Seconds())
+	var temps []float64
+	for _, e := range data.List {
+		if e.Dt >= end {
+			break
+		}
+		temps = append(temps, convertTemp(e.Main.Temp, data.Units, units))
+	}
+	lo, hi := temps[0], temps[0]
+	for _, t := range temps {
+		lo, hi = min(lo, t), max(hi, t)
+	}
+	label := unitSetFor(units).Temp
+	setLocationHeaders(w, data.Location())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s %s%s to %s%s\n", sparkline(temps), formatTemp(lo, 1), label, formatTemp(hi, 1), label)
+}
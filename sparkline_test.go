@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   string
+	}{
+		{nil, ""},
+		{[]float64{5}, "▄"},
+		{[]float64{3, 3, 3}, "▄▄▄"},
+		{[]float64{0, 1, 2, 3, 4, 5, 6, 7}, "▁▂▃▄▅▆▇█"},
+		{[]float64{10, -10}, "█▁"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values); got != tt.want {
+			t.Errorf("sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestForecastSparkline(t *testing.T) {
+	h := newTestServer().routes()
+	for units, label := range map[string]string{unitsMetric: "°C", unitsImperial: "°F"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London/sparkline?units="+units, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || strings.Count(body, label) != 2 || !strings.ContainsAny(body, string(sparkBlocks)) {
+			t.Errorf("?units=%s: got %d %q, want a sparkline and a %s range", units, rec.Code, body, label)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forecast/London/sparkline?units=rankine", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("?units=rankine: got %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsLimit = 10
+	maxStatsLimit     = 100
+)
+
+// maxTrackedCities bounds cityCounter; STATS_MAX_CITIES overrides it.
+var maxTrackedCities = envInt("STATS_MAX_CITIES", 1000)
+
+// cityCounter tallies how often each city has been looked up. Once it
+// holds max cities, a new one replaces the least requested, so made-up
+// names can't grow it without bound.
+type cityCounter struct {
+	mu     sync.Mutex
+	max    int
+	seq    uint64
+	counts map[string]*cityTally
+}
+
+type cityTally struct {
+	count    int
+	lastSeen uint64
+}
+
+var requestStats = newCityCounter(maxTrackedCities)
+
+func newCityCounter(max int) *cityCounter {
+	return &cityCounter{max: max, counts: make(map[string]*cityTally)}
+}
+
+// Record counts a successful lookup of city.
+func (c *cityCounter) Record(city string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	key := cacheKey(city)
+	t, ok := c.counts[key]
+	if !ok {
+		if len(c.counts) >= c.max {
+			c.evictLocked()
+		}
+		t = &cityTally{}
+		c.counts[key] = t
+	}
+	t.count++
+	t.lastSeen = c.seq
+}
+
+// evictLocked drops the least requested city, the one asked for longest
+// ago among equals.
+func (c *cityCounter) evictLocked() {
+	var victim string
+	var min *cityTally
+	for city, t := range c.counts {
+		if min == nil || t.count < min.count || t.count == min.count && t.lastSeen < min.lastSeen {
+			victim, min = city, t
+		}
+	}
+	delete(c.counts, victim)
+}
+
+// Len returns how many cities are being tallied.
+func (c *cityCounter) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.counts)
+}
+
+type cityCount struct {
+	City  string `json:"city"`
+	Count int    `json:"count"`
+}
+
+// Top returns up to limit cities ordered by count (ties broken
+// alphabetically) or, with byName, alphabetically.
+func (c *cityCounter) Top(limit int, byName bool) ([]cityCount, int) {
+	c.mu.Lock()
+	list := make([]cityCount, 0, len(c.counts))
+	for city, t := range c.counts {
+		list = append(list, cityCount{city, t.count})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if !byName && list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].City < list[j].City
+	})
+	total := len(list)
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, total
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	limit := defaultStatsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxStatsLimit {
+			http.Error(w, fmt.Sprintf("invalid limit %q: expected an integer between 1 and %d", raw, maxStatsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var byName bool
+	switch sortBy := r.URL.Query().Get("sort"); sortBy {
+	case "", "count":
+	case "alpha":
+		byName = true
+	default:
+		http.Error(w, fmt.Sprintf("invalid sort %q: expected count or alpha", sortBy), http.StatusBadRequest)
+		return
+	}
+
+	cities, total := requestStats.Top(limit, byName)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"cities":         cities,
+		"distinct_total": total,
+		"cache": map[string]int{
+			"entries":   s.cache.Len(),
+			"evictions": s.cache.Evictions(),
+		},
+		"upstream_latency": upstreamLatency.Summary(),
+		"memory":           s.memoryUsage(),
+		"upstream_quota":   upstreamQuota.Summary(time.Now()),
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCityCounterTop(t *testing.T) {
+	c := newCityCounter(10)
+	for _, city := range []string{"Paris", "london", "London ", "Oslo", "paris", "LONDON", "Bern"} {
+		c.Record(city)
+	}
+	byCount, total := c.Top(3, false)
+	if want := []cityCount{{"london", 3}, {"paris", 2}, {"bern", 1}}; !reflect.DeepEqual(byCount, want) || total != 4 {
+		t.Errorf("Top(3, count) = %v, %d; want %v, 4", byCount, total, want)
+	}
+	byName, _ := c.Top(2, true)
+	if want := []cityCount{{"bern", 1}, {"london", 3}}; !reflect.DeepEqual(byName, want) {
+		t.Errorf("Top(2, alpha) = %v, want %v", byName, want)
+	}
+}
+
+// TestCityCounterBounded records far more cities than it holds: the
+// popular one must survive, and the oldest of the rest make way.
+func TestCityCounterBounded(t *testing.T) {
+	c := newCityCounter(3)
+	c.Record("London")
+	c.Record("London")
+	for i := range 100 {
+		c.Record(fmt.Sprintf("nowhere-%d", i))
+	}
+	got, total := c.Top(10, true)
+	if want := []cityCount{{"london", 2}, {"nowhere-98", 1}, {"nowhere-99", 1}}; !reflect.DeepEqual(got, want) || total != 3 {
+		t.Errorf("Top = %v, %d; want %v, 3", got, total, want)
+	}
+}
+
+// TestStatsCountsFoundCities checks only lookups that found a city are
+// counted, so made-up names don't show up in /stats.
+func TestStatsCountsFoundCities(t *testing.T) {
+	defer func(c *cityCounter) { requestStats = c }(requestStats)
+	requestStats = newCityCounter(10)
+	h := newTestServer().routes()
+
+	for _, path := range []string{"/weather/London", "/weather/Atlantis", "/weather/London"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got, total := requestStats.Top(10, false); !reflect.DeepEqual(got, []cityCount{{"london", 2}}) || total != 1 {
+		t.Errorf("stats = %v, %d; want london twice and nothing else", got, total)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	maxStreams     = envInt("STREAM_MAX", 100)
+	streamInterval = envDuration("STREAM_INTERVAL", time.Minute)
+)
+
+// handleWeatherStream pushes the city's weather as Server-Sent Events. The
+// current report is sent on connect and again whenever the cache entry
+// changes; the handler re-reads the cache every STREAM_INTERVAL so stale
+// entries get their background refresh even with no other traffic.
+func (s *server) handleWeatherStream(w http.ResponseWriter, r *http.Request) {
+	city, err := parseCityQuery(r.PathValue("city"))
+	if err != nil {
+		http.Error(w, err.Error(), cityErrorStatus(err))
+		return
+	}
+	units := requestUnits(r)
+	formatter, err := selectFormatter(units, "json", ReportOptions{Round: queryBool(r, "round", false)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.streams.Add(1) > int64(maxStreams) {
+		s.streams.Add(-1)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "too many open streams", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.streams.Add(-1)
+
+	upstreamUnits := fetchUnits(units)
+	key := cacheKey(city, upstreamUnits)
+	fetch := func(ctx context.Context) (WeatherData, error) { return s.fetchCurrent(ctx, city, upstreamUnits) }
+	updates, stop := s.cache.Watch(key)
+	defer stop()
+	data, _, err := s.cache.Get(r.Context(), key, fetch)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(data WeatherData) error {
+		fmt.Fprintf(w, "event: weather\ndata: %s\n\n", strings.ReplaceAll(formatter.Format(data), "\n", "\ndata: "))
+		return rc.Flush()
+	}
+	if err := send(data); err != nil {
+		return
+	}
+	// Drop the notification for the value just sent, if Get fetched it.
+	select {
+	case <-updates:
+	default:
+	}
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-updates:
+			if err := send(data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.cache.Get(r.Context(), key, fetch)
+			// A comment line keeps idle proxies from closing the stream.
+			fmt.Fprint(w, ": ping\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
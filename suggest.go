@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxSuggestions is how many geocoding candidates a 404 offers.
+const maxSuggestions = 5
+
+// citySuggestionsEnabled turns on "did you mean?" lookups for unknown
+// cities with CITY_SUGGESTIONS=true. Each one costs a geocoding call.
+var citySuggestionsEnabled = os.Getenv("CITY_SUGGESTIONS") == "true"
+
+// suggestCities returns candidates for city from the geocoder, or nil
+// when it can't geocode or the lookup fails; a failed lookup must not turn
+// the 404 into something else.
+func (s *server) suggestCities(ctx context.Context, city string) []place {
+	places, err := s.geocoder.Geocode(ctx, city)
+	if errors.Is(err, errGeocodingUnavailable) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("city suggestions for %q failed: %v", city, err)
+		return nil
+	}
+	return placesOf(places[:min(len(places), maxSuggestions)])
+}
+
+// writeCityNotFound answers a 404 for city with any suggestions, as JSON
+// for JSON clients and as a "Did you mean" list otherwise.
+func writeCityNotFound(w http.ResponseWriter, err error, asJSON bool, suggestions []place) {
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "suggestions": suggestions})
+		return
+	}
+	var b strings.Builder
+	b.WriteString(err.Error())
+	if len(suggestions) > 0 {
+		b.WriteString("\nDid you mean:")
+		for _, c := range suggestions {
+			fmt.Fprintf(&b, "\n  %s (%.4f, %.4f)", c, c.Lat, c.Lon)
+		}
+	}
+	http.Error(w, b.String(), http.StatusNotFound)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Condition groups a symbol theme must cover.
+const (
+	groupClear        = "clear"
+	groupClouds       = "clouds"
+	groupRain         = "rain"
+	groupDrizzle      = "drizzle"
+	groupThunderstorm = "thunderstorm"
+	groupSnow         = "snow"
+	groupFog          = "fog"
+	groupUnknown      = "unknown"
+)
+
+// Condition is one entry of OpenWeather's weather array.
+type Condition struct {
+	ID          int    `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// unknownCondition stands in for an empty or missing weather array, so
+// every format and endpoint reports the same thing instead of a blank. Its
+// group is groupUnknown.
+var unknownCondition = Condition{Main: "Unknown", Description: "condition not reported"}
+
+// primaryCondition is the first, and most significant, of the conditions,
+// or unknownCondition when there are none.
+func primaryCondition(conditions []Condition) Condition {
+	if len(conditions) == 0 {
+		return unknownCondition
+	}
+	return conditions[0]
+}
+
+func (w WeatherData) condition() Condition {
+	return primaryCondition(w.Weather)
+}
+
+// symbolThemes map each condition group to the symbol reports show for it.
+// nerdfont uses the Weather Icons glyphs bundled with Nerd Fonts, which
+// only render in a terminal using one of those fonts.
+var symbolThemes = map[string]map[string]string{
+	"emoji": {
+		groupClear:        "☀️",
+		groupClouds:       "☁️",
+		groupRain:         "🌧️",
+		groupDrizzle:      "🌦️",
+		groupThunderstorm: "⛈️",
+		groupSnow:         "❄️",
+		groupFog:          "🌫️",
+		groupUnknown:      unknownConditionEmoji,
+	},
+	"ascii": {
+		groupClear:        "[sun]",
+		groupClouds:       "[cloud]",
+		groupRain:         "[rain]",
+		groupDrizzle:      "[drizzle]",
+		groupThunderstorm: "[storm]",
+		groupSnow:         "[snow]",
+		groupFog:          "[fog]",
+		groupUnknown:      "[?]",
+	},
+	"nerdfont": {
+		groupClear:        "\ue30d", // nf-weather-day_sunny
+		groupClouds:       "\ue312", // nf-weather-cloudy
+		groupRain:         "\ue318", // nf-weather-rain
+		groupDrizzle:      "\ue31b", // nf-weather-sprinkle
+		groupThunderstorm: "\ue31d", // nf-weather-thunderstorm
+		groupSnow:         "\ue31a", // nf-weather-snow
+		groupFog:          "\ue313", // nf-weather-fog
+		groupUnknown:      "\ue374", // nf-weather-na
+	},
+}
+
+// defaultTheme is SYMBOL_THEME, used when a request has no ?theme=.
+var defaultTheme = loadDefaultTheme()
+
+func loadDefaultTheme() string {
+	theme, err := parseTheme(os.Getenv("SYMBOL_THEME"))
+	if err != nil {
+		log.Printf("ignoring SYMBOL_THEME: %v", err)
+	}
+	if theme == "" {
+		return "emoji"
+	}
+	return theme
+}
+
+// parseTheme validates ?theme=, ignoring case. "" leaves the choice to
+// defaultTheme.
+func parseTheme(raw string) (string, error) {
+	theme := strings.ToLower(raw)
+	if _, ok := symbolThemes[theme]; !ok && theme != "" {
+		return "", fmt.Errorf("unsupported theme %q: expected emoji, ascii or nerdfont", raw)
+	}
+	return theme, nil
+}
+
+// conditionGroup classifies a condition by its Main string, falling back
+// on the numeric ID's group for values we don't recognise.
+func conditionGroup(main string, id int) string {
+	switch strings.ToLower(main) {
+	case "clear":
+		return groupClear
+	case "clouds":
+		return groupClouds
+	case "rain":
+		return groupRain
+	case "drizzle":
+		return groupDrizzle
+	case "thunderstorm":
+		return groupThunderstorm
+	case "snow":
+		return groupSnow
+	case "mist", "fog":
+		return groupFog
+	}
+	switch {
+	case id >= 200 && id < 300:
+		return groupThunderstorm
+	case id >= 300 && id < 400:
+		return groupDrizzle
+	case id >= 500 && id < 600:
+		return groupRain
+	case id >= 600 && id < 700:
+		return groupSnow
+	case id >= 700 && id < 800:
+		return groupFog
+	case id == 800:
+		return groupClear
+	case id > 800 && id < 900:
+		return groupClouds
+	}
+	return groupUnknown
+}
+
+// conditionSymbol is the theme's symbol for a condition, where "" (or any
+// unknown name) is defaultTheme.
+func conditionSymbol(theme, main string, id int) string {
+	symbols, ok := symbolThemes[theme]
+	if !ok {
+		symbols = symbolThemes[defaultTheme]
+	}
+	return symbols[conditionGroup(main, id)]
+}
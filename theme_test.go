@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConditionSymbol(t *testing.T) {
+	tests := []struct {
+		main  string
+		id    int
+		group string
+		emoji string
+		ascii string
+		nerd  string
+	}{
+		{"Clear", 800, groupClear, "☀️", "[sun]", "\ue30d"},
+		{"Clouds", 803, groupClouds, "☁️", "[cloud]", "\ue312"},
+		{"Rain", 500, groupRain, "🌧️", "[rain]", "\ue318"},
+		{"Drizzle", 300, groupDrizzle, "🌦️", "[drizzle]", "\ue31b"},
+		{"Thunderstorm", 211, groupThunderstorm, "⛈️", "[storm]", "\ue31d"},
+		{"Snow", 601, groupSnow, "❄️", "[snow]", "\ue31a"},
+		{"Mist", 701, groupFog, "🌫️", "[fog]", "\ue313"},
+		{"Fog", 741, groupFog, "🌫️", "[fog]", "\ue313"},
+
+		// Main is matched without regard to case.
+		{"RAIN", 500, groupRain, "🌧️", "[rain]", "\ue318"},
+		{"clear", 800, groupClear, "☀️", "[sun]", "\ue30d"},
+
+		// A recognised Main wins over the ID; an unrecognised one falls
+		// back on the ID's group.
+		{"Rain", 800, groupRain, "🌧️", "[rain]", "\ue318"},
+		{"Haze", 721, groupFog, "🌫️", "[fog]", "\ue313"},
+		{"Squall", 771, groupFog, "🌫️", "[fog]", "\ue313"},
+		{"", 502, groupRain, "🌧️", "[rain]", "\ue318"},
+		{"", 804, groupClouds, "☁️", "[cloud]", "\ue312"},
+
+		// Neither Main nor ID known.
+		{"Volcano", 0, groupUnknown, "🌈", "[?]", "\ue374"},
+		{"", 999, groupUnknown, "🌈", "[?]", "\ue374"},
+	}
+	for _, tt := range tests {
+		if got := conditionGroup(tt.main, tt.id); got != tt.group {
+			t.Errorf("conditionGroup(%q, %d) = %q, want %q", tt.main, tt.id, got, tt.group)
+		}
+		if got := conditionEmoji(tt.main, tt.id); got != tt.emoji {
+			t.Errorf("conditionEmoji(%q, %d) = %q, want %q", tt.main, tt.id, got, tt.emoji)
+		}
+		for theme, want := range map[string]string{"emoji": tt.emoji, "ascii": tt.ascii, "nerdfont": tt.nerd} {
+			if got := conditionSymbol(theme, tt.main, tt.id); got != want {
+				t.Errorf("conditionSymbol(%q, %q, %d) = %q, want %q", theme, tt.main, tt.id, got, want)
+			}
+		}
+		// An unknown theme name is the default theme.
+		if got, want := conditionSymbol("no-such-theme", tt.main, tt.id), conditionSymbol(defaultTheme, tt.main, tt.id); got != want {
+			t.Errorf("conditionSymbol(unknown theme, %q, %d) = %q, want the default theme's %q", tt.main, tt.id, got, want)
+		}
+	}
+}
+
+func TestSymbolThemesCoverEveryGroup(t *testing.T) {
+	groups := []string{groupClear, groupClouds, groupRain, groupDrizzle, groupThunderstorm, groupSnow, groupFog, groupUnknown}
+	for theme, symbols := range symbolThemes {
+		if len(symbols) != len(groups) {
+			t.Errorf("theme %q has %d symbols, want %d", theme, len(symbols), len(groups))
+		}
+		for _, g := range groups {
+			if symbols[g] == "" {
+				t.Errorf("theme %q has no symbol for %s", theme, g)
+			}
+		}
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	for raw, want := range map[string]string{"": "", "emoji": "emoji", "ASCII": "ascii", "NerdFont": "nerdfont"} {
+		got, err := parseTheme(raw)
+		if err != nil || got != want {
+			t.Errorf("parseTheme(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := parseTheme("wingdings"); err == nil {
+		t.Error(`parseTheme("wingdings") succeeded, want an error`)
+	}
+}
+
+// TestEmojiEndpointThemes checks ?theme= end to end on /weather/{city}/emoji,
+// where London's sample weather is broken clouds.
+func TestEmojiEndpointThemes(t *testing.T) {
+	h := newTestServer().handler()
+	for theme, want := range map[string]string{"emoji": "☁️", "ascii": "[cloud]", "nerdfont": "\ue312"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London/emoji?theme="+theme, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("?theme=%s: got %d %q, want 200 %q", theme, rec.Code, rec.Body.String(), want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London/emoji?theme=wingdings", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("?theme=wingdings: got %d, want 400", rec.Code)
+	}
+}
+
+func TestPrimaryCondition(t *testing.T) {
+	rain := Condition{ID: 500, Main: "Rain", Description: "light rain"}
+	mist := Condition{ID: 701, Main: "Mist", Description: "mist"}
+	tests := []struct {
+		in   []Condition
+		want Condition
+	}{
+		{nil, unknownCondition},
+		{[]Condition{}, unknownCondition},
+		{[]Condition{rain}, rain},
+		{[]Condition{rain, mist}, rain},
+	}
+	for _, tt := range tests {
+		if got := primaryCondition(tt.in); got != tt.want {
+			t.Errorf("primaryCondition(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if got := conditionGroup(unknownCondition.Main, unknownCondition.ID); got != groupUnknown {
+		t.Errorf("unknownCondition is in group %q, want %q", got, groupUnknown)
+	}
+}
+
+// TestEmptyWeatherArray checks every format reports the Unknown condition,
+// rather than leaving it out, when OpenWeather sends no weather entries.
+func TestEmptyWeatherArray(t *testing.T) {
+	w := sampleWeather()
+	w.Weather = nil
+	s := newServer(&FakeProvider{Weather: map[string]WeatherData{cacheKey("London"): w}},
+		NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	h := s.handler()
+	for _, format := range []string{"text", "json", "html", "slack", "env"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London?format="+format, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Unknown") {
+			t.Errorf("?format=%s: got %d %q, want the Unknown condition", format, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-Condition"); got != "Unknown" {
+			t.Errorf("?format=%s: X-Condition = %q, want Unknown", format, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// tempThresholds are operator-defined limits in °C, set with HOT_THRESHOLD
+// and COLD_THRESHOLD. They are unrelated to OpenWeather's own alerts.
+type tempThresholds struct {
+	hot, cold       float64
+	hasHot, hasCold bool
+}
+
+var thresholds = loadThresholds()
+
+func loadThresholds() tempThresholds {
+	var t tempThresholds
+	t.hot, t.hasHot = envFloat("HOT_THRESHOLD")
+	t.cold, t.hasCold = envFloat("COLD_THRESHOLD")
+	return t
+}
+
+// envFloat reads an optional float variable; ok is false when it is unset
+// or invalid.
+func envFloat(name string) (v float64, ok bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", name, raw, err)
+		return 0, false
+	}
+	return v, true
+}
+
+// warnings lists the thresholds tempC has reached. Reaching a threshold
+// exactly counts as crossing it.
+func (t tempThresholds) warnings(tempC float64, deg string) []string {
+	var out []string
+	if t.hasHot && tempC >= t.hot {
+		out = append(out, fmt.Sprintf("Temperature %s%sC is at or above the hot threshold of %g%sC", formatTemp(tempC, 1), deg, t.hot, deg))
+	}
+	if t.hasCold && tempC <= t.cold {
+		out = append(out, fmt.Sprintf("Temperature %s%sC is at or below the cold threshold of %g%sC", formatTemp(tempC, 1), deg, t.cold, deg))
+	}
+	return out
+}
+
+// tempWarnings is thresholds.warnings for w's temperature, with none for
+// a missing one.
+func (w WeatherData) tempWarnings(deg string) []string {
+	if !w.tempKnown(w.Main.Temp) {
+		return nil
+	}
+	return thresholds.warnings(w.celsius(w.Main.Temp), deg)
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestThresholdWarnings(t *testing.T) {
+	th := tempThresholds{hot: 30, cold: -5, hasHot: true, hasCold: true}
+	tests := []struct {
+		tempC float64
+		want  int
+	}{
+		{29.9, 0},
+		{30, 1},
+		{35, 1},
+		{-4.9, 0},
+		{-5, 1},
+		{-10, 1},
+	}
+	for _, tt := range tests {
+		if got := th.warnings(tt.tempC, "°"); len(got) != tt.want {
+			t.Errorf("warnings(%v) = %q, want %d", tt.tempC, got, tt.want)
+		}
+	}
+	if got := (tempThresholds{}).warnings(100, "°"); got != nil {
+		t.Errorf("warnings with no thresholds set = %q, want none", got)
+	}
+	got := th.warnings(30, "°")
+	if want := "Temperature 30.0°C is at or above the hot threshold of 30°C"; len(got) != 1 || got[0] != want {
+		t.Errorf("warnings(30) = %q, want %q", got, want)
+	}
+}
+
+func TestLoadThresholds(t *testing.T) {
+	t.Setenv("HOT_THRESHOLD", "32.5")
+	t.Setenv("COLD_THRESHOLD", "cold")
+	th := loadThresholds()
+	if !th.hasHot || th.hot != 32.5 || th.hasCold {
+		t.Errorf("loadThresholds() = %+v, want only hot at 32.5", th)
+	}
+}
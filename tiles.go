@@ -0,0 +1,181 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxTileZoom     = 18
+	tileCacheTTL    = time.Hour
+	tileCacheMaxLen = 500
+)
+
+// tileLayers are the map layers OpenWeather serves tiles for.
+var tileLayers = map[string]bool{
+	"clouds_new":        true,
+	"precipitation_new": true,
+	"pressure_new":      true,
+	"wind_new":          true,
+	"temp_new":          true,
+}
+
+// TileProvider is implemented by providers that can proxy map tiles.
+type TileProvider interface {
+	Tile(ctx context.Context, layer string, z, x, y int) ([]byte, string, error)
+}
+
+// Tile fetches a weather map tile PNG and its content type. Tiles skip
+// fetchRaw: a failing tile server says nothing about the weather API, so
+// it mustn't trip the circuit breaker, and a tile isn't worth a retry.
+func (c *WeatherClient) Tile(ctx context.Context, layer string, z, x, y int) ([]byte, string, error) {
+	state := c.keys.Load()
+	if state.err != nil {
+		return nil, "", state.err
+	}
+	endpoint := c.endpoints[endpointTiles] + fmt.Sprintf("/%s/%d/%d/%d.png", layer, z, x, y)
+	body, header, err := c.do(ctx, retryPolicy{}, endpoint, state.pool.Next(), url.Values{}, c.tileLimits)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, header.Get("Content-Type"), nil
+}
+
+// parseTile validates a tile request. x and y must lie within the 2^z by
+// 2^z grid for the zoom level.
+func parseTile(layer, rawZ, rawX, rawY string) (z, x, y int, err error) {
+	if !tileLayers[layer] {
+		return 0, 0, 0, fmt.Errorf("unknown tile layer %q", layer)
+	}
+	z, err = strconv.Atoi(rawZ)
+	if err != nil || z < 0 || z > maxTileZoom {
+		return 0, 0, 0, fmt.Errorf("invalid zoom %q: expected 0-%d", rawZ, maxTileZoom)
+	}
+	n := 1 << z
+	x, err = strconv.Atoi(rawX)
+	if err != nil || x < 0 || x >= n {
+		return 0, 0, 0, fmt.Errorf("invalid x %q for zoom %d", rawX, z)
+	}
+	rawY, ok := strings.CutSuffix(rawY, ".png")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("tiles must be requested as .png")
+	}
+	y, err = strconv.Atoi(rawY)
+	if err != nil || y < 0 || y >= n {
+		return 0, 0, 0, fmt.Errorf("invalid y %q for zoom %d", rawY, z)
+	}
+	return z, x, y, nil
+}
+
+type blob struct {
+	key         string
+	data        []byte
+	contentType string
+	fetchedAt   time.Time
+}
+
+// blobCache is a small TTL+LRU cache for binary payloads such as tiles,
+// bounded by both entry count and total payload bytes.
+type blobCache struct {
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	ttl      time.Duration
+	maxLen   int
+	maxBytes int64
+	bytes    int64
+}
+
+func newBlobCache(ttl time.Duration, maxLen int, maxBytes int64) *blobCache {
+	return &blobCache{entries: make(map[string]*list.Element), lru: list.New(), ttl: ttl, maxLen: maxLen, maxBytes: maxBytes}
+}
+
+func (c *blobCache) Get(key string) (blob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return blob{}, false
+	}
+	b := el.Value.(*blob)
+	if time.Since(b.fetchedAt) > c.ttl {
+		c.remove(el)
+		return blob{}, false
+	}
+	c.lru.MoveToFront(el)
+	return *b, true
+}
+
+// Set stores data under key, evicting the least recently used blobs while
+// either limit is exceeded. A blob larger than maxBytes isn't kept.
+func (c *blobCache) Set(key string, data []byte, contentType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&blob{key: key, data: data, contentType: contentType, fetchedAt: time.Now()})
+	c.bytes += int64(len(data))
+	for c.lru.Len() > c.maxLen || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops el from the cache; c.mu must be held.
+func (c *blobCache) remove(el *list.Element) {
+	b := el.Value.(*blob)
+	c.lru.Remove(el)
+	delete(c.entries, b.key)
+	c.bytes -= int64(len(b.data))
+}
+
+// Usage returns the number of blobs held and their total size.
+func (c *blobCache) Usage() (entries int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.bytes
+}
+
+func (s *server) handleTile(w http.ResponseWriter, r *http.Request) {
+	tp, ok := s.provider.(TileProvider)
+	if !ok {
+		http.Error(w, "tiles are not available", http.StatusNotImplemented)
+		return
+	}
+	layer := r.PathValue("layer")
+	z, x, y, err := parseTile(layer, r.PathValue("z"), r.PathValue("x"), r.PathValue("y"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := fmt.Sprintf("%s/%d/%d/%d", layer, z, x, y)
+	b, hit := s.tiles.Get(key)
+	if !hit {
+		data, contentType, err := tp.Tile(r.Context(), layer, z, x, y)
+		if err != nil {
+			writeQueryError(w, err)
+			return
+		}
+		if contentType == "" {
+			contentType = "image/png"
+		}
+		s.tiles.Set(key, data, contentType)
+		b = blob{data: data, contentType: contentType}
+		w.Header().Set("X-Cache", cacheMiss)
+	} else {
+		w.Header().Set("X-Cache", cacheHit)
+	}
+	w.Header().Set("Content-Type", b.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(b.data)
+}
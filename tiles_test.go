@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTileFailuresSkipBreaker checks a failing tile server is asked once
+// per tile and leaves the weather circuit breaker closed.
+func TestTileFailuresSkipBreaker(t *testing.T) {
+	var calls atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+	c := newTestClient(t, upstream)
+	c.breaker = newCircuitBreaker(1, time.Minute)
+	c.retry = retryPolicy{maxRetries: 2, backoff: time.Millisecond, statuses: map[int]bool{502: true}}
+
+	for range 3 {
+		if _, _, err := c.Tile(context.Background(), "clouds_new", 1, 0, 0); err == nil {
+			t.Fatal("Tile succeeded against a failing server")
+		}
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("%d upstream calls for 3 tiles, want 3", got)
+	}
+	if state := c.BreakerState(); state != breakerClosed {
+		t.Errorf("breaker %s after tile failures, want %s", state, breakerClosed)
+	}
+}
+
+func TestParseTile(t *testing.T) {
+	if z, x, y, err := parseTile("clouds_new", "2", "3", "1.png"); err != nil || z != 2 || x != 3 || y != 1 {
+		t.Errorf("parseTile = %d/%d/%d, %v; want 2/3/1", z, x, y, err)
+	}
+	for _, tt := range [][4]string{
+		{"roads", "2", "3", "1.png"},
+		{"clouds_new", "19", "0", "0.png"},
+		{"clouds_new", "2", "4", "1.png"},
+		{"clouds_new", "2", "3", "-1.png"},
+		{"clouds_new", "2", "3", "1.jpg"},
+	} {
+		if _, _, _, err := parseTile(tt[0], tt[1], tt[2], tt[3]); err == nil {
+			t.Errorf("parseTile(%q) succeeded, want an error", tt)
+		}
+	}
+}
+
+func TestBlobCacheByteLimit(t *testing.T) {
+	c := newBlobCache(time.Minute, 10, 10)
+	c.Set("a", make([]byte, 4), "image/png")
+	c.Set("b", make([]byte, 4), "image/png")
+	c.Set("c", make([]byte, 4), "image/png")
+	if _, ok := c.Get("a"); ok {
+		t.Error("oldest blob kept past the byte limit")
+	}
+	if n, bytes := c.Usage(); n != 2 || bytes != 8 {
+		t.Errorf("Usage() = %d, %d; want 2 blobs, 8 bytes", n, bytes)
+	}
+	c.Set("huge", make([]byte, 11), "image/png")
+	if _, ok := c.Get("huge"); ok {
+		t.Error("blob larger than the whole limit was kept")
+	}
+}
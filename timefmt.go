@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	// Zone names for ?tz= must resolve even where the host has no zoneinfo.
+	_ "time/tzdata"
+)
+
+const (
+	clock24h = "15:04"
+	clock12h = "3:04 PM"
+)
+
+// clockLayout is chosen with TIME_FORMAT=12h|24h and defaults to 24-hour.
+var clockLayout = loadClockLayout()
+
+func loadClockLayout() string {
+	switch v := os.Getenv("TIME_FORMAT"); v {
+	case "", "24h":
+		return clock24h
+	case "12h":
+		return clock12h
+	default:
+		log.Printf("ignoring invalid TIME_FORMAT=%q: expected 12h or 24h", v)
+		return clock24h
+	}
+}
+
+func formatClock(t time.Time) string {
+	return t.Format(clockLayout)
+}
+
+// formatDateTime prefixes the clock time with the day, for listings such
+// as the forecast that span several days.
+func formatDateTime(t time.Time) string {
+	return t.Format("Mon 02 Jan ") + formatClock(t)
+}
+
+// formatDaylight renders a duration as hours and minutes, e.g. "9h 42m".
+func formatDaylight(d time.Duration) string {
+	m := int(d.Round(time.Minute).Minutes())
+	return fmt.Sprintf("%dh %02dm", m/60, m%60)
+}
+
+// localTime converts a Unix timestamp to the city's local time, or to
+// DisplayZone's when the client chose one.
+func (w WeatherData) localTime(unix int64) time.Time {
+	if w.DisplayZone != nil {
+		return time.Unix(unix, 0).In(w.DisplayZone)
+	}
+	return time.Unix(unix, 0).In(time.FixedZone("", w.Timezone))
+}
+
+// zoneLabel names the zone localTime uses, for reports that mark their
+// times with it.
+func (w WeatherData) zoneLabel() string {
+	if w.DisplayZone != nil {
+		return w.DisplayZone.String()
+	}
+	return "local"
+}
+
+// parseTZ validates ?tz=, an IANA zone name such as America/New_York. ""
+// keeps the city's own zone.
+func parseTZ(raw string) (*time.Location, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	// LoadLocation takes "Local" as the server's zone, which means nothing
+	// to a client.
+	if raw == "Local" {
+		return nil, errors.New(`invalid timezone "Local": expected an IANA name such as America/New_York`)
+	}
+	loc, err := time.LoadLocation(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: expected an IANA name such as America/New_York", stripControl(raw))
+	}
+	return loc, nil
+}
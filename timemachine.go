@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TimeMachineProvider is implemented by providers that can report the
+// weather at a past moment.
+type TimeMachineProvider interface {
+	WeatherAt(ctx context.Context, lat, lon float64, dt int64, units string) (WeatherData, error)
+}
+
+var errTimeMachineDisabled = errors.New("historical weather needs the One Call API, set ONECALL_ENABLED=true")
+
+// errNoHistoricalData is returned by WeatherAt when the timemachine has
+// nothing for the requested time; handlers answer it with 404.
+var errNoHistoricalData = errors.New("no historical data")
+
+// timeMachineEarliest is the oldest timestamp One Call's timemachine
+// has data for, 1979-01-01 UTC.
+const timeMachineEarliest = 283996800
+
+// timeMachineData is One Call's timemachine response. Data holds a single
+// entry, the observation nearest the requested time.
+type timeMachineData struct {
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	Timezone       string  `json:"timezone"`
+	TimezoneOffset int     `json:"timezone_offset"`
+	Data           []struct {
+		Dt        int64       `json:"dt"`
+		Sunrise   int64       `json:"sunrise"`
+		Sunset    int64       `json:"sunset"`
+		Temp      float64     `json:"temp"`
+		FeelsLike float64     `json:"feels_like"`
+		Pressure  int         `json:"pressure"`
+		Humidity  int         `json:"humidity"`
+		UVI       float64     `json:"uvi"`
+		Clouds    int         `json:"clouds"`
+		WindSpeed float64     `json:"wind_speed"`
+		WindDeg   int         `json:"wind_deg"`
+		Weather   []Condition `json:"weather"`
+	} `json:"data"`
+}
+
+// WeatherAt fetches the weather at lat, lon at the Unix time dt. The
+// result carries no city name; callers fill that in.
+func (c *WeatherClient) WeatherAt(ctx context.Context, lat, lon float64, dt int64, units string) (WeatherData, error) {
+	if !oneCallEnabled {
+		return WeatherData{}, errTimeMachineDisabled
+	}
+	params := url.Values{
+		"lat": {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon": {strconv.FormatFloat(lon, 'f', -1, 64)},
+		"dt":  {strconv.FormatInt(dt, 10)},
+	}
+	if units != unitsStandard {
+		params.Set("units", units)
+	}
+	var data timeMachineData
+	if err := c.fetch(ctx, endpointTimeMachine, params, &data); err != nil {
+		return WeatherData{}, err
+	}
+	if len(data.Data) == 0 {
+		return WeatherData{}, fmt.Errorf("%w for %d", errNoHistoricalData, dt)
+	}
+	at := data.Data[0]
+	var w WeatherData
+	w.Coord.Lat, w.Coord.Lon = data.Lat, data.Lon
+	w.Dt = at.Dt
+	w.Timezone = data.TimezoneOffset
+	w.Main.Temp = at.Temp
+	w.Main.FeelsLike = at.FeelsLike
+	// The timemachine reports a single reading, not a range.
+	w.Main.TempMin, w.Main.TempMax = at.Temp, at.Temp
+	w.Main.Pressure = at.Pressure
+	w.Main.Humidity = at.Humidity
+	w.Weather = at.Weather
+	w.Wind.Speed, w.Wind.Deg = at.WindSpeed, at.WindDeg
+	w.Clouds.All = at.Clouds
+	w.Sys.Sunrise, w.Sys.Sunset = at.Sunrise, at.Sunset
+	w.UVI, w.HasUVI = at.UVI, true
+	w.Units = units
+	w.Historical = true
+	return w, nil
+}
+
+// parseHistoryTimestamp validates ?dt= as a Unix time between
+// timeMachineEarliest and now.
+func parseHistoryTimestamp(raw string, now time.Time) (int64, error) {
+	dt, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid dt %q: expected a Unix timestamp", raw)
+	}
+	if dt < timeMachineEarliest {
+		return 0, fmt.Errorf("dt %d is too early: historical data starts at %d (1979-01-01)", dt, timeMachineEarliest)
+	}
+	if dt >= now.Unix() {
+		return 0, fmt.Errorf("dt %d is not in the past", dt)
+	}
+	return dt, nil
+}
+
+// handleWeatherAt serves /weather/{city}/history?dt=. It looks the city up
+// through the weather cache for its coordinates, then formats the
+// timemachine result like current weather.
+func (s *server) handleWeatherAt(w http.ResponseWriter, r *http.Request, city string) {
+	tp, ok := s.provider.(TimeMachineProvider)
+	if !ok || !oneCallEnabled {
+		http.Error(w, errTimeMachineDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+	dt, err := parseHistoryTimestamp(r.URL.Query().Get("dt"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	units := requestUnits(r)
+	formatter, asciiOnly, err := reportFormatter(r, units, r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tz, err := parseTZ(r.URL.Query().Get("tz"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upstreamUnits := fetchUnits(units)
+	current, _, err := s.cache.Get(r.Context(), cacheKey(city, upstreamUnits), func(ctx context.Context) (WeatherData, error) {
+		return s.fetchCurrent(ctx, city, upstreamUnits)
+	})
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	data, err := tp.WeatherAt(r.Context(), current.Coord.Lat, current.Coord.Lon, dt, upstreamUnits)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	data.ID, data.Name, data.Sys.Country = current.ID, current.Name, current.Sys.Country
+	data.DisplayZone = tz
+	setLocationHeaders(w, data.Location())
+	w.Header().Add("Vary", "Accept-Charset")
+	writeReport(w, formatter, asciiOnly, data)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTimestamp(t *testing.T) {
+	now := time.Unix(1718798400, 0)
+	for raw, wantErr := range map[string]bool{
+		"1718712000": false,
+		"283996800":  false,
+		"283996799":  true,
+		"1718798400": true,
+		"yesterday":  true,
+	} {
+		if _, err := parseHistoryTimestamp(raw, now); (err != nil) != wantErr {
+			t.Errorf("parseHistoryTimestamp(%q) error = %v, want error %v", raw, err, wantErr)
+		}
+	}
+}
+
+// timeMachineProvider answers WeatherAt with a fixed result.
+type timeMachineProvider struct {
+	*FakeProvider
+	data WeatherData
+	err  error
+}
+
+func (p timeMachineProvider) WeatherAt(context.Context, float64, float64, int64, string) (WeatherData, error) {
+	return p.data, p.err
+}
+
+// TestWeatherAtNoData checks an empty timemachine answer is a 404, both as
+// the client reports it and as the handler serves it.
+func TestWeatherAtNoData(t *testing.T) {
+	defer func(prev bool) { oneCallEnabled = prev }(oneCallEnabled)
+	oneCallEnabled = true
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"lat":51.5,"lon":-0.12,"timezone_offset":0,"data":[]}`))
+	}))
+	defer upstream.Close()
+	if _, err := newTestClient(t, upstream).WeatherAt(context.Background(), 51.5, -0.12, 1718712000, unitsMetric); !errors.Is(err, errNoHistoricalData) {
+		t.Fatalf("WeatherAt with no data = %v, want errNoHistoricalData", err)
+	}
+
+	fake := newTestServer().provider.(*FakeProvider)
+	s := newServer(timeMachineProvider{FakeProvider: fake, err: errNoHistoricalData}, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	dt := strconv.FormatInt(time.Now().Add(-24*time.Hour).Unix(), 10)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London/history?dt="+dt, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+// TestWeatherAtOptions checks ?dt= reports take the same options as
+// current weather, such as ASCII output for clients without UTF-8.
+func TestWeatherAtOptions(t *testing.T) {
+	defer func(prev bool) { oneCallEnabled = prev }(oneCallEnabled)
+	oneCallEnabled = true
+
+	past := sampleWeather()
+	past.Historical = true
+	fake := newTestServer().provider.(*FakeProvider)
+	s := newServer(timeMachineProvider{FakeProvider: fake, data: past}, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	dt := strconv.FormatInt(time.Now().Add(-24*time.Hour).Unix(), 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/weather/London/history?dt="+dt, nil)
+	req.Header.Set("Accept-Charset", "us-ascii")
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/plain; charset=us-ascii" {
+		t.Errorf("GET with Accept-Charset: us-ascii = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London/history?dt="+dt+"&order=kelvin", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET with ?order=kelvin = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OpenWeather unit systems. Temperatures are K, °C and °F respectively;
+// wind speed is m/s for standard and metric, mph for imperial.
+const (
+	unitsStandard = "standard"
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+)
+
+// UnitSet holds the labels reports print for one unit system. Temp
+// carries its own spacing since Kelvin takes no degree sign.
+type UnitSet struct {
+	Temp     string
+	Speed    string
+	Pressure string
+}
+
+var unitSets = map[string]UnitSet{
+	unitsMetric:   {Temp: "°C", Speed: "m/s", Pressure: "hPa"},
+	unitsImperial: {Temp: "°F", Speed: "mph", Pressure: "inHg"},
+	unitsStandard: {Temp: " K", Speed: "m/s", Pressure: "hPa"},
+}
+
+// unitSetFor returns the labels for units, where "" is metric.
+func unitSetFor(units string) UnitSet {
+	if set, ok := unitSets[units]; ok {
+		return set
+	}
+	return unitSets[unitsMetric]
+}
+
+// plain drops the degree sign for ASCII-only output.
+func (u UnitSet) plain() UnitSet {
+	u.Temp = strings.ReplaceAll(u.Temp, "°", "")
+	return u
+}
+
+// defaultUnits is DEFAULT_UNITS, used when a request has no ?units=.
+var defaultUnits = loadDefaultUnits()
+
+func loadDefaultUnits() string {
+	switch units := os.Getenv("DEFAULT_UNITS"); units {
+	case "":
+	case unitsMetric, unitsImperial, unitsStandard:
+		return units
+	default:
+		log.Printf("ignoring DEFAULT_UNITS %q: expected metric, imperial or standard", units)
+	}
+	return unitsMetric
+}
+
+// requestUnits returns ?units=, or defaultUnits when it is absent.
+func requestUnits(r *http.Request) string {
+	if units := r.URL.Query().Get("units"); units != "" {
+		return units
+	}
+	return defaultUnits
+}
+
+// alwaysFetchStandard makes every upstream request use standard units so a
+// single cache entry per city serves clients asking for any unit system.
+var alwaysFetchStandard = os.Getenv("ALWAYS_FETCH_STANDARD") == "true"
+
+// fetchUnits returns the units to request from OpenWeather for a client
+// asking for units, where "" is defaultUnits.
+func fetchUnits(units string) string {
+	switch {
+	case alwaysFetchStandard:
+		return unitsStandard
+	case units == "":
+		return defaultUnits
+	}
+	return units
+}
+
+func kelvinToCelsius(k float64) float64 {
+	return k - 273.15
+}
+
+func kelvinToFahrenheit(k float64) float64 {
+	return kelvinToCelsius(k)*9/5 + 32
+}
+
+func metersPerSecondToMph(ms float64) float64 {
+	return ms * 2.23694
+}
+
+// Pressure units. OpenWeather always reports hPa whatever the unit system.
+const (
+	pressureHPa  = "hPa"
+	pressureInHg = "inHg"
+	pressureMmHg = "mmHg"
+)
+
+// pressureDecimals is how many decimals each pressure unit is shown with.
+var pressureDecimals = map[string]int{
+	pressureHPa:  0,
+	pressureInHg: 2,
+	pressureMmHg: 0,
+}
+
+// parsePressureUnit validates ?pressureUnit=, ignoring case. "" leaves the
+// unit to the unit system.
+func parsePressureUnit(raw string) (string, error) {
+	for unit := range pressureDecimals {
+		if strings.EqualFold(raw, unit) {
+			return unit, nil
+		}
+	}
+	if raw == "" {
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported pressure unit %q: expected hPa, inHg or mmHg", raw)
+}
+
+// convertPressure converts a pressure in hPa to unit.
+func convertPressure(hPa float64, unit string) float64 {
+	switch unit {
+	case pressureInHg:
+		return hPa * 0.02953
+	case pressureMmHg:
+		return hPa * 0.750062
+	}
+	return hPa
+}
+
+// pressureIn converts a pressure in hPa to unit, rounded to the unit's
+// pressureDecimals.
+func pressureIn(hPa int, unit string) float64 {
+	return roundTemp(convertPressure(float64(hPa), unit), pressureDecimals[unit])
+}
+
+// formatPressure renders a pressure in hPa as unit, without the label.
+func formatPressure(hPa int, unit string) string {
+	return strconv.FormatFloat(pressureIn(hPa, unit), 'f', pressureDecimals[unit], 64)
+}
+
+// convertTemp converts a temperature between unit systems. An empty unit
+// system means standard, which is what OpenWeather defaults to.
+func convertTemp(v float64, from, to string) float64 {
+	k := v
+	switch from {
+	case unitsMetric:
+		k = v + 273.15
+	case unitsImperial:
+		k = (v-32)*5/9 + 273.15
+	}
+	switch to {
+	case unitsMetric:
+		return kelvinToCelsius(k)
+	case unitsImperial:
+		return kelvinToFahrenheit(k)
+	}
+	return k
+}
+
+func convertSpeed(v float64, from, to string) float64 {
+	if from == unitsImperial {
+		v /= 2.23694
+	}
+	if to == unitsImperial {
+		return metersPerSecondToMph(v)
+	}
+	return v
+}
+
+func (w WeatherData) celsius(v float64) float64 {
+	return convertTemp(v, w.Units, unitsMetric)
+}
+
+func (w WeatherData) kelvin(v float64) float64 {
+	return convertTemp(v, w.Units, unitsStandard)
+}
+
+func (w WeatherData) fahrenheit(v float64) float64 {
+	return convertTemp(v, w.Units, unitsImperial)
+}
+
+func (f ForecastData) celsius(v float64) float64 {
+	return convertTemp(v, f.Units, unitsMetric)
+}
+
+func (f ForecastData) kelvin(v float64) float64 {
+	return convertTemp(v, f.Units, unitsStandard)
+}
+
+func (w WeatherData) windSpeed(units string) float64 {
+	return convertSpeed(w.Wind.Speed, w.Units, units)
+}
+
+// roundTemp rounds v to decimals places, halves away from zero. A result
+// of -0, from a small negative value, becomes 0 so it never prints "-0".
+func roundTemp(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	v = math.Round(v*scale) / scale
+	if v == 0 {
+		return 0
+	}
+	return v
+}
+
+// formatTemp renders v with decimals places. Negative values keep a plain
+// minus sign and positive ones have no sign at all.
+func formatTemp(v float64, decimals int) string {
+	return strconv.FormatFloat(roundTemp(v, decimals), 'f', decimals, 64)
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormatTemp(t *testing.T) {
+	tests := []struct {
+		v        float64
+		decimals int
+		want     string
+	}{
+		{15.456, 2, "15.46"},
+		{-0.001, 2, "0.00"},
+		{-0.004, 1, "0.0"},
+		{-0.4, 0, "0"},
+		{-0.5, 0, "-1"},
+		{-3.14159, 1, "-3.1"},
+		{0.05, 1, "0.1"},
+		{283.15, 1, "283.2"},
+	}
+	for _, tt := range tests {
+		if got := formatTemp(tt.v, tt.decimals); got != tt.want {
+			t.Errorf("formatTemp(%v, %d) = %q, want %q", tt.v, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+// forecastIn is London's sample forecast as if fetched in units.
+func forecastIn(units string) ForecastData {
+	f := sampleForecast()
+	to := units
+	if to == "" {
+		to = unitsStandard
+	}
+	for i := range f.List {
+		m := &f.List[i].Main
+		m.Temp, m.FeelsLike = convertTemp(m.Temp, unitsStandard, to), convertTemp(m.FeelsLike, unitsStandard, to)
+		m.TempMin, m.TempMax = convertTemp(m.TempMin, unitsStandard, to), convertTemp(m.TempMax, unitsStandard, to)
+		f.List[i].Wind.Speed = convertSpeed(f.List[i].Wind.Speed, unitsStandard, to)
+	}
+	f.Units = units
+	return f
+}
+
+// TestForecastUnits renders the forecast fetched in each unit system and
+// expects the same readings from every view of it: the text and JSON
+// forecast, the daily rollup and the 24h delta.
+func TestForecastUnits(t *testing.T) {
+	const (
+		firstC = 289.1 - 273.15
+		lastC  = 288.6 - 273.15
+		maxK   = 291.4
+		minK   = 288.2
+	)
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	for _, fetched := range []string{unitsStandard, unitsMetric, unitsImperial, ""} {
+		f := forecastIn(fetched)
+
+		if text := f.FormatOutput(""); !strings.Contains(text, "15.95°C (60.71°F)") {
+			t.Errorf("fetched in %q: text forecast lacks 15.95°C (60.71°F):\n%s", fetched, text)
+		}
+
+		var js struct {
+			List []forecastEntryJSON `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(f.FormatJSON("")), &js); err != nil || len(js.List) != 3 {
+			t.Fatalf("fetched in %q: %v: %s", fetched, err, f.FormatJSON(""))
+		}
+		if e := js.List[0]; !near(e.Temperature, firstC) || !near(e.WindSpeed, 4.1) {
+			t.Errorf("fetched in %q: first JSON step %.4f °C, wind %.4f m/s; want %.2f and 4.1", fetched, e.Temperature, e.WindSpeed, firstC)
+		}
+
+		days := dailyRollup(f)
+		if len(days) != 1 || !near(days[0].Max, maxK) || !near(days[0].Min, minK) {
+			t.Errorf("fetched in %q: rollup %+v, want one day from %v K to %v K", fetched, days, minK, maxK)
+		}
+
+		d, err := forecastDelta(f)
+		if err != nil || !near(d.From, firstC) || !near(d.To, lastC) {
+			t.Errorf("fetched in %q: delta %+v, %v; want %.2f to %.2f °C", fetched, d, err, firstC, lastC)
+		}
+	}
+}
+
+// TestForecastDisplayUnits checks the text and JSON forecast are shown in
+// the units asked for, whichever units they were fetched in.
+func TestForecastDisplayUnits(t *testing.T) {
+	tests := []struct {
+		units    string
+		text     string
+		wantUnit string
+		temp     float64
+		wind     float64
+	}{
+		{"", "15.95°C (60.71°F)", unitsMetric, 15.95, 4.1},
+		{unitsMetric, "15.95°C (60.71°F)", unitsMetric, 15.95, 4.1},
+		{unitsImperial, "60.71°F (15.95°C)", unitsImperial, 60.71, 9.17},
+		{unitsStandard, "289.10 K (15.95°C)", unitsStandard, 289.1, 4.1},
+	}
+	for _, tt := range tests {
+		for _, fetched := range []string{unitsStandard, unitsMetric, unitsImperial} {
+			f := forecastIn(fetched)
+			if text := f.FormatOutput(tt.units); !strings.Contains(text, tt.text) {
+				t.Errorf("units %q, fetched in %s: text lacks %q:\n%s", tt.units, fetched, tt.text, text)
+			}
+			var js struct {
+				Units string              `json:"units"`
+				List  []forecastEntryJSON `json:"list"`
+			}
+			if err := json.Unmarshal([]byte(f.FormatJSON(tt.units)), &js); err != nil || len(js.List) == 0 {
+				t.Fatalf("units %q: %v", tt.units, err)
+			}
+			e := js.List[0]
+			if js.Units != tt.wantUnit || math.Abs(e.Temperature-tt.temp) > 0.005 || math.Abs(e.WindSpeed-tt.wind) > 0.005 {
+				t.Errorf("units %q, fetched in %s: JSON %s, %.4f, wind %.4f; want %s, %v, %v", tt.units, fetched, js.Units, e.Temperature, e.WindSpeed, tt.wantUnit, tt.temp, tt.wind)
+			}
+		}
+	}
+}
+
+func TestConvertTemp(t *testing.T) {
+	tests := []struct {
+		v        float64
+		from, to string
+		want     float64
+	}{
+		{288.15, unitsStandard, unitsMetric, 15},
+		{288.15, unitsStandard, unitsImperial, 59},
+		{288.15, unitsStandard, unitsStandard, 288.15},
+		{15, unitsMetric, unitsStandard, 288.15},
+		{15, unitsMetric, unitsImperial, 59},
+		{15, unitsMetric, unitsMetric, 15},
+		{59, unitsImperial, unitsMetric, 15},
+		{59, unitsImperial, unitsStandard, 288.15},
+		{-40, unitsImperial, unitsMetric, -40},
+		// "" is standard on either side.
+		{288.15, "", unitsMetric, 15},
+		{15, unitsMetric, "", 288.15},
+		{288.15, "", "", 288.15},
+	}
+	for _, tt := range tests {
+		if got := convertTemp(tt.v, tt.from, tt.to); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("convertTemp(%v, %q, %q) = %v, want %v", tt.v, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestFetchUnits(t *testing.T) {
+	defer func(units string, standard bool) { defaultUnits, alwaysFetchStandard = units, standard }(defaultUnits, alwaysFetchStandard)
+
+	defaultUnits, alwaysFetchStandard = unitsImperial, false
+	for in, want := range map[string]string{"": unitsImperial, unitsMetric: unitsMetric, unitsImperial: unitsImperial, unitsStandard: unitsStandard} {
+		if got := fetchUnits(in); got != want {
+			t.Errorf("fetchUnits(%q) = %q, want %q", in, got, want)
+		}
+	}
+	alwaysFetchStandard = true
+	for _, in := range []string{"", unitsMetric, unitsImperial, unitsStandard} {
+		if got := fetchUnits(in); got != unitsStandard {
+			t.Errorf("with ALWAYS_FETCH_STANDARD, fetchUnits(%q) = %q, want standard", in, got)
+		}
+	}
+}
+
+// weatherIn is London's sample weather as if OpenWeather had been asked for
+// units: the same physical readings, in that system's numbers.
+func weatherIn(units string) WeatherData {
+	w := sampleWeather()
+	to := units
+	if to == "" {
+		to = unitsStandard
+	}
+	m := &w.Main
+	m.Temp, m.FeelsLike = convertTemp(m.Temp, unitsStandard, to), convertTemp(m.FeelsLike, unitsStandard, to)
+	m.TempMin, m.TempMax = convertTemp(m.TempMin, unitsStandard, to), convertTemp(m.TempMax, unitsStandard, to)
+	w.Wind.Speed = convertSpeed(w.Wind.Speed, unitsStandard, to)
+	w.Units = units
+	return w
+}
+
+// TestUnitsRegression renders the same weather fetched in every unit
+// system, in every requested one, and expects the same physical values:
+// a 15 °C payload must not be taken for 15 K, nor 288.15 K for °C.
+func TestUnitsRegression(t *testing.T) {
+	text := map[string]string{
+		unitsMetric:   "Temperature: 15.00°C (59.00°F)",
+		unitsImperial: "Temperature: 59.00°F (15.00°C)",
+		unitsStandard: "Temperature: 288.15 K (15.00°C)",
+	}
+	jsonTemp := map[string]float64{unitsMetric: 15, unitsImperial: 59, unitsStandard: 288.15}
+
+	for _, fetched := range []string{unitsStandard, unitsMetric, unitsImperial, ""} {
+		w := weatherIn(fetched)
+		if got := w.FormatOutput(); !strings.Contains(got, text[unitsMetric]) {
+			t.Errorf("fetched in %q: FormatOutput lacks %q:\n%s", fetched, text[unitsMetric], got)
+		}
+		for requested, want := range text {
+			f, err := selectFormatter(requested, "text", ReportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Format(w); !strings.Contains(got, want) {
+				t.Errorf("fetched in %q, text in %s: lacks %q:\n%s", fetched, requested, want, got)
+			}
+
+			f, err = selectFormatter(requested, "json", ReportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out struct {
+				Units       string   `json:"units"`
+				Temperature *float64 `json:"temperature"`
+			}
+			if err := json.Unmarshal([]byte(f.Format(w)), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Units != requested || out.Temperature == nil || math.Abs(*out.Temperature-jsonTemp[requested]) > 1e-9 {
+				t.Errorf("fetched in %q, JSON in %s: units %q temperature %v, want %s %v",
+					fetched, requested, out.Units, ptrString(out.Temperature), requested, jsonTemp[requested])
+			}
+		}
+	}
+}
+
+// TestZeroKelvinWithEmptyUnits checks that a 0 in data with no Units is
+// a missing Kelvin reading: convertTemp takes "" as standard, so
+// tempKnown must too, or it would print -273.15 °C.
+func TestZeroKelvinWithEmptyUnits(t *testing.T) {
+	defer func(guard string) { absoluteZeroGuard = guard }(absoluteZeroGuard)
+	absoluteZeroGuard = zeroGuardNA
+
+	for _, units := range []string{"", unitsStandard} {
+		w := sampleWeather()
+		w.Units, w.Main.Temp = units, 0
+		if w.tempKnown(w.Main.Temp) {
+			t.Errorf("Units %q: tempKnown(0) = true, want false", units)
+		}
+		if got := w.FormatOutput(); !strings.Contains(got, "Temperature: "+notAvailable) || strings.Contains(got, "-273.15") {
+			t.Errorf("Units %q: report doesn't show the temperature as %s:\n%s", units, notAvailable, got)
+		}
+		out := JSONFormatter{Units: unitsMetric}.Format(w)
+		if !strings.Contains(out, `"temperature":null`) {
+			t.Errorf("Units %q: JSON temperature isn't null: %s", units, out)
+		}
+	}
+	for _, units := range []string{unitsMetric, unitsImperial} {
+		w := weatherIn(units)
+		w.Main.Temp = 0
+		if !w.tempKnown(w.Main.Temp) {
+			t.Errorf("Units %q: tempKnown(0) = false, a 0 in %s is a real reading", units, units)
+		}
+	}
+}
+
+// TestUnitsThroughServer asks the server for each unit system while the
+// provider hands back metric data, the case that used to double-convert.
+func TestUnitsThroughServer(t *testing.T) {
+	h := newServer(&FakeProvider{
+		Weather: map[string]WeatherData{cacheKey("London"): weatherIn(unitsMetric)},
+	}, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries)).handler()
+
+	for units, want := range map[string]string{
+		unitsMetric:   "15.00°C (59.00°F)",
+		unitsImperial: "59.00°F (15.00°C)",
+		unitsStandard: "288.15 K (15.00°C)",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/weather/London?units="+units, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("?units=%s: got %d, want 200 with %q:\n%s", units, rec.Code, want, rec.Body.String())
+		}
+		if got := rec.Header().Get("X-Temperature-Celsius"); got != "15.00" {
+			t.Errorf("?units=%s: X-Temperature-Celsius = %q, want 15.00", units, got)
+		}
+	}
+}
+
+func ptrString(v *float64) any {
+	if v == nil {
+		return "null"
+	}
+	return *v
+}
+
+func TestParsePressureUnit(t *testing.T) {
+	for raw, want := range map[string]string{"": "", "hpa": pressureHPa, "INHG": pressureInHg, "mmHg": pressureMmHg} {
+		if got, err := parsePressureUnit(raw); err != nil || got != want {
+			t.Errorf("parsePressureUnit(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := parsePressureUnit("psi"); err == nil {
+		t.Error("parsePressureUnit(\"psi\") accepted an unknown unit")
+	}
+}
+
+func TestFormatPressure(t *testing.T) {
+	for unit, want := range map[string]string{pressureHPa: "1013", pressureInHg: "29.91", pressureMmHg: "760"} {
+		if got := formatPressure(1013, unit); got != want {
+			t.Errorf("formatPressure(1013, %s) = %s, want %s", unit, got, want)
+		}
+	}
+}
+
+// TestPressureUnitInReports checks imperial reports default to inHg and
+// ?pressureUnit= overrides the unit system either way.
+func TestPressureUnitInReports(t *testing.T) {
+	w := sampleWeather()
+	w.Main.Pressure = 1013
+	tests := []struct {
+		units, unit string
+		want        string
+	}{
+		{unitsMetric, "", "Pressure: 1013 hPa"},
+		{unitsImperial, "", "Pressure: 29.91 inHg"},
+		{unitsImperial, pressureHPa, "Pressure: 1013 hPa"},
+		{unitsMetric, pressureMmHg, "Pressure: 760 mmHg"},
+	}
+	for _, tt := range tests {
+		f, err := selectFormatter(tt.units, "text", ReportOptions{NoEmoji: true, PressureUnit: tt.unit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Format(w); !strings.Contains(got, tt.want) {
+			t.Errorf("%s with pressureUnit %q: lacks %q:\n%s", tt.units, tt.unit, tt.want, got)
+		}
+	}
+	out := JSONFormatter{Units: unitsImperial}.Format(w)
+	if !strings.Contains(out, `"pressure":29.91,"pressure_unit":"inHg"`) {
+		t.Errorf("imperial JSON pressure isn't in inHg: %s", out)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	openWeatherBaseURL = "http://api.openweathermap.org"
+	openWeatherTileURL = "http://tile.openweathermap.org"
+)
+
+// UpstreamError is returned when OpenWeather answers with a non-200 status.
+type UpstreamError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("openweather returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("openweather returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// errUpstreamTooLarge is returned when a response exceeds its fetchLimits.
+var errUpstreamTooLarge = errors.New("openweather response too large")
+
+// fetchLimits bound a single upstream call on top of the client-wide
+// timeout. Zero values leave that dimension unlimited.
+type fetchLimits struct {
+	timeout  time.Duration
+	maxBytes int64
+	// binary marks a payload that isn't JSON, such as a map tile, which
+	// lastResponses doesn't keep.
+	binary bool
+}
+
+// WeatherClient is the Provider backed by the OpenWeather HTTP API.
+type WeatherClient struct {
+	httpClient *http.Client
+	// endpoints maps endpoint names such as endpointWeather to URLs.
+	endpoints  map[string]string
+	configFile string
+	tileLimits fetchLimits
+	retry      retryPolicy
+	breaker    *circuitBreaker
+	// slots bounds how many OpenWeather calls run at once.
+	slots chan struct{}
+	// keys is swapped as a whole by Reload so requests never see a
+	// half-updated key set.
+	keys atomic.Pointer[apiKeyState]
+}
+
+// apiKeyState is the outcome of loading the keys: a failed load is kept so
+// that every request reports why no key is available.
+type apiKeyState struct {
+	pool *keyPool
+	err  error
+}
+
+// newUpstreamTransport returns the transport used for OpenWeather calls.
+// Every request goes to the same few hosts, so the per-host idle pool is
+// raised from Go's default of 2 to keep connections (and, over HTTPS, TLS
+// sessions) warm under load:
+//
+//	UPSTREAM_MAX_IDLE_CONNS           total idle connections (default 100)
+//	UPSTREAM_MAX_IDLE_CONNS_PER_HOST  idle connections per host (default 20)
+//	UPSTREAM_IDLE_CONN_TIMEOUT        how long idle connections live (default 90s)
+//
+// UPSTREAM_PROXY, when set, is used for every upstream request instead of
+// whatever HTTP_PROXY/HTTPS_PROXY say.
+func newUpstreamTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = envInt("UPSTREAM_MAX_IDLE_CONNS", 100)
+	t.MaxIdleConnsPerHost = envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 20)
+	t.IdleConnTimeout = envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second)
+	t.ForceAttemptHTTP2 = true
+	if raw := os.Getenv("UPSTREAM_PROXY"); raw != "" {
+		proxy, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UPSTREAM_PROXY: %w", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid UPSTREAM_PROXY %q: scheme must be http, https or socks5", proxy.Redacted())
+		}
+		if proxy.Host == "" {
+			return nil, fmt.Errorf("invalid UPSTREAM_PROXY %q: missing host", proxy.Redacted())
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t, nil
+}
+
+// configFilePath is CONFIG_FILE, or .apiConfig in the working directory.
+// The extension picks the format, see decodeConfig.
+func configFilePath() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return ".apiConfig"
+}
+
+func NewWeatherClient() (*WeatherClient, error) {
+	transport, err := newUpstreamTransport()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := loadEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	c := &WeatherClient{
+		httpClient: &http.Client{Transport: transport, Timeout: envDuration("UPSTREAM_TIMEOUT", 10*time.Second)},
+		endpoints:  endpoints,
+		configFile: configFilePath(),
+		retry:      loadRetryPolicy(),
+		slots:      make(chan struct{}, max(1, envInt("UPSTREAM_CONCURRENCY", 4))),
+		breaker:    newCircuitBreaker(envInt("BREAKER_THRESHOLD", 5), envDuration("BREAKER_COOLDOWN", 30*time.Second)),
+		tileLimits: fetchLimits{
+			timeout:  envDuration("TILE_TIMEOUT", 5*time.Second),
+			maxBytes: int64(envInt("TILE_MAX_BYTES", 1<<20)),
+			binary:   true,
+		},
+	}
+	keys, err := c.loadKeysAtStartup()
+	c.keys.Store(&apiKeyState{pool: newKeyPool(keys), err: err})
+	if err != nil {
+		log.Printf("no OpenWeather API key loaded: %v", err)
+	}
+	return c, nil
+}
+
+// BreakerState reports the upstream circuit breaker state.
+func (c *WeatherClient) BreakerState() string {
+	return c.breaker.State()
+}
+
+// fetch calls the named OpenWeather endpoint with the configured key and
+// decodes the JSON response into v. A body that isn't valid JSON, usually
+// one cut short by a proxy, is fetched again up to decodeRetries times.
+func (c *WeatherClient) fetch(ctx context.Context, endpoint string, params url.Values, v any) error {
+	for attempt := 0; ; attempt++ {
+		body, _, err := c.fetchRaw(ctx, c.endpoints[endpoint], params)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(body, v)
+		if err == nil {
+			recordUpstreamSuccess()
+			return nil
+		}
+		// do leaves the key it sent in params.
+		decodeErr := newDecodeError(body, params.Get("APPID"), err)
+		// Unmarshal stops before filling in v on a syntax error, so a
+		// retry starts clean; a type mismatch would just happen again.
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) || attempt >= c.retry.decodeRetries {
+			return decodeErr
+		}
+		log.Printf("upstream %s (attempt %d): %v, retrying", endpoint, attempt+1, decodeErr)
+		if err := sleepCtx(ctx, c.retry.backoff<<attempt); err != nil {
+			return decodeErr
+		}
+	}
+}
+
+// decodeSnippetBytes caps how much of an unparseable body a decodeError
+// quotes, so one bad response can't flood the log.
+const decodeSnippetBytes = 256
+
+// decodeError is returned when a 200 response isn't the JSON we expected.
+// Body is the start of what OpenWeather sent, with the API key redacted.
+type decodeError struct {
+	Body      string
+	Truncated bool
+	Err       error
+}
+
+func newDecodeError(body []byte, key string, err error) *decodeError {
+	// Redacted before it's cut, which could split the key.
+	snippet := redactKey(string(body), key)
+	e := &decodeError{Err: err, Truncated: len(snippet) > decodeSnippetBytes}
+	if e.Truncated {
+		// The cut may fall inside a multi-byte character.
+		snippet = strings.ToValidUTF8(snippet[:decodeSnippetBytes], "")
+	}
+	e.Body = snippet
+	return e
+}
+
+func (e *decodeError) Error() string {
+	more := ""
+	if e.Truncated {
+		more = "..."
+	}
+	return fmt.Sprintf("decoding openweather response: %v; body %q%s", e.Err, e.Body, more)
+}
+
+func (e *decodeError) Unwrap() error { return e.Err }
+
+// fetchRaw calls an OpenWeather URL with the configured key and returns
+// the body and headers of a 200 response.
+func (c *WeatherClient) fetchRaw(ctx context.Context, endpoint string, params url.Values) ([]byte, http.Header, error) {
+	state := c.keys.Load()
+	if state.err != nil {
+		return nil, nil, state.err
+	}
+	if !c.breaker.Allow() {
+		return nil, nil, errCircuitOpen
+	}
+	key := state.pool.Next()
+	body, header, err := c.do(ctx, c.retry, endpoint, key, params, fetchLimits{})
+	if err != nil {
+		metrics.Inc("upstream_errors")
+	}
+	// A call the caller gave up on says nothing about OpenWeather's health.
+	if ctx.Err() == nil {
+		c.breaker.Record(err == nil || !countsAsFailure(err))
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && (upstreamErr.StatusCode == http.StatusUnauthorized || upstreamErr.StatusCode == http.StatusTooManyRequests) {
+		state.pool.Bench(key, keyBenchDuration)
+	}
+	return body, header, err
+}
+
+// do makes one OpenWeather call, repeated as retry allows, and returns
+// the body and headers of a 200 response.
+func (c *WeatherClient) do(ctx context.Context, retry retryPolicy, endpoint, key string, params url.Values, limits fetchLimits) ([]byte, http.Header, error) {
+	params.Set("APPID", key)
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	defer func() { <-c.slots }()
+	if limits.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.timeout)
+		defer cancel()
+	}
+	endpoint += "?" + params.Encode()
+	start := time.Now()
+	sent := false
+	defer func() {
+		if sent {
+			upstreamLatency.Record(time.Since(start))
+			metrics.Observe("upstream_request", time.Since(start))
+		}
+	}()
+	resp, err := retry.do(ctx, c.httpClient, func() (*http.Request, error) {
+		if !upstreamQuota.Take(time.Now()) {
+			return nil, errQuotaExhausted
+		}
+		sent = true
+		return http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	})
+	if err != nil {
+		if sent && !limits.binary {
+			lastResponses.Record(endpoint, key, 0, nil, err)
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the entire response body, one byte past the cap so an
+	// oversized one is detected rather than silently truncated.
+	var r io.Reader = resp.Body
+	if limits.maxBytes > 0 {
+		r = io.LimitReader(resp.Body, limits.maxBytes+1)
+	}
+	body, err := io.ReadAll(r)
+	if !limits.binary {
+		lastResponses.Record(endpoint, key, resp.StatusCode, body, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if limits.maxBytes > 0 && int64(len(body)) > limits.maxBytes {
+		return nil, nil, errUpstreamTooLarge
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return nil, nil, &UpstreamError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+	return body, resp.Header, nil
+}
+
+// Current fetches the current weather for city in the given units.
+func (c *WeatherClient) Current(ctx context.Context, city, units string) (WeatherData, error) {
+	return c.current(ctx, url.Values{"q": {city}}, units)
+}
+
+// CurrentAt fetches the current weather at a coordinate pair.
+func (c *WeatherClient) CurrentAt(ctx context.Context, lat, lon float64, units string) (WeatherData, error) {
+	return c.current(ctx, url.Values{
+		"lat": {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon": {strconv.FormatFloat(lon, 'f', -1, 64)},
+	}, units)
+}
+
+func (c *WeatherClient) current(ctx context.Context, params url.Values, units string) (WeatherData, error) {
+	if units != unitsStandard {
+		params.Set("units", units)
+	}
+	var weather WeatherData
+	if err := c.fetch(ctx, endpointWeather, params, &weather); err != nil {
+		return WeatherData{}, err
+	}
+	weather.Units, weather.UpstreamStatus = units, http.StatusOK
+	if !weather.tempKnown(weather.Main.Temp) {
+		if absoluteZeroGuard == zeroGuardStrict {
+			return WeatherData{}, fmt.Errorf("%s: %w", weather.Name, errAbsoluteZero)
+		}
+	} else if err := checkTemperature(weather); err != nil {
+		if strictSanity {
+			return WeatherData{}, err
+		}
+		log.Printf("warning: %v", err)
+	}
+	if oneCallEnabled {
+		uvi, err := c.currentUVI(ctx, weather.Coord.Lat, weather.Coord.Lon)
+		if err != nil {
+			log.Printf("one call lookup for %q failed: %v", weather.Name, err)
+		} else {
+			weather.UVI = uvi
+			weather.HasUVI = true
+		}
+	}
+	return weather, nil
+}
+
+// writeQueryError maps a failed upstream query to an HTTP error response,
+// with OpenWeather's own status in X-Upstream-Status when it answered.
+func writeQueryError(w http.ResponseWriter, err error) {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		w.Header().Set("X-Upstream-Status", strconv.Itoa(upstreamErr.StatusCode))
+	}
+	http.Error(w, err.Error(), queryErrorStatus(err))
+}
+
+// queryErrorStatus maps a provider error to the status we answer with: a
+// city OpenWeather doesn't know, or a time it has no data for, is 404,
+// other upstream failures are 502 and an open circuit breaker is 503.
+func queryErrorStatus(err error) int {
+	var upstreamErr *UpstreamError
+	var decodeErr *decodeError
+	switch {
+	case errors.As(err, &upstreamErr) && upstreamErr.StatusCode == http.StatusNotFound,
+		errors.Is(err, errNoHistoricalData):
+		return http.StatusNotFound
+	case errors.As(err, &upstreamErr):
+		return http.StatusBadGateway
+	case errors.As(err, &decodeErr), errors.Is(err, errUpstreamTooLarge), errors.Is(err, errAbsoluteZero), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusBadGateway
+	case errors.Is(err, errCircuitOpen), errors.Is(err, errQuotaExhausted):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errCoordsUnavailable), errors.Is(err, errGeocodingUnavailable):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// newTestClient is a WeatherClient whose OpenWeather endpoints are all
+// upstream.
+func newTestClient(t *testing.T, upstream *httptest.Server) *WeatherClient {
+	t.Helper()
+	t.Setenv("OPENWEATHER_API_KEY", "0123456789abcdef0123456789abcdef")
+	for name := range defaultEndpoints {
+		t.Setenv("OPENWEATHER_ENDPOINT_"+strings.ToUpper(name), upstream.URL)
+	}
+	c, err := NewWeatherClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestClientHonoursContext checks that a cancelled context aborts the
+// OpenWeather request itself, and isn't held against the breaker.
+func TestClientHonoursContext(t *testing.T) {
+	cancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+	t.Setenv("BREAKER_THRESHOLD", "1")
+	c := newTestClient(t, upstream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Current(ctx, "London", unitsStandard)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Current returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Current took %s after a 50ms deadline", elapsed)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("upstream request was not cancelled")
+	}
+	if state := c.BreakerState(); state != breakerClosed {
+		t.Errorf("breaker %s after a cancelled call, want closed", state)
+	}
+}
+
+func TestDecodeErrorSnippet(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef"
+	var syntaxErr error = &json.SyntaxError{}
+
+	e := newDecodeError([]byte(`{"name": "London", "key": "`+key+`", `), key, syntaxErr)
+	if e.Truncated || strings.Contains(e.Error(), key) {
+		t.Errorf("short body: %v", e)
+	}
+	if want := `body "{\"name\": \"London\", \"key\": \"REDACTED\", "`; !strings.Contains(e.Error(), want) {
+		t.Errorf("error %q lacks the quoted snippet %s", e.Error(), want)
+	}
+
+	// The key straddles the cut, so truncating first would leak its start.
+	long := strings.Repeat("x", decodeSnippetBytes-10) + key + strings.Repeat("y", 1000)
+	e = newDecodeError([]byte(long), key, syntaxErr)
+	if !e.Truncated || len(e.Body) > decodeSnippetBytes || !strings.HasSuffix(e.Error(), `"...`) {
+		t.Errorf("long body: truncated %v, %d bytes: %v", e.Truncated, len(e.Body), e)
+	}
+	if strings.Contains(e.Body, key[:10]) {
+		t.Errorf("snippet leaks the start of the key: %q", e.Body)
+	}
+
+	// A cut inside a multi-byte character doesn't leave half of it.
+	e = newDecodeError([]byte(strings.Repeat("x", decodeSnippetBytes-1)+"é"), "", syntaxErr)
+	if !utf8.ValidString(e.Body) {
+		t.Errorf("snippet is not valid UTF-8: %q", e.Body)
+	}
+}
+
+// TestDecodeRetries feeds the client a body cut short, then the real one.
+// A syntax error is asked for again only with UPSTREAM_DECODE_RETRIES set,
+// and what couldn't be parsed is quoted in the error.
+func TestDecodeRetries(t *testing.T) {
+	for _, tt := range []struct {
+		retries   string
+		bodies    []string
+		wantCalls int64
+		wantErr   bool
+	}{
+		{"0", []string{londonJSON[:40], londonJSON}, 1, true},
+		{"1", []string{londonJSON[:40], londonJSON}, 2, false},
+		{"1", []string{londonJSON[:40], londonJSON[:40]}, 2, true},
+		// A type mismatch would come back the same, so it isn't retried.
+		{"3", []string{`{"name": 42}`, londonJSON}, 1, true},
+	} {
+		var calls atomic.Int64
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			w.Write([]byte(tt.bodies[min(int(n), len(tt.bodies))-1]))
+		}))
+		t.Setenv("UPSTREAM_DECODE_RETRIES", tt.retries)
+		t.Setenv("UPSTREAM_RETRY_BACKOFF", "1ms")
+		c := newTestClient(t, upstream)
+
+		_, err := c.Current(context.Background(), "London", unitsStandard)
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("retries %s, bodies %.12q: %d calls, want %d", tt.retries, tt.bodies, got, tt.wantCalls)
+		}
+		var decodeErr *decodeError
+		switch {
+		case !tt.wantErr && err != nil:
+			t.Errorf("retries %s, bodies %.12q: %v", tt.retries, tt.bodies, err)
+		case tt.wantErr && !errors.As(err, &decodeErr):
+			t.Errorf("retries %s, bodies %.12q: got %v, want a decodeError", tt.retries, tt.bodies, err)
+		case tt.wantErr && (!strings.Contains(err.Error(), `body "{`) || queryErrorStatus(err) != http.StatusBadGateway):
+			t.Errorf("retries %s: error %q should quote the body and map to 502", tt.retries, err)
+		}
+		upstream.Close()
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// VoiceFormatter renders a sentence or two for a voice assistant to read
+// out, e.g. "It's currently 18 degrees and clear in London, with a high of
+// 21 today." Numbers are whole and there are no symbols, so text-to-speech
+// reads it as written.
+type VoiceFormatter struct {
+	Units string
+	ReportOptions
+}
+
+func (VoiceFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+// Only differences a listener would notice get their own sentence.
+const (
+	voiceFeelsLikeGap = 3    // degrees, in the report's units
+	voiceWindyMS      = 10.8 // m/s, Beaufort 6 ("strong breeze")
+)
+
+func (f VoiceFormatter) Format(w WeatherData) string {
+	units := f.Units
+	if units == "" {
+		units = unitsMetric
+	}
+	temp := math.Round(convertTemp(w.Main.Temp, w.Units, units))
+	feelsRaw, _ := w.feelsLike()
+	feels := math.Round(convertTemp(feelsRaw, w.Units, units))
+	high := math.Round(convertTemp(w.Main.TempMax, w.Units, units))
+
+	tempKnown, feelsKnown := w.tempKnown(w.Main.Temp), w.feelsLikeKnown()
+	in := ""
+	if name := w.Location().Name; name != "" {
+		in = " in " + spokenText(name)
+	}
+
+	var b strings.Builder
+	switch {
+	case f.showsActual() && !tempKnown, !f.showsActual() && !feelsKnown:
+		fmt.Fprintf(&b, "There's no temperature reading%s right now.", in)
+	default:
+		if f.showsActual() {
+			fmt.Fprintf(&b, "It's currently %s", spokenTemp(temp, units))
+		} else {
+			fmt.Fprintf(&b, "It currently feels like %s", spokenTemp(feels, units))
+		}
+		if phrase := spokenCondition(w.condition()); phrase != "" {
+			b.WriteString(" " + phrase)
+		}
+		b.WriteString(in)
+		if f.showsActual() && w.Main.TempMax != 0 && w.tempKnown(w.Main.TempMax) && high > temp {
+			fmt.Fprintf(&b, ", with a high of %s today", spokenNumber(high))
+		}
+		b.WriteString(".")
+	}
+	if f.showsActual() && f.showsFeelsLike() && tempKnown && feelsKnown && math.Abs(feels-temp) >= voiceFeelsLikeGap {
+		fmt.Fprintf(&b, " It feels like %s.", spokenNumber(feels))
+	}
+	if !w.NoWind && w.windSpeed(unitsMetric) >= voiceWindyMS {
+		b.WriteString(" It's windy.")
+	}
+	if f.Advice {
+		if tip := recommend(w); tip != "" {
+			b.WriteString(" " + tip)
+		}
+	}
+	if credit := f.attribution(); credit != "" {
+		b.WriteString(" " + credit + ".")
+	}
+	return b.String() + "\n"
+}
+
+// spokenNumber says a whole number the way it should be read, with
+// "minus" rather than a sign a speech engine might skip.
+func spokenNumber(v float64) string {
+	switch {
+	case v == 0:
+		// Also catches -0, which would print as "-0".
+		return "0"
+	case v < 0:
+		return fmt.Sprintf("minus %.0f", -v)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// spokenTemp adds the unit: "degrees" for Celsius and Fahrenheit, which
+// the listener already expects, and "kelvin" for standard units.
+func spokenTemp(v float64, units string) string {
+	unit := "degrees"
+	switch {
+	case units == unitsStandard:
+		unit = "kelvin"
+	case math.Abs(v) == 1:
+		unit = "degree"
+	}
+	return spokenNumber(v) + " " + unit
+}
+
+// spokenCondition turns a condition into a phrase that follows the
+// temperature: "and clear", "with light rain". The unknown condition says
+// nothing.
+func spokenCondition(c Condition) string {
+	if c == unknownCondition {
+		return ""
+	}
+	description := spokenText(strings.ToLower(c.Description))
+	switch conditionGroup(c.Main, c.ID) {
+	case groupClear:
+		return "and clear"
+	case groupThunderstorm:
+		return "with thunderstorms"
+	case groupUnknown:
+		return ""
+	}
+	if description == "" {
+		return ""
+	}
+	return "with " + description
+}
+
+// spokenText drops the symbols OpenWeather uses in some names and
+// descriptions, such as the slash in "sand/dust whirls".
+var spokenText = strings.NewReplacer("/", " and ", "&", " and ", "_", " ").Replace
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVoiceFormatter(t *testing.T) {
+	quiet := ReportOptions{NoAttribution: true}
+	weather := func(change func(*WeatherData)) WeatherData {
+		w := sampleWeather()
+		change(&w)
+		return w
+	}
+	tests := []struct {
+		name  string
+		w     WeatherData
+		units string
+		opts  ReportOptions
+		want  string
+	}{
+		{"London", sampleWeather(), unitsMetric, quiet,
+			"It's currently 15 degrees with broken clouds in London, with a high of 16 today."},
+		{"attribution", sampleWeather(), unitsMetric, ReportOptions{},
+			"It's currently 15 degrees with broken clouds in London, with a high of 16 today. Data provided by OpenWeather."},
+		{"feels like first", sampleWeather(), unitsMetric, ReportOptions{NoAttribution: true, Show: showFeelsLike},
+			"It currently feels like 14 degrees with broken clouds in London."},
+		{"kelvin", sampleWeather(), unitsStandard, quiet,
+			"It's currently 288 kelvin with broken clouds in London, with a high of 289 today."},
+		{"clear, cold and windy", weather(func(w *WeatherData) {
+			w.Weather = []Condition{{ID: 800, Main: "Clear", Description: "clear sky"}}
+			w.Main.Temp, w.Main.FeelsLike, w.Main.TempMax = 268.15, 262.15, 0
+			w.Wind.Speed = 12
+		}), unitsMetric, quiet,
+			"It's currently minus 5 degrees and clear in London. It feels like minus 11. It's windy."},
+		{"thunderstorm", weather(func(w *WeatherData) {
+			w.Weather = []Condition{{ID: 211, Main: "Thunderstorm", Description: "thunderstorm"}}
+		}), unitsMetric, quiet,
+			"It's currently 15 degrees with thunderstorms in London, with a high of 16 today."},
+		{"symbols spelled out", weather(func(w *WeatherData) {
+			w.Weather = []Condition{{ID: 731, Main: "Dust", Description: "sand/dust whirls"}}
+		}), unitsMetric, quiet,
+			"It's currently 15 degrees with sand and dust whirls in London, with a high of 16 today."},
+		{"unknown condition", weather(func(w *WeatherData) { w.Weather = nil }), unitsMetric, quiet,
+			"It's currently 15 degrees in London, with a high of 16 today."},
+		{"one degree", weather(func(w *WeatherData) {
+			w.Main.Temp, w.Main.FeelsLike, w.Main.TempMax = 274.15, 274.15, 0
+		}), unitsMetric, quiet,
+			"It's currently 1 degree with broken clouds in London."},
+	}
+	for _, tt := range tests {
+		got := VoiceFormatter{Units: tt.units, ReportOptions: tt.opts}.Format(tt.w)
+		if got != tt.want+"\n" {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want+"\n")
+		}
+	}
+}
+
+func TestSpokenNumber(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{7, "7"},
+		{-12, "minus 12"},
+		{100, "100"},
+	}
+	for _, tt := range tests {
+		if got := spokenNumber(tt.v); got != tt.want {
+			t.Errorf("spokenNumber(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
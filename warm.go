@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// warmCache fetches each city into the cache, then repeats every interval
+// so the cities in WARM_CITIES are always answered from memory. Requests go
+// through the provider, so they share the upstream concurrency limit.
+func warmCache(provider Provider, cache *Cache, cities []string, interval time.Duration) {
+	for {
+		start := time.Now()
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		failed := 0
+		for _, raw := range cities {
+			city, err := parseCityQuery(raw)
+			if err != nil {
+				log.Printf("cache warm: skipping %q: %v", raw, err)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data, err := provider.Current(context.Background(), city, fetchUnits(""))
+				if err != nil {
+					log.Printf("cache warm: %s failed: %v", city, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					return
+				}
+				cache.Set(cacheKey(city, data.Units), data)
+			}()
+		}
+		wg.Wait()
+		log.Printf("cache warm: %d cities, %d failed, took %s", len(cities), failed, time.Since(start).Round(time.Millisecond))
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// XMLProvider is implemented by providers that can return OpenWeather's
+// own XML representation, for legacy clients that expect it.
+type XMLProvider interface {
+	CurrentXML(ctx context.Context, city, units string) ([]byte, error)
+}
+
+// CurrentXML fetches the current weather with mode=xml. The body is passed
+// through untouched.
+func (c *WeatherClient) CurrentXML(ctx context.Context, city, units string) ([]byte, error) {
+	params := url.Values{"q": {city}, "mode": {"xml"}}
+	if units != unitsStandard {
+		params.Set("units", units)
+	}
+	body, _, err := c.fetchRaw(ctx, c.endpoints[endpointWeather], params)
+	return body, err
+}
+
+func (s *server) writeWeatherXML(w http.ResponseWriter, r *http.Request, city, units string) {
+	xp, ok := s.provider.(XMLProvider)
+	if !ok {
+		http.Error(w, "xml format is not available", http.StatusNotImplemented)
+		return
+	}
+	// The body isn't parsed, so it can't be converted afterwards: always
+	// ask upstream for the client's units.
+	if units == "" {
+		units = defaultUnits
+	}
+	body, err := xp.CurrentXML(r.Context(), city, units)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(body)
+}
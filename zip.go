@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ZipResolver is implemented by geocoders that can also turn a postal code
+// into a place.
+type ZipResolver interface {
+	ResolveZip(ctx context.Context, zip, country string) (Location, error)
+}
+
+// ResolveZip looks a postal code up with the geocoding API.
+func (c *WeatherClient) ResolveZip(ctx context.Context, zip, country string) (Location, error) {
+	var out struct {
+		Name    string  `json:"name"`
+		Country string  `json:"country"`
+		Lat     float64 `json:"lat"`
+		Lon     float64 `json:"lon"`
+	}
+	if err := c.fetch(ctx, endpointGeocodingZip, url.Values{"zip": {zip + "," + country}}, &out); err != nil {
+		return Location{}, err
+	}
+	return Location{Name: out.Name, Country: out.Country, Lat: out.Lat, Lon: out.Lon}, nil
+}
+
+var (
+	usZip        = regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`)
+	caPostalCode = regexp.MustCompile(`^[A-Z][0-9][A-Z] ?[0-9][A-Z][0-9]$`)
+	ukPostcode   = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`)
+)
+
+// inferCountryFromZip guesses the country of a postal code from its shape:
+//
+//   - 12345 or 12345-6789 is US. Other countries use five digits too
+//     (DE, FR, ES, ...), but the US is by far the most common caller.
+//   - A1A 1A1, with or without the space, is CA.
+//   - The UK formats (SW1A 1AA, M1 1AE, EC1A 1BB, ...) are GB.
+//
+// Anything else reports false, and the caller must ask for the country
+// rather than guess.
+func inferCountryFromZip(zip string) (string, bool) {
+	zip = strings.ToUpper(strings.TrimSpace(zip))
+	switch {
+	case usZip.MatchString(zip):
+		return "US", true
+	case caPostalCode.MatchString(zip):
+		return "CA", true
+	case ukPostcode.MatchString(zip):
+		return "GB", true
+	}
+	return "", false
+}
+
+// handleWeatherByZip resolves a postal code to a place and then answers
+// exactly as /weather/{city} would, for the code's coordinates: the place
+// name alone may be shared by several towns. ?country= is inferred when
+// absent.
+func (s *server) handleWeatherByZip(w http.ResponseWriter, r *http.Request) {
+	zr, ok := s.geocoder.(ZipResolver)
+	if !ok {
+		http.Error(w, "postal code lookups are not available", http.StatusNotImplemented)
+		return
+	}
+	zip := strings.TrimSpace(stripControl(r.PathValue("zip")))
+	if zip == "" || len(zip) > 10 {
+		http.Error(w, "invalid postal code", http.StatusBadRequest)
+		return
+	}
+	country := strings.ToUpper(r.URL.Query().Get("country"))
+	if country == "" {
+		var ok bool
+		if country, ok = inferCountryFromZip(zip); !ok {
+			http.Error(w, fmt.Sprintf("can't tell which country %q is in, add ?country=CC", zip), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(country) != 2 || !isASCIILetters(country) {
+		http.Error(w, fmt.Sprintf("invalid country %q: expected a two-letter code", country), http.StatusBadRequest)
+		return
+	}
+	loc, err := zr.ResolveZip(r.Context(), zip, country)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	s.serveWeather(w, r, weatherQuery{at: &point{Lat: loc.Lat, Lon: loc.Lon}}, "")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInferCountryFromZip(t *testing.T) {
+	tests := []struct {
+		zip  string
+		want string
+		ok   bool
+	}{
+		{"90210", "US", true},
+		{"90210-1234", "US", true},
+		{" 10001 ", "US", true},
+		{"K1A 0B1", "CA", true},
+		{"k1a0b1", "CA", true},
+		{"SW1A 1AA", "GB", true},
+		{"M1 1AE", "GB", true},
+		{"EC1A1BB", "GB", true},
+		{"1234", "", false},
+		{"902101", "", false},
+		{"90210-12", "", false},
+		{"1000 AB", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := inferCountryFromZip(tt.zip)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("inferCountryFromZip(%q) = %q, %v; want %q, %v", tt.zip, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// zipGeocoder resolves every postal code to the same place.
+type zipGeocoder struct {
+	FakeGeocoder
+	loc Location
+}
+
+func (p *zipGeocoder) ResolveZip(_ context.Context, zip, country string) (Location, error) {
+	return p.loc, nil
+}
+
+// TestWeatherByZipUsesCoordinates checks a resolved code is looked up by
+// its coordinates, not by the place name it resolved to.
+func TestWeatherByZipUsesCoordinates(t *testing.T) {
+	p := &coordProvider{FakeProvider: &FakeProvider{}}
+	s := newServer(p, NewCache(defaultCacheTTL, defaultCacheStaleTTL, defaultCacheMaxEntries))
+	s.geocoder = &zipGeocoder{loc: Location{Name: "Springfield", Country: "US", Lat: 39.8, Lon: -89.64}}
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/zip/62701", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "London") {
+		t.Fatalf("GET /zip/62701 = %d %q", rec.Code, rec.Body)
+	}
+	if p.asked == nil || *p.asked != (point{Lat: 39.8, Lon: -89.64}) {
+		t.Errorf("CurrentAt asked for %v, want the resolved 39.8,-89.64", p.asked)
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/zip/1000AB", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /zip/1000AB without ?country= = %d, want 400", rec.Code)
+	}
+}